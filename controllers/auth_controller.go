@@ -9,7 +9,10 @@ import (
 	"github.com/AngelVlc/lists-backend/services"
 )
 
-// TokenHandler is the handler for the auth/token endpoint
+// TokenHandler is the handler for the auth/token endpoint. The users with the two-factor
+// authentication enabled get a challenge token, which is exchanged for the tokens in the
+// auth/token/totp endpoint. The user names and ip addresses with too many failed logins
+// are locked out for a while.
 func TokenHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
 	if r.Method != http.MethodPost {
 		return okResult{nil, http.StatusMethodNotAllowed}
@@ -20,14 +23,39 @@ func TokenHandler(r *http.Request, servicePrv services.ServiceProvider) handlerR
 		return errorResult{err}
 	}
 
+	attemptsSrv := servicePrv.GetLoginAttemptsService()
+	ip := getClientIPFromContext(r)
+	if err := attemptsSrv.CheckLoginAllowed(l.UserName, ip); err != nil {
+		return errorResult{err}
+	}
+
 	userSrv := servicePrv.GetUsersService()
 	foundUser, err := userSrv.CheckIfUserPasswordIsOk(l.UserName, l.Password)
+	// only the wrong credentials are counted, not the right ones of a user who can't log in
+	if _, isInvalidCredentials := err.(*appErrors.InvalidCredentialsError); isInvalidCredentials {
+		if err := attemptsSrv.AddLoginFailure(l.UserName, ip); err != nil {
+			return errorResult{err}
+		}
+	}
 	if err != nil {
 		return errorResult{err}
 	}
 
 	authSrv := servicePrv.GetAuthService()
 
+	// the failures are reset when the second step of the login succeeds
+	if foundUser.TotpEnabled {
+		challenge, err := authSrv.CreateChallengeToken(foundUser)
+		if err != nil {
+			return errorResult{err}
+		}
+		return okResult{challenge, http.StatusOK}
+	}
+
+	if err := attemptsSrv.ResetLoginFailures(l.UserName); err != nil {
+		return errorResult{err}
+	}
+
 	tokens, err := authSrv.CreateTokens(foundUser)
 	if err != nil {
 		return errorResult{err}
@@ -36,6 +64,61 @@ func TokenHandler(r *http.Request, servicePrv services.ServiceProvider) handlerR
 	return okResult{tokens, http.StatusOK}
 }
 
+// TotpTokenHandler is the handler for the auth/token/totp endpoint, the second step of the
+// login of the users with the two-factor authentication enabled
+func TotpTokenHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if r.Method != http.MethodPost {
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+
+	l, err := parseTotpTokenBody(r)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	authSrv := servicePrv.GetAuthService()
+	userID, err := authSrv.ParseChallengeToken(l.ChallengeToken)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	userSrv := servicePrv.GetUsersService()
+
+	foundUser := models.User{}
+
+	err = userSrv.GetUserByID(userID, &foundUser)
+	if err != nil || !foundUser.IsActive {
+		return errorResult{&appErrors.BadRequestError{Msg: "The user is no longer valid", InternalError: nil}}
+	}
+
+	attemptsSrv := servicePrv.GetLoginAttemptsService()
+	ip := getClientIPFromContext(r)
+	if err := attemptsSrv.CheckLoginAllowed(foundUser.UserName, ip); err != nil {
+		return errorResult{err}
+	}
+
+	err = authSrv.CheckTotpCode(userID, l.Code)
+	if _, isUnauthorized := err.(*appErrors.UnauthorizedError); isUnauthorized {
+		if err := attemptsSrv.AddLoginFailure(foundUser.UserName, ip); err != nil {
+			return errorResult{err}
+		}
+	}
+	if err != nil {
+		return errorResult{err}
+	}
+
+	if err := attemptsSrv.ResetLoginFailures(foundUser.UserName); err != nil {
+		return errorResult{err}
+	}
+
+	tokens, err := authSrv.CreateTokens(&foundUser)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	return okResult{tokens, http.StatusOK}
+}
+
 // RefreshTokenHandler is the handler for the auth/refreshtoken endpoint
 func RefreshTokenHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
 	if r.Method != http.MethodPost {
@@ -58,11 +141,11 @@ func RefreshTokenHandler(r *http.Request, servicePrv services.ServiceProvider) h
 	foundUser := models.User{}
 
 	err = userSrv.GetUserByID(rtInfo.UserID, &foundUser)
-	if err != nil {
+	if err != nil || !foundUser.IsActive {
 		return errorResult{&appErrors.BadRequestError{Msg: "The user is no longer valid", InternalError: nil}}
 	}
 
-	tokens, err := authSrv.CreateTokens(&foundUser)
+	tokens, err := authSrv.RefreshTokens(rtInfo, &foundUser)
 	if err != nil {
 		return errorResult{err}
 	}
@@ -70,6 +153,60 @@ func RefreshTokenHandler(r *http.Request, servicePrv services.ServiceProvider) h
 	return okResult{tokens, http.StatusOK}
 }
 
+// LogoutHandler is the handler for the auth/logout endpoint, which revokes the refresh token
+func LogoutHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if r.Method != http.MethodPost {
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+
+	rt, err := parseRefreshTokenBody(r)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	authSrv := servicePrv.GetAuthService()
+	rtInfo, err := authSrv.ParseRefreshToken(rt.RefreshToken)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	err = authSrv.RevokeRefreshToken(rtInfo)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	return okResult{nil, http.StatusNoContent}
+}
+
+// LogoutAllHandler is the handler for the auth/logoutall endpoint, which revokes all the
+// refresh tokens of the user
+func LogoutAllHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if r.Method != http.MethodPost {
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+
+	if isAPIKeyAuth(r) {
+		return errorResult{&appErrors.ForbiddenError{Msg: "The sessions can't be revoked with an api key"}}
+	}
+
+	err := servicePrv.GetAuthService().RevokeUserRefreshTokens(getUserIDFromContext(r))
+	if err != nil {
+		return errorResult{err}
+	}
+
+	return okResult{nil, http.StatusNoContent}
+}
+
+// JwksHandler is the handler for the .well-known/jwks.json endpoint, which publishes the
+// public keys used to verify the tokens
+func JwksHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if r.Method != http.MethodGet {
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+
+	return okResult{servicePrv.GetAuthService().GetJwks(), http.StatusOK}
+}
+
 func parseTokenBody(r *http.Request) (models.Login, error) {
 	decoder := json.NewDecoder(r.Body)
 
@@ -90,6 +227,26 @@ func parseTokenBody(r *http.Request) (models.Login, error) {
 	return l, nil
 }
 
+func parseTotpTokenBody(r *http.Request) (models.TotpLogin, error) {
+	decoder := json.NewDecoder(r.Body)
+
+	var l models.TotpLogin
+	err := decoder.Decode(&l)
+	if err != nil {
+		return models.TotpLogin{}, &appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}
+	}
+
+	if len(l.ChallengeToken) == 0 {
+		return models.TotpLogin{}, &appErrors.BadRequestError{Msg: "ChallengeToken is mandatory", InternalError: nil}
+	}
+
+	if len(l.Code) == 0 {
+		return models.TotpLogin{}, &appErrors.BadRequestError{Msg: "Code is mandatory", InternalError: nil}
+	}
+
+	return l, nil
+}
+
 func parseRefreshTokenBody(r *http.Request) (*models.RefreshToken, error) {
 	decoder := json.NewDecoder(r.Body)
 
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/AngelVlc/lists-backend/models"
 
@@ -15,9 +16,29 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+type mockedLoginAttemptsService struct {
+	mock.Mock
+}
+
+func (s *mockedLoginAttemptsService) CheckLoginAllowed(userName string, ip string) error {
+	args := s.Called(userName, ip)
+	return args.Error(0)
+}
+
+func (s *mockedLoginAttemptsService) AddLoginFailure(userName string, ip string) error {
+	args := s.Called(userName, ip)
+	return args.Error(0)
+}
+
+func (s *mockedLoginAttemptsService) ResetLoginFailures(userName string) error {
+	args := s.Called(userName)
+	return args.Error(0)
+}
+
 func TestTokenHandler(t *testing.T) {
 	testUsersSrv := new(mockedUsersService)
 	testAuthSrv := new(mockedAuthService)
+	testAttemptsSrv := new(mockedLoginAttemptsService)
 
 	testSrvProvider := new(mockedServiceProvider)
 
@@ -34,6 +55,7 @@ func TestTokenHandler(t *testing.T) {
 		assert.Equal(t, "Invalid body", badReqErr.Error())
 
 		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
 	})
 
 	t.Run("POST without user name in body should return an errorResult with a BadRequestError", func(t *testing.T) {
@@ -56,6 +78,7 @@ func TestTokenHandler(t *testing.T) {
 		assert.Equal(t, "UserName is mandatory", badReqErr.Error())
 
 		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
 	})
 
 	t.Run("POST without pasword in body should return an errorResult with a BadRequestError", func(t *testing.T) {
@@ -78,6 +101,7 @@ func TestTokenHandler(t *testing.T) {
 		assert.Equal(t, "Password is mandatory", badReqErr.Error())
 
 		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
 	})
 
 	t.Run("POST returns and okResult with a 405 status when the method is not GET, POST, PUT or DELETE", func(t *testing.T) {
@@ -89,6 +113,7 @@ func TestTokenHandler(t *testing.T) {
 
 		assert.Equal(t, want, got, "should be equal")
 		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
 	})
 
 	t.Run("POST returns an errorResult when the CheckIfUserPasswordIsOk() returns an error", func(t *testing.T) {
@@ -100,8 +125,10 @@ func TestTokenHandler(t *testing.T) {
 
 		request, _ := http.NewRequest(http.MethodPost, "/auth/token", bytes.NewBuffer(body))
 
+		testSrvProvider.On("GetLoginAttemptsService").Return(testAttemptsSrv).Once()
 		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
 
+		testAttemptsSrv.On("CheckLoginAllowed", login.UserName, "").Return(nil).Once()
 		testUsersSrv.On("CheckIfUserPasswordIsOk", login.UserName, login.Password).Return(nil, errors.New("wadus")).Once()
 
 		got := TokenHandler(request, testSrvProvider)
@@ -110,6 +137,7 @@ func TestTokenHandler(t *testing.T) {
 		assert.Equal(t, true, isErrorResult, "should be an error result")
 
 		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
 	})
 
 	t.Run("POST returns an errorResult when CreateTokens returns an error", func(t *testing.T) {
@@ -121,13 +149,17 @@ func TestTokenHandler(t *testing.T) {
 
 		request, _ := http.NewRequest(http.MethodPost, "/auth/token", bytes.NewBuffer(body))
 
+		testSrvProvider.On("GetLoginAttemptsService").Return(testAttemptsSrv).Once()
 		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
 
+		testAttemptsSrv.On("CheckLoginAllowed", login.UserName, "").Return(nil).Once()
+
 		user := models.User{
 			UserName: login.UserName,
 			ID:       "id",
 		}
 		testUsersSrv.On("CheckIfUserPasswordIsOk", login.UserName, login.Password).Return(&user, nil).Once()
+		testAttemptsSrv.On("ResetLoginFailures", login.UserName).Return(nil).Once()
 
 		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
 
@@ -139,6 +171,7 @@ func TestTokenHandler(t *testing.T) {
 		assert.Equal(t, true, isErrorResult, "should be an error result")
 
 		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
 	})
 
 	t.Run("POST returns an okResult when there is no error", func(t *testing.T) {
@@ -150,13 +183,17 @@ func TestTokenHandler(t *testing.T) {
 
 		request, _ := http.NewRequest(http.MethodPost, "/auth/token", bytes.NewBuffer(body))
 
+		testSrvProvider.On("GetLoginAttemptsService").Return(testAttemptsSrv).Once()
 		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
 
+		testAttemptsSrv.On("CheckLoginAllowed", login.UserName, "").Return(nil).Once()
+
 		user := models.User{
 			UserName: login.UserName,
 			ID:       "id",
 		}
 		testUsersSrv.On("CheckIfUserPasswordIsOk", login.UserName, login.Password).Return(&user, nil).Once()
+		testAttemptsSrv.On("ResetLoginFailures", login.UserName).Return(nil).Once()
 
 		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
 
@@ -171,6 +208,201 @@ func TestTokenHandler(t *testing.T) {
 
 		assert.Equal(t, want, got, "should be equal")
 		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
+	})
+
+	t.Run("POST returns a challenge token when the user has the two-factor authentication enabled", func(t *testing.T) {
+		login := models.Login{
+			UserName: "wadus",
+			Password: "pass",
+		}
+		body, _ := json.Marshal(login)
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/token", bytes.NewBuffer(body))
+
+		testSrvProvider.On("GetLoginAttemptsService").Return(testAttemptsSrv).Once()
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+
+		testAttemptsSrv.On("CheckLoginAllowed", login.UserName, "").Return(nil).Once()
+
+		user := models.User{
+			UserName:    login.UserName,
+			ID:          "id",
+			TotpEnabled: true,
+		}
+		testUsersSrv.On("CheckIfUserPasswordIsOk", login.UserName, login.Password).Return(&user, nil).Once()
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+
+		challenge := map[string]string{
+			"challengeToken": "theChallengeToken",
+		}
+		testAuthSrv.On("CreateChallengeToken", &user).Return(challenge, nil).Once()
+
+		got := TokenHandler(request, testSrvProvider)
+
+		want := okResult{challenge, http.StatusOK}
+
+		assert.Equal(t, want, got, "should be equal")
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
+	})
+
+	t.Run("POST returns an errorResult with a TooManyRequestsError when the login is locked out", func(t *testing.T) {
+		login := models.Login{
+			UserName: "wadus",
+			Password: "pass",
+		}
+		body, _ := json.Marshal(login)
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/token", bytes.NewBuffer(body))
+
+		lockedErr := &appErrors.TooManyRequestsError{Msg: "Too many failed login attempts", RetryAfter: time.Minute}
+
+		testSrvProvider.On("GetLoginAttemptsService").Return(testAttemptsSrv).Once()
+		testAttemptsSrv.On("CheckLoginAllowed", login.UserName, "").Return(lockedErr).Once()
+
+		got := TokenHandler(request, testSrvProvider)
+
+		assert.Equal(t, errorResult{lockedErr}, got)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
+	})
+
+	t.Run("POST counts the failed login when the credentials are not valid", func(t *testing.T) {
+		login := models.Login{
+			UserName: "wadus",
+			Password: "pass",
+		}
+		body, _ := json.Marshal(login)
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/token", bytes.NewBuffer(body))
+
+		credentialsErr := &appErrors.InvalidCredentialsError{}
+
+		testSrvProvider.On("GetLoginAttemptsService").Return(testAttemptsSrv).Once()
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testAttemptsSrv.On("CheckLoginAllowed", login.UserName, "").Return(nil).Once()
+		testUsersSrv.On("CheckIfUserPasswordIsOk", login.UserName, login.Password).Return(nil, credentialsErr).Once()
+		testAttemptsSrv.On("AddLoginFailure", login.UserName, "").Return(nil).Once()
+
+		got := TokenHandler(request, testSrvProvider)
+
+		assert.Equal(t, errorResult{credentialsErr}, got)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
+	})
+
+	t.Run("POST doesn't count the failed login when the password is right but the user can't log in", func(t *testing.T) {
+		login := models.Login{
+			UserName: "wadus",
+			Password: "pass",
+		}
+		body, _ := json.Marshal(login)
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/token", bytes.NewBuffer(body))
+
+		disabledErr := &appErrors.BadRequestError{Msg: "The user is disabled"}
+
+		testSrvProvider.On("GetLoginAttemptsService").Return(testAttemptsSrv).Once()
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testAttemptsSrv.On("CheckLoginAllowed", login.UserName, "").Return(nil).Once()
+		testUsersSrv.On("CheckIfUserPasswordIsOk", login.UserName, login.Password).Return(nil, disabledErr).Once()
+
+		got := TokenHandler(request, testSrvProvider)
+
+		assert.Equal(t, errorResult{disabledErr}, got)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
+	})
+}
+
+func TestTotpTokenHandler(t *testing.T) {
+	testUsersSrv := new(mockedUsersService)
+	testAuthSrv := new(mockedAuthService)
+	testAttemptsSrv := new(mockedLoginAttemptsService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	t.Run("POST without code in body should return an errorResult with a BadRequestError", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/token/totp", strings.NewReader(`{"challengeToken":"theChallengeToken"}`))
+
+		got := TotpTokenHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.Equal(t, true, isErrorResult, "should be an error result")
+		assert.IsType(t, &appErrors.BadRequestError{}, errorRes.err)
+		assert.Equal(t, "Code is mandatory", errorRes.err.Error())
+
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
+	})
+
+	t.Run("POST returns an errorResult when the challenge token is not valid", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/token/totp", strings.NewReader(`{"challengeToken":"theChallengeToken","code":"123456"}`))
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("ParseChallengeToken", "theChallengeToken").Return("", &appErrors.UnauthorizedError{Msg: "Invalid challenge token"}).Once()
+
+		got := TotpTokenHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.Equal(t, true, isErrorResult, "should be an error result")
+		assert.IsType(t, &appErrors.UnauthorizedError{}, errorRes.err)
+
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
+	})
+
+	t.Run("POST returns an errorResult when the code is not valid", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/token/totp", strings.NewReader(`{"challengeToken":"theChallengeToken","code":"123456"}`))
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testAuthSrv.On("ParseChallengeToken", "theChallengeToken").Return("id", nil).Once()
+		testSrvProvider.On("GetLoginAttemptsService").Return(testAttemptsSrv).Once()
+		testUsersSrv.On("GetUserByID", "id", &models.User{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(1).(*models.User) = models.User{ID: "id", UserName: "wadus", IsActive: true}
+		})
+		testAttemptsSrv.On("CheckLoginAllowed", "wadus", "").Return(nil).Once()
+		testAuthSrv.On("CheckTotpCode", "id", "123456").Return(&appErrors.UnauthorizedError{Msg: "Invalid two-factor authentication code"}).Once()
+		testAttemptsSrv.On("AddLoginFailure", "wadus", "").Return(nil).Once()
+
+		got := TotpTokenHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.Equal(t, true, isErrorResult, "should be an error result")
+		assert.IsType(t, &appErrors.UnauthorizedError{}, errorRes.err)
+
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
+	})
+
+	t.Run("POST returns the tokens when the code is valid", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/token/totp", strings.NewReader(`{"challengeToken":"theChallengeToken","code":"123456"}`))
+
+		user := models.User{ID: "id", UserName: "wadus", IsActive: true, TotpEnabled: true}
+		tokens := map[string]string{
+			"token": "theToken",
+		}
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testAuthSrv.On("ParseChallengeToken", "theChallengeToken").Return("id", nil).Once()
+		testUsersSrv.On("GetUserByID", "id", &models.User{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(1).(*models.User) = user
+		})
+		testSrvProvider.On("GetLoginAttemptsService").Return(testAttemptsSrv).Once()
+		testAttemptsSrv.On("CheckLoginAllowed", "wadus", "").Return(nil).Once()
+		testAuthSrv.On("CheckTotpCode", "id", "123456").Return(nil).Once()
+		testAttemptsSrv.On("ResetLoginFailures", "wadus").Return(nil).Once()
+		testAuthSrv.On("CreateTokens", &user).Return(tokens, nil).Once()
+
+		got := TotpTokenHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{tokens, http.StatusOK}, got)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+		testAttemptsSrv.AssertExpectations(t)
 	})
 }
 
@@ -266,7 +498,31 @@ func TestRefreshTokenHandler(t *testing.T) {
 
 	})
 
-	t.Run("POST returns an errorResult when CreateTokens returns an error", func(t *testing.T) {
+	t.Run("POST returns an errorResult when the user is disabled", func(t *testing.T) {
+		refreshToken := models.RefreshToken{
+			RefreshToken: "theRefreshToken",
+		}
+		body, _ := json.Marshal(refreshToken)
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/refreshtoken", bytes.NewBuffer(body))
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		rtInfo := models.RefreshTokenClaimsInfo{
+			UserID: "1",
+		}
+		testAuthSrv.On("ParseRefreshToken", refreshToken.RefreshToken).Return(&rtInfo, nil).Once()
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testUsersSrv.On("GetUserByID", rtInfo.UserID, &models.User{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(1).(*models.User) = models.User{ID: "1", UserName: "user"}
+		})
+
+		got := RefreshTokenHandler(request, testSrvProvider)
+
+		assert.Equal(t, errorResult{&appErrors.BadRequestError{Msg: "The user is no longer valid", InternalError: nil}}, got)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+	})
+
+	t.Run("POST returns an errorResult when RefreshTokens returns an error", func(t *testing.T) {
 		refreshToken := models.RefreshToken{
 			RefreshToken: "theRefreshToken",
 		}
@@ -284,13 +540,14 @@ func TestRefreshTokenHandler(t *testing.T) {
 		fu := models.User{
 			UserName: "user",
 			ID:       "1",
+			IsActive: true,
 		}
 		testUsersSrv.On("GetUserByID", rtInfo.UserID, &u).Return(nil).Run(func(args mock.Arguments) {
 			arg := args.Get(1).(*models.User)
 			*arg = fu
 		})
 
-		testAuthSrv.On("CreateTokens", &fu).Return(nil, &appErrors.UnexpectedError{Msg: "Error creating jwt token"}).Once()
+		testAuthSrv.On("RefreshTokens", &rtInfo, &fu).Return(nil, &appErrors.UnexpectedError{Msg: "Error creating jwt token"}).Once()
 
 		got := RefreshTokenHandler(request, testSrvProvider)
 
@@ -322,6 +579,7 @@ func TestRefreshTokenHandler(t *testing.T) {
 		fu := models.User{
 			UserName: "user",
 			ID:       "1",
+			IsActive: true,
 		}
 		testUsersSrv.On("GetUserByID", rtInfo.UserID, &u).Return(nil).Run(func(args mock.Arguments) {
 			arg := args.Get(1).(*models.User)
@@ -332,7 +590,7 @@ func TestRefreshTokenHandler(t *testing.T) {
 			"token":        "theToken",
 			"refreshToken": "theRefresToken",
 		}
-		testAuthSrv.On("CreateTokens", &fu).Return(tokens, nil).Once()
+		testAuthSrv.On("RefreshTokens", &rtInfo, &fu).Return(tokens, nil).Once()
 
 		got := RefreshTokenHandler(request, testSrvProvider)
 
@@ -354,6 +612,105 @@ func TestRefreshTokenHandler(t *testing.T) {
 	})
 }
 
+func TestLogoutHandler(t *testing.T) {
+	testUsersSrv := new(mockedUsersService)
+	testAuthSrv := new(mockedAuthService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	refreshToken := models.RefreshToken{
+		RefreshToken: "theRefreshToken",
+	}
+	body, _ := json.Marshal(refreshToken)
+
+	t.Run("POST returns an errorResult when ParseRefreshToken returns an error", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/logout", bytes.NewBuffer(body))
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("ParseRefreshToken", refreshToken.RefreshToken).Return(nil, &appErrors.UnauthorizedError{Msg: "Invalid refresh token"}).Once()
+
+		got := LogoutHandler(request, testSrvProvider)
+
+		assert.Equal(t, errorResult{&appErrors.UnauthorizedError{Msg: "Invalid refresh token"}}, got)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+	})
+
+	t.Run("POST revokes the refresh token", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/logout", bytes.NewBuffer(body))
+
+		rtInfo := models.RefreshTokenClaimsInfo{ID: "jti", FamilyID: "fid", UserID: "1"}
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("ParseRefreshToken", refreshToken.RefreshToken).Return(&rtInfo, nil).Once()
+		testAuthSrv.On("RevokeRefreshToken", &rtInfo).Return(nil).Once()
+
+		got := LogoutHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+	})
+}
+
+func TestLogoutAllHandler(t *testing.T) {
+	testUsersSrv := new(mockedUsersService)
+	testAuthSrv := new(mockedAuthService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	t.Run("POST revokes all the refresh tokens of the user", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/logoutall", nil)
+		request = addUserIDToContext("1", request)
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("RevokeUserRefreshTokens", "1").Return(nil).Once()
+
+		got := LogoutAllHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+	})
+
+	t.Run("returns an errorResult with a ForbiddenError when the request uses an api key", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/logoutall", nil)
+		request = Handler{}.addAPIKeyAuthToContext(addUserIDToContext("1", request))
+
+		got := LogoutAllHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.True(t, isErrorResult)
+		assert.IsType(t, &appErrors.ForbiddenError{}, errorRes.err)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+	})
+
+	t.Run("returns an okResult with a 405 status when the method is not POST", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/auth/logoutall", nil)
+
+		got := LogoutAllHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusMethodNotAllowed}, got)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+	})
+}
+
+func TestJwksHandler(t *testing.T) {
+	testUsersSrv := new(mockedUsersService)
+	testAuthSrv := new(mockedAuthService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	t.Run("GET returns the public keys", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+
+		jwks := models.JwksDto{Keys: []models.JSONWebKey{{KeyType: "EC", KeyID: "kid"}}}
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("GetJwks").Return(jwks).Once()
+
+		got := JwksHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{jwks, http.StatusOK}, got)
+		assertAuthExpectations(t, testSrvProvider, testUsersSrv, testAuthSrv)
+	})
+}
+
 func assertAuthExpectations(t *testing.T, sp *mockedServiceProvider, us *mockedUsersService, as *mockedAuthService) {
 	t.Helper()
 
@@ -28,3 +28,18 @@ func (sp *mockedServiceProvider) GetCountersService() services.CountersService {
 	args := sp.Called()
 	return args.Get(0).(services.CountersService)
 }
+
+func (sp *mockedServiceProvider) GetLoginAttemptsService() services.LoginAttemptsService {
+	args := sp.Called()
+	return args.Get(0).(services.LoginAttemptsService)
+}
+
+func (sp *mockedServiceProvider) GetRegistrationService() services.RegistrationService {
+	args := sp.Called()
+	return args.Get(0).(services.RegistrationService)
+}
+
+func (sp *mockedServiceProvider) GetPasswordResetService() services.PasswordResetService {
+	args := sp.Called()
+	return args.Get(0).(services.PasswordResetService)
+}
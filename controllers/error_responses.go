@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+)
+
+// ErrorResponse contains how an error is written in the response
+type ErrorResponse struct {
+	StatusCode int
+	Code       string
+	Msg        string
+	Fields     []appErrors.FieldError
+	Headers    map[string]string
+	// InternalError is only written in the logs
+	InternalError error
+}
+
+// ErrorResponseFunc returns the response of an error
+type ErrorResponseFunc func(err error) ErrorResponse
+
+// errorResponses contains the functions which return the response of each error type
+var errorResponses = map[reflect.Type]ErrorResponseFunc{}
+
+// RegisterErrorResponse sets the function which returns the response of the errors with the
+// same type as the given one. It isn't safe to use while the requests are handled, so the
+// error types must be registered before.
+func RegisterErrorResponse(err error, f ErrorResponseFunc) {
+	errorResponses[reflect.TypeOf(err)] = f
+}
+
+func init() {
+	RegisterErrorResponse(&appErrors.UnexpectedError{}, func(err error) ErrorResponse {
+		e := err.(*appErrors.UnexpectedError)
+		return ErrorResponse{StatusCode: http.StatusInternalServerError, Code: appErrors.CodeUnexpected, Msg: e.Msg, InternalError: e.InternalError}
+	})
+
+	// the model isn't in the message because it can be the name of a collection
+	RegisterErrorResponse(&appErrors.NotFoundError{}, func(err error) ErrorResponse {
+		return ErrorResponse{StatusCode: http.StatusNotFound, Code: appErrors.CodeNotFound, Msg: "Not found", InternalError: err}
+	})
+
+	RegisterErrorResponse(&appErrors.BadRequestError{}, func(err error) ErrorResponse {
+		e := err.(*appErrors.BadRequestError)
+		return ErrorResponse{StatusCode: http.StatusBadRequest, Code: appErrors.CodeBadRequest, Msg: e.Msg, InternalError: e.InternalError}
+	})
+
+	RegisterErrorResponse(&appErrors.ValidationError{}, func(err error) ErrorResponse {
+		e := err.(*appErrors.ValidationError)
+		return ErrorResponse{StatusCode: http.StatusBadRequest, Code: appErrors.CodeValidation, Msg: e.Msg, Fields: e.Fields}
+	})
+
+	RegisterErrorResponse(&appErrors.InvalidCredentialsError{}, func(err error) ErrorResponse {
+		return ErrorResponse{StatusCode: http.StatusBadRequest, Code: appErrors.CodeInvalidCredentials, Msg: err.Error()}
+	})
+
+	RegisterErrorResponse(&appErrors.UnauthorizedError{}, func(err error) ErrorResponse {
+		e := err.(*appErrors.UnauthorizedError)
+		return ErrorResponse{StatusCode: http.StatusUnauthorized, Code: appErrors.CodeUnauthorized, Msg: e.Msg, InternalError: e.InternalError}
+	})
+
+	RegisterErrorResponse(&appErrors.ForbiddenError{}, func(err error) ErrorResponse {
+		e := err.(*appErrors.ForbiddenError)
+		return ErrorResponse{StatusCode: http.StatusForbidden, Code: appErrors.CodeForbidden, Msg: e.Msg, InternalError: e.InternalError}
+	})
+
+	RegisterErrorResponse(&appErrors.ConflictError{}, func(err error) ErrorResponse {
+		e := err.(*appErrors.ConflictError)
+		return ErrorResponse{StatusCode: http.StatusPreconditionFailed, Code: appErrors.CodeConflict, Msg: e.Msg, InternalError: e.InternalError}
+	})
+
+	RegisterErrorResponse(&appErrors.TooManyRequestsError{}, func(err error) ErrorResponse {
+		e := err.(*appErrors.TooManyRequestsError)
+		return ErrorResponse{
+			StatusCode: http.StatusTooManyRequests,
+			Code:       appErrors.CodeTooManyRequests,
+			Msg:        e.Msg,
+			Headers:    map[string]string{"Retry-After": strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds())))},
+		}
+	})
+}
+
+// getErrorResponse returns the response of the error, which is an internal error when its
+// type isn't registered
+func getErrorResponse(err error) ErrorResponse {
+	if f, ok := errorResponses[reflect.TypeOf(err)]; ok {
+		return f(err)
+	}
+
+	return ErrorResponse{StatusCode: http.StatusInternalServerError, Code: appErrors.CodeInternal, Msg: "Internal error", InternalError: err}
+}
+
+// problemDetails is the body of the error responses, which follows RFC 7807
+type problemDetails struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Code      string                 `json:"code"`
+	Detail    string                 `json:"detail"`
+	RequestID string                 `json:"requestId"`
+	Fields    []appErrors.FieldError `json:"fields,omitempty"`
+}
+
+const problemContentType = "application/problem+json"
@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"net"
 	"net/http"
-	"strconv"
 	"strings"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
@@ -18,7 +18,31 @@ type Handler struct {
 	HandlerFunc
 	ServiceProvider services.ServiceProvider
 	RequireAuth     bool
-	RequireAdmin    bool
+	Permissions     Permissions
+	// TrustProxyHeaders makes the client ip be read from the X-Forwarded-For header, which
+	// can only be trusted when the app is behind a proxy which sets it
+	TrustProxyHeaders bool
+	// AllowPasswordChange lets the users who must change their password use the handler,
+	// which is forbidden for them in the other ones
+	AllowPasswordChange bool
+	// RequestMetrics counts the requests, which aren't counted without it
+	RequestMetrics services.RequestMetrics
+}
+
+// AnyMethod is the key of the permission required by the methods without their own permission
+const AnyMethod = "*"
+
+// Permissions contains the permission the user needs for each http method
+type Permissions map[string]string
+
+// required returns the permission needed for the method, which is empty when the user
+// only needs to be authenticated
+func (p Permissions) required(method string) string {
+	if permission, ok := p[method]; ok {
+		return permission
+	}
+
+	return p[AnyMethod]
 }
 
 type handlerResult interface {
@@ -42,6 +66,12 @@ func (r okResult) IsError() bool {
 	return false
 }
 
+// okResultWithHeaders is an okResult which also sets some response headers
+type okResultWithHeaders struct {
+	okResult
+	headers map[string]string
+}
+
 // HandlerFunc is the type for the handler functions
 type HandlerFunc func(*http.Request, services.ServiceProvider) handlerResult
 
@@ -49,28 +79,62 @@ type contextKey string
 
 const reqContextUserKey contextKey = "userID"
 const reqContextRequestKey contextKey = "requestID"
+const reqContextAPIKeyKey contextKey = "apiKey"
+const reqContextClientIPKey contextKey = "clientIP"
+
+// apiKeyScheme is the scheme of the authorization header used to send an api key
+const apiKeyScheme = "ApiKey "
 
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var jwtInfo *models.JwtClaimsInfo
 
 	r = h.addRequestIDToContext(r)
+	w.Header().Set(requestIDHeader, h.getRequestIDFromContext(r))
+	r = h.addClientIPToContext(r)
+
+	if h.RequestMetrics != nil {
+		h.RequestMetrics.CountRequest()
+	}
 
 	if h.RequireAuth {
-		token, err := h.getAuthToken(r)
+		token, isAPIKey, err := h.getAuthToken(r)
 		if err != nil {
-			h.writeErrorResponse(r, w, http.StatusUnauthorized, err.Error(), nil)
+			h.writeErrorResponse(r, w, err)
 			return
 		}
 
 		authSrv := h.ServiceProvider.GetAuthService()
-		jwtInfo, err = authSrv.ParseToken(token)
+		if isAPIKey {
+			jwtInfo, err = authSrv.ParseAPIKey(token)
+			r = h.addAPIKeyAuthToContext(r)
+		} else {
+			jwtInfo, err = authSrv.ParseToken(token)
+		}
+		if _, isUnexpected := err.(*appErrors.UnexpectedError); isUnexpected {
+			h.writeErrorResponse(r, w, err)
+			return
+		}
 		if err != nil {
-			h.writeErrorResponse(r, w, http.StatusUnauthorized, "Invalid auth token", err)
+			h.writeErrorResponse(r, w, &appErrors.UnauthorizedError{Msg: "Invalid auth token", InternalError: err})
 			return
 		}
 
-		if h.RequireAdmin && !jwtInfo.IsAdmin {
-			h.writeErrorResponse(r, w, http.StatusForbidden, "Access forbidden", err)
+		err = h.checkUser(jwtInfo.UserID)
+		if _, isUnexpected := err.(*appErrors.UnexpectedError); isUnexpected {
+			h.writeErrorResponse(r, w, err)
+			return
+		}
+		if _, isForbidden := err.(*appErrors.ForbiddenError); isForbidden {
+			h.writeErrorResponse(r, w, err)
+			return
+		}
+		if err != nil {
+			h.writeErrorResponse(r, w, &appErrors.UnauthorizedError{Msg: "Invalid auth token", InternalError: err})
+			return
+		}
+
+		if p := h.Permissions.required(r.Method); p != "" && !models.HasPermission(jwtInfo.Permissions, p) {
+			h.writeErrorResponse(r, w, &appErrors.ForbiddenError{Msg: "Access forbidden"})
 			return
 		}
 	}
@@ -88,33 +152,66 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if res.IsError() {
 		errorRes, _ := res.(errorResult)
-		err := errorRes.err
-		if unexErr, ok := err.(*appErrors.UnexpectedError); ok {
-			h.writeErrorResponse(r, w, http.StatusInternalServerError, unexErr.Error(), unexErr.InternalError)
-		} else if unauthErr, ok := err.(*appErrors.UnauthorizedError); ok {
-			h.writeErrorResponse(r, w, http.StatusUnauthorized, unauthErr.Error(), unauthErr.InternalError)
-		} else if notFoundErr, ok := err.(*appErrors.NotFoundError); ok {
-			h.writeErrorResponse(r, w, http.StatusNotFound, notFoundErr.Error(), nil)
-		} else if badRequestErr, ok := err.(*appErrors.BadRequestError); ok {
-			h.writeErrorResponse(r, w, http.StatusBadRequest, badRequestErr.Error(), badRequestErr.InternalError)
-		} else {
-			h.writeErrorResponse(r, w, http.StatusInternalServerError, "Internal error", err)
-		}
+		h.writeErrorResponse(r, w, errorRes.err)
 	} else {
+		if headersRes, ok := res.(okResultWithHeaders); ok {
+			for k, v := range headersRes.headers {
+				w.Header().Set(k, v)
+			}
+			res = headersRes.okResult
+		}
 		okRes, _ := res.(okResult)
 		h.writeOkResponse(r, w, okRes.statusCode, okRes.content)
 	}
 }
 
-// writeErrorResponse is used when and endpoind responds with an error
-func (h Handler) writeErrorResponse(r *http.Request, w http.ResponseWriter, statusCode int, msg string, internalError error) {
+// checkUser returns an error when the user of the token has been removed or disabled, or
+// when it must change its password and the handler doesn't allow it
+func (h Handler) checkUser(userID string) error {
+	u := models.User{}
+	if err := h.ServiceProvider.GetUsersService().GetUserByID(userID, &u); err != nil {
+		return err
+	}
+
+	if !u.IsActive {
+		return &appErrors.UnauthorizedError{Msg: "The user is disabled"}
+	}
+
+	if u.MustChangePassword && !h.AllowPasswordChange {
+		return &appErrors.ForbiddenError{Msg: "The password must be changed"}
+	}
+
+	return nil
+}
+
+// writeErrorResponse is used when and endpoind responds with an error. The response is a
+// problem details json with the response registered for the type of the error.
+func (h Handler) writeErrorResponse(r *http.Request, w http.ResponseWriter, err error) {
+	res := getErrorResponse(err)
+
 	requestID := h.getRequestIDFromContext(r)
-	if internalError != nil {
-		log.Printf("[%v] %v %v (%v)", requestID, statusCode, msg, internalError)
+	if res.InternalError != nil {
+		log.Printf("[%v] %v %v (%v)", requestID, res.StatusCode, res.Msg, res.InternalError)
+	} else if len(res.Fields) > 0 {
+		log.Printf("[%v] %v %v %v", requestID, res.StatusCode, res.Msg, res.Fields)
 	} else {
-		log.Printf("[%v] %v %v", requestID, statusCode, msg)
+		log.Printf("[%v] %v %v", requestID, res.StatusCode, res.Msg)
+	}
+
+	for k, v := range res.Headers {
+		w.Header().Set(k, v)
 	}
-	http.Error(w, msg, statusCode)
+	w.Header().Set("content-type", problemContentType)
+	w.WriteHeader(res.StatusCode)
+	json.NewEncoder(w).Encode(problemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(res.StatusCode),
+		Status:    res.StatusCode,
+		Code:      res.Code,
+		Detail:    res.Msg,
+		RequestID: requestID,
+		Fields:    res.Fields,
+	})
 }
 
 // writeOkResponse is used when and endpoind does not respond with an error
@@ -132,20 +229,26 @@ func (h Handler) writeOkResponse(r *http.Request, w http.ResponseWriter, statusC
 	}
 }
 
-func (h Handler) getAuthToken(r *http.Request) (string, error) {
+// getAuthToken returns the token of the authorization header, which is a jwt token with the
+// Bearer scheme or an api key with the ApiKey one
+func (h Handler) getAuthToken(r *http.Request) (string, bool, error) {
 	authHeader := r.Header.Get("Authorization")
 
 	if len(authHeader) == 0 {
-		return "", &appErrors.UnauthorizedError{Msg: "No authorization header", InternalError: nil}
+		return "", false, &appErrors.UnauthorizedError{Msg: "No authorization header", InternalError: nil}
+	}
+
+	if strings.HasPrefix(authHeader, apiKeyScheme) {
+		return authHeader[len(apiKeyScheme):], true, nil
 	}
 
 	authHeaderParts := strings.Split(authHeader, "Bearer ")
 
 	if len(authHeaderParts) != 2 {
-		return "", &appErrors.UnauthorizedError{Msg: "Invalid authorization header", InternalError: nil}
+		return "", false, &appErrors.UnauthorizedError{Msg: "Invalid authorization header", InternalError: nil}
 	}
 
-	return authHeaderParts[1], nil
+	return authHeaderParts[1], false, nil
 }
 
 func (h Handler) addUserIDToContext(userID string, r *http.Request) *http.Request {
@@ -155,22 +258,42 @@ func (h Handler) addUserIDToContext(userID string, r *http.Request) *http.Reques
 	return r.WithContext(ctx)
 }
 
-func (h Handler) addRequestIDToContext(r *http.Request) *http.Request {
-	s := h.ServiceProvider.GetCountersService()
+// addAPIKeyAuthToContext marks the request as authenticated with an api key
+func (h Handler) addAPIKeyAuthToContext(r *http.Request) *http.Request {
+	ctx := context.WithValue(r.Context(), reqContextAPIKeyKey, true)
 
-	s.IncrementCounter("requests")
+	return r.WithContext(ctx)
+}
 
-	v, err := s.GetCounterValue("requests")
+// addClientIPToContext adds the ip address of the client, which is the last one added to
+// the X-Forwarded-For header by the proxy when the proxy headers are trusted
+func (h Handler) addClientIPToContext(r *http.Request) *http.Request {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
 
-	if err == nil {
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, reqContextRequestKey, strconv.Itoa(v))
+	if forwarded := r.Header.Get("X-Forwarded-For"); h.TrustProxyHeaders && forwarded != "" {
+		parts := strings.Split(forwarded, ",")
+		ip = strings.TrimSpace(parts[len(parts)-1])
+	}
 
-		return r.WithContext(ctx)
+	ctx := context.WithValue(r.Context(), reqContextClientIPKey, ip)
+
+	return r.WithContext(ctx)
+}
+
+// addRequestIDToContext adds the id of the request, which is the one in the X-Request-ID
+// header when it is valid or a new one otherwise
+func (h Handler) addRequestIDToContext(r *http.Request) *http.Request {
+	requestID := r.Header.Get(requestIDHeader)
+	if !isValidRequestID(requestID) {
+		requestID = newRequestID()
 	}
 
-	log.Fatalf("Error incrementing requests counter. Error: %v", err)
-	return nil
+	ctx := context.WithValue(r.Context(), reqContextRequestKey, requestID)
+
+	return r.WithContext(ctx)
 }
 
 func getUserIDFromContext(r *http.Request) string {
@@ -181,6 +304,19 @@ func getUserIDFromContext(r *http.Request) string {
 	return userID
 }
 
+// isAPIKeyAuth returns true when the request has been authenticated with an api key
+func isAPIKeyAuth(r *http.Request) bool {
+	isAPIKey, _ := r.Context().Value(reqContextAPIKeyKey).(bool)
+
+	return isAPIKey
+}
+
+func getClientIPFromContext(r *http.Request) string {
+	ip, _ := r.Context().Value(reqContextClientIPKey).(string)
+
+	return ip
+}
+
 func (h Handler) getRequestIDFromContext(r *http.Request) string {
 	requestIDRaw := r.Context().Value(reqContextRequestKey)
 
@@ -1,11 +1,15 @@
 package controllers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
 	"github.com/AngelVlc/lists-backend/models"
@@ -27,11 +31,98 @@ func (s *mockedAuthService) CreateTokens(u *models.User) (map[string]string, err
 	return args.Get(0).(map[string]string), args.Error(1)
 }
 
+func (s *mockedAuthService) RefreshTokens(rtInfo *models.RefreshTokenClaimsInfo, u *models.User) (map[string]string, error) {
+	args := s.Called(rtInfo, u)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+func (s *mockedAuthService) RevokeRefreshToken(rtInfo *models.RefreshTokenClaimsInfo) error {
+	args := s.Called(rtInfo)
+	return args.Error(0)
+}
+
+func (s *mockedAuthService) RevokeUserRefreshTokens(userID string) error {
+	args := s.Called(userID)
+	return args.Error(0)
+}
+
+func (s *mockedAuthService) RevokeUserAPIKeys(userID string) error {
+	args := s.Called(userID)
+	return args.Error(0)
+}
+
+func (s *mockedAuthService) GetJwks() models.JwksDto {
+	args := s.Called()
+	return args.Get(0).(models.JwksDto)
+}
+
 func (s *mockedAuthService) ParseToken(token string) (*models.JwtClaimsInfo, error) {
 	args := s.Called(token)
 	return args.Get(0).(*models.JwtClaimsInfo), args.Error(1)
 }
 
+func (s *mockedAuthService) GetAPIKeys(userID string, r *[]models.APIKeyResultDto) error {
+	args := s.Called(userID, r)
+	return args.Error(0)
+}
+
+func (s *mockedAuthService) AddAPIKey(userID string, dto models.APIKeyDto, r *models.APIKeyResultDto) error {
+	args := s.Called(userID, dto, r)
+	return args.Error(0)
+}
+
+func (s *mockedAuthService) RemoveAPIKey(id string, userID string) error {
+	args := s.Called(id, userID)
+	return args.Error(0)
+}
+
+func (s *mockedAuthService) ParseAPIKey(key string) (*models.JwtClaimsInfo, error) {
+	args := s.Called(key)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.JwtClaimsInfo), args.Error(1)
+}
+
+func (s *mockedAuthService) EnrollTotp(userID string, r *models.TotpEnrollmentDto) error {
+	args := s.Called(userID, r)
+	return args.Error(0)
+}
+
+func (s *mockedAuthService) ConfirmTotp(userID string, code string, r *models.RecoveryCodesDto) error {
+	args := s.Called(userID, code, r)
+	return args.Error(0)
+}
+
+func (s *mockedAuthService) DisableTotp(userID string, code string) error {
+	args := s.Called(userID, code)
+	return args.Error(0)
+}
+
+func (s *mockedAuthService) CheckTotpCode(userID string, code string) error {
+	args := s.Called(userID, code)
+	return args.Error(0)
+}
+
+func (s *mockedAuthService) CreateChallengeToken(u *models.User) (map[string]string, error) {
+	args := s.Called(u)
+	res := args.Get(0)
+	if res == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+func (s *mockedAuthService) ParseChallengeToken(challengeToken string) (string, error) {
+	args := s.Called(challengeToken)
+	return args.String(0), args.Error(1)
+}
+
 func (s *mockedAuthService) ParseRefreshToken(refreshTokenString string) (*models.RefreshTokenClaimsInfo, error) {
 	args := s.Called(refreshTokenString)
 	res := args.Get(0)
@@ -41,36 +132,89 @@ func (s *mockedAuthService) ParseRefreshToken(refreshTokenString string) (*model
 	return args.Get(0).(*models.RefreshTokenClaimsInfo), args.Error(1)
 }
 
-type mockedCountersService struct {
-	mock.Mock
+type wadusError struct{}
+
+func (e *wadusError) Error() string {
+	return "wadus error"
 }
 
-func (s *mockedCountersService) AddCounter(name string) error {
-	args := s.Called(name)
-	return args.Error(0)
+type mockedRequestMetrics struct {
+	mock.Mock
 }
 
-func (s *mockedCountersService) IncrementCounter(name string) error {
-	args := s.Called(name)
-	return args.Error(0)
+func (m *mockedRequestMetrics) CountRequest() {
+	m.Called()
 }
 
-func (s *mockedCountersService) ExistsCounter(name string) bool {
-	args := s.Called(name)
-	return args.Bool(0)
+func TestHandlerRequestID(t *testing.T) {
+	mockServicePrv := new(mockedServiceProvider)
+	mockMetrics := new(mockedRequestMetrics)
+
+	var requestID string
+	f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+		requestID = (Handler{}).getRequestIDFromContext(r)
+		return okResult{nil, http.StatusOK}
+	}
+
+	handler := Handler{
+		HandlerFunc:     f,
+		ServiceProvider: mockServicePrv,
+		RequestMetrics:  mockMetrics,
+	}
+
+	t.Run("Generates a new request id and returns it in the response", func(t *testing.T) {
+		mockMetrics.On("CountRequest").Once()
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, requestID)
+		assert.Equal(t, requestID, response.Header().Get("X-Request-ID"))
+		assertHandlerExpectations(t, mockServicePrv)
+		mockMetrics.AssertExpectations(t)
+	})
+
+	t.Run("Uses the request id of the request when it is valid", func(t *testing.T) {
+		mockMetrics.On("CountRequest").Once()
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		request.Header.Set("X-Request-ID", "proxy-id.1")
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, "proxy-id.1", requestID)
+		assert.Equal(t, "proxy-id.1", response.Header().Get("X-Request-ID"))
+		mockMetrics.AssertExpectations(t)
+	})
+
+	t.Run("Replaces the request id of the request when it isn't valid", func(t *testing.T) {
+		mockMetrics.On("CountRequest").Once()
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		request.Header.Set("X-Request-ID", "id with spaces")
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.NotEqual(t, "id with spaces", requestID)
+		assert.Equal(t, requestID, response.Header().Get("X-Request-ID"))
+		mockMetrics.AssertExpectations(t)
+	})
 }
 
-func (s *mockedCountersService) GetCounterValue(name string) (int, error) {
-	args := s.Called(name)
-	return args.Int(0), args.Error(1)
+func TestIsValidRequestID(t *testing.T) {
+	assert.True(t, isValidRequestID(newRequestID()))
+	assert.True(t, isValidRequestID("Root=1-5759e988:abc_1"))
+	assert.False(t, isValidRequestID(""))
+	assert.False(t, isValidRequestID("wadus\nfake log line"))
+	assert.False(t, isValidRequestID(strings.Repeat("a", 129)))
 }
 
 func TestHandlerWithoutAuth(t *testing.T) {
 	mockServicePrv := new(mockedServiceProvider)
-	mockCountersService := new(mockedCountersService)
-	mockServicePrv.On("GetCountersService").Return(mockCountersService)
-	mockCountersService.On("IncrementCounter", "requests").Return(nil)
-	mockCountersService.On("GetCounterValue", "requests").Return(1, nil)
 
 	t.Run("Returns 200 when no error", func(t *testing.T) {
 		f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
@@ -88,7 +232,7 @@ func TestHandlerWithoutAuth(t *testing.T) {
 		handler.ServeHTTP(response, request)
 
 		assert.Equal(t, http.StatusOK, response.Result().StatusCode)
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertHandlerExpectations(t, mockServicePrv)
 	})
 
 	t.Run("Returns 200 with content when no error", func(t *testing.T) {
@@ -117,7 +261,7 @@ func TestHandlerWithoutAuth(t *testing.T) {
 		assert.Equal(t, want, got, "they should be equal")
 
 		assert.Equal(t, http.StatusOK, response.Result().StatusCode)
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertHandlerExpectations(t, mockServicePrv)
 	})
 
 	t.Run("Returns 500 when an unexpected error happens", func(t *testing.T) {
@@ -136,8 +280,8 @@ func TestHandlerWithoutAuth(t *testing.T) {
 		handler.ServeHTTP(response, request)
 
 		assert.Equal(t, http.StatusInternalServerError, response.Result().StatusCode)
-		assert.Equal(t, "error\n", string(response.Body.String()))
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertProblem(t, response, appErrors.CodeUnexpected, "error")
+		assertHandlerExpectations(t, mockServicePrv)
 	})
 
 	t.Run("Returns 404 when a not found error happens", func(t *testing.T) {
@@ -156,8 +300,8 @@ func TestHandlerWithoutAuth(t *testing.T) {
 		handler.ServeHTTP(response, request)
 
 		assert.Equal(t, http.StatusNotFound, response.Result().StatusCode)
-		assert.Equal(t, "model not found\n", string(response.Body.String()))
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertProblem(t, response, appErrors.CodeNotFound, "Not found")
+		assertHandlerExpectations(t, mockServicePrv)
 	})
 
 	t.Run("Returns 400 when a bad request error happens", func(t *testing.T) {
@@ -176,8 +320,156 @@ func TestHandlerWithoutAuth(t *testing.T) {
 		handler.ServeHTTP(response, request)
 
 		assert.Equal(t, http.StatusBadRequest, response.Result().StatusCode)
-		assert.Equal(t, "\"id\" is not a valid id\n", string(response.Body.String()))
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertProblem(t, response, appErrors.CodeBadRequest, "\"id\" is not a valid id")
+		assertHandlerExpectations(t, mockServicePrv)
+	})
+
+	t.Run("Returns 400 with the errors of the fields when a validation error happens", func(t *testing.T) {
+		f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+			return errorResult{&appErrors.ValidationError{Msg: "wadus", Fields: []appErrors.FieldError{{Field: "newPassword", Msg: "too short"}}}}
+		}
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusBadRequest, response.Result().StatusCode)
+		got := assertProblem(t, response, appErrors.CodeValidation, "wadus")
+		assert.Equal(t, []appErrors.FieldError{{Field: "newPassword", Msg: "too short"}}, got.Fields)
+		assertHandlerExpectations(t, mockServicePrv)
+	})
+
+	t.Run("Returns 403 when a forbidden error happens", func(t *testing.T) {
+		f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+			return errorResult{&appErrors.ForbiddenError{Msg: "wadus"}}
+		}
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusForbidden, response.Result().StatusCode)
+		assertProblem(t, response, appErrors.CodeForbidden, "wadus")
+		assertHandlerExpectations(t, mockServicePrv)
+	})
+
+	t.Run("Returns 412 when a conflict error happens", func(t *testing.T) {
+		f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+			return errorResult{&appErrors.ConflictError{Msg: "wadus"}}
+		}
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusPreconditionFailed, response.Result().StatusCode)
+		assertProblem(t, response, appErrors.CodeConflict, "wadus")
+		assertHandlerExpectations(t, mockServicePrv)
+	})
+
+	t.Run("Returns 400 with its own code when an invalid credentials error happens", func(t *testing.T) {
+		f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+			return errorResult{&appErrors.InvalidCredentialsError{}}
+		}
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusBadRequest, response.Result().StatusCode)
+		assertProblem(t, response, appErrors.CodeInvalidCredentials, "Invalid user name or password")
+		assertHandlerExpectations(t, mockServicePrv)
+	})
+
+	t.Run("Returns 429 with the Retry-After header when a too many requests error happens", func(t *testing.T) {
+		f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+			return errorResult{&appErrors.TooManyRequestsError{Msg: "wadus", RetryAfter: time.Millisecond * 1500}}
+		}
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusTooManyRequests, response.Result().StatusCode)
+		assert.Equal(t, "2", response.Result().Header.Get("Retry-After"))
+		assertProblem(t, response, appErrors.CodeTooManyRequests, "wadus")
+		assertHandlerExpectations(t, mockServicePrv)
+	})
+
+	t.Run("Adds the client ip to the context", func(t *testing.T) {
+		var got string
+		f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+			got = getClientIPFromContext(r)
+			return okResult{nil, http.StatusOK}
+		}
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		request.RemoteAddr = "10.0.0.1:1234"
+		request.Header.Set("X-Forwarded-For", "1.1.1.1, 2.2.2.2")
+
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+		assert.Equal(t, "10.0.0.1", got, "the proxy headers shouldn't be trusted by default")
+
+		handler.TrustProxyHeaders = true
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+		assert.Equal(t, "2.2.2.2", got, "the ip added by the proxy should be used")
+
+		assertHandlerExpectations(t, mockServicePrv)
+	})
+
+	t.Run("Returns the headers of the result", func(t *testing.T) {
+		f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+			return okResultWithHeaders{okResult{nil, http.StatusNotModified}, map[string]string{"ETag": `"1"`}}
+		}
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusNotModified, response.Result().StatusCode)
+		assert.Equal(t, `"1"`, response.Result().Header.Get("ETag"))
+		assertHandlerExpectations(t, mockServicePrv)
 	})
 
 	t.Run("Returns 401 when an unauthorized error happens", func(t *testing.T) {
@@ -196,8 +488,8 @@ func TestHandlerWithoutAuth(t *testing.T) {
 		handler.ServeHTTP(response, request)
 
 		assert.Equal(t, http.StatusUnauthorized, response.Result().StatusCode)
-		assert.Equal(t, "wadus\n", string(response.Body.String()))
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertProblem(t, response, appErrors.CodeUnauthorized, "wadus")
+		assertHandlerExpectations(t, mockServicePrv)
 	})
 
 	t.Run("Returns 500 when an unhandled error happens", func(t *testing.T) {
@@ -216,19 +508,41 @@ func TestHandlerWithoutAuth(t *testing.T) {
 		handler.ServeHTTP(response, request)
 
 		assert.Equal(t, http.StatusInternalServerError, response.Result().StatusCode)
-		assert.Equal(t, "Internal error\n", string(response.Body.String()))
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertProblem(t, response, appErrors.CodeInternal, "Internal error")
+		assertHandlerExpectations(t, mockServicePrv)
+	})
+
+	t.Run("Returns the registered response of an error type", func(t *testing.T) {
+		RegisterErrorResponse(&wadusError{}, func(err error) ErrorResponse {
+			return ErrorResponse{StatusCode: http.StatusTeapot, Code: "wadus", Msg: err.Error()}
+		})
+		defer delete(errorResponses, reflect.TypeOf(&wadusError{}))
+
+		f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+			return errorResult{&wadusError{}}
+		}
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusTeapot, response.Result().StatusCode)
+		assertProblem(t, response, "wadus", "wadus error")
+		assertHandlerExpectations(t, mockServicePrv)
 	})
 }
 
 func TestHandlerWithAuth(t *testing.T) {
 	mockServicePrv := new(mockedServiceProvider)
-	mockCountersService := new(mockedCountersService)
-	mockServicePrv.On("GetCountersService").Return(mockCountersService)
-	mockCountersService.On("IncrementCounter", "requests").Return(nil)
-	mockCountersService.On("GetCounterValue", "requests").Return(1, nil)
 
 	mockAuthSvc := new(mockedAuthService)
+	mockUsersSvc := new(mockedUsersService)
 
 	f := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
 		return errorResult{errors.New("wadus")}
@@ -247,9 +561,9 @@ func TestHandlerWithAuth(t *testing.T) {
 		handler.ServeHTTP(response, request)
 
 		assert.Equal(t, http.StatusUnauthorized, response.Result().StatusCode)
-		assert.Equal(t, "No authorization header\n", string(response.Body.String()))
+		assertProblem(t, response, appErrors.CodeUnauthorized, "No authorization header")
 
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertHandlerExpectations(t, mockServicePrv)
 		mockAuthSvc.AssertExpectations(t)
 	})
 
@@ -267,9 +581,9 @@ func TestHandlerWithAuth(t *testing.T) {
 		handler.ServeHTTP(response, request)
 
 		assert.Equal(t, http.StatusUnauthorized, response.Result().StatusCode)
-		assert.Equal(t, "Invalid authorization header\n", string(response.Body.String()))
+		assertProblem(t, response, appErrors.CodeUnauthorized, "Invalid authorization header")
 
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertHandlerExpectations(t, mockServicePrv)
 		mockAuthSvc.AssertExpectations(t)
 	})
 
@@ -291,22 +605,50 @@ func TestHandlerWithAuth(t *testing.T) {
 		handler.ServeHTTP(response, request)
 
 		assert.Equal(t, http.StatusUnauthorized, response.Result().StatusCode)
-		assert.Equal(t, "Invalid auth token\n", string(response.Body.String()))
+		assertProblem(t, response, appErrors.CodeUnauthorized, "Invalid auth token")
+
+		assertHandlerExpectations(t, mockServicePrv)
+		mockAuthSvc.AssertExpectations(t)
+	})
+
+	t.Run("Returns 401 when the user is disabled", func(t *testing.T) {
+		mockServicePrv.On("GetAuthService").Return(mockAuthSvc).Once()
+		mockServicePrv.On("GetUsersService").Return(mockUsersSvc).Once()
+
+		mockAuthSvc.On("ParseToken", "token").Return(&models.JwtClaimsInfo{UserID: "1"}, nil).Once()
+		mockUsersSvc.On("GetUserByID", "1", &models.User{}).Return(nil).Once()
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+			RequireAuth:     true,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		request.Header.Set("Authorization", "Bearer token")
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusUnauthorized, response.Result().StatusCode)
+		assertProblem(t, response, appErrors.CodeUnauthorized, "Invalid auth token")
 
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertHandlerExpectations(t, mockServicePrv)
 		mockAuthSvc.AssertExpectations(t)
+		mockUsersSvc.AssertExpectations(t)
 	})
 
-	t.Run("Returns 403 when the resource requires admin and the user is not admin", func(t *testing.T) {
+	t.Run("Returns 500 when the user can't be retrieved", func(t *testing.T) {
 		mockServicePrv.On("GetAuthService").Return(mockAuthSvc).Once()
+		mockServicePrv.On("GetUsersService").Return(mockUsersSvc).Once()
 
-		mockAuthSvc.On("ParseToken", "token").Return(&models.JwtClaimsInfo{IsAdmin: false}, nil).Once()
+		mockAuthSvc.On("ParseToken", "token").Return(&models.JwtClaimsInfo{UserID: "1"}, nil).Once()
+		mockUsersSvc.On("GetUserByID", "1", &models.User{}).Return(&appErrors.UnexpectedError{Msg: "Error retrieving from the database"}).Once()
 
 		handler := Handler{
 			HandlerFunc:     f,
 			ServiceProvider: mockServicePrv,
 			RequireAuth:     true,
-			RequireAdmin:    true,
 		}
 
 		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
@@ -315,18 +657,185 @@ func TestHandlerWithAuth(t *testing.T) {
 
 		handler.ServeHTTP(response, request)
 
+		assert.Equal(t, http.StatusInternalServerError, response.Result().StatusCode)
+
+		assertHandlerExpectations(t, mockServicePrv)
+		mockAuthSvc.AssertExpectations(t)
+		mockUsersSvc.AssertExpectations(t)
+	})
+
+	t.Run("Returns 403 when the user doesn't have the permission required by the method", func(t *testing.T) {
+		mockServicePrv.On("GetAuthService").Return(mockAuthSvc).Once()
+		mockServicePrv.On("GetUsersService").Return(mockUsersSvc).Once()
+
+		mockAuthSvc.On("ParseToken", "token").Return(&models.JwtClaimsInfo{UserID: "1", Permissions: []string{models.PermissionListsRead}}, nil).Once()
+		mockUsersSvc.On("GetUserByID", "1", &models.User{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(1).(*models.User).IsActive = true
+		})
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+			RequireAuth:     true,
+			Permissions:     Permissions{http.MethodGet: models.PermissionListsRead, AnyMethod: models.PermissionListsWrite},
+		}
+
+		request, _ := http.NewRequest(http.MethodPost, "/wadus", nil)
+		request.Header.Set("Authorization", "Bearer token")
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
 		assert.Equal(t, http.StatusForbidden, response.Result().StatusCode)
-		assert.Equal(t, "Access forbidden\n", string(response.Body.String()))
+		assertProblem(t, response, appErrors.CodeForbidden, "Access forbidden")
 
-		assertHandlerExpectations(t, mockServicePrv, mockCountersService)
+		assertHandlerExpectations(t, mockServicePrv)
 		mockAuthSvc.AssertExpectations(t)
 	})
 
+	t.Run("Returns 403 when the user must change the password", func(t *testing.T) {
+		mockServicePrv.On("GetAuthService").Return(mockAuthSvc).Once()
+		mockServicePrv.On("GetUsersService").Return(mockUsersSvc).Once()
+
+		mockAuthSvc.On("ParseToken", "token").Return(&models.JwtClaimsInfo{UserID: "1"}, nil).Once()
+		mockUsersSvc.On("GetUserByID", "1", &models.User{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(1).(*models.User).IsActive = true
+			args.Get(1).(*models.User).MustChangePassword = true
+		})
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+			RequireAuth:     true,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		request.Header.Set("Authorization", "Bearer token")
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusForbidden, response.Result().StatusCode)
+		assertProblem(t, response, appErrors.CodeForbidden, "The password must be changed")
+
+		assertHandlerExpectations(t, mockServicePrv)
+		mockAuthSvc.AssertExpectations(t)
+		mockUsersSvc.AssertExpectations(t)
+	})
+
+	t.Run("Allows the users who must change the password when the handler allows it", func(t *testing.T) {
+		mockServicePrv.On("GetAuthService").Return(mockAuthSvc).Once()
+		mockServicePrv.On("GetUsersService").Return(mockUsersSvc).Once()
+
+		mockAuthSvc.On("ParseToken", "token").Return(&models.JwtClaimsInfo{UserID: "1"}, nil).Once()
+		mockUsersSvc.On("GetUserByID", "1", &models.User{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(1).(*models.User).IsActive = true
+			args.Get(1).(*models.User).MustChangePassword = true
+		})
+
+		okFunc := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+			return okResult{nil, http.StatusOK}
+		}
+
+		handler := Handler{
+			HandlerFunc:         okFunc,
+			ServiceProvider:     mockServicePrv,
+			RequireAuth:         true,
+			AllowPasswordChange: true,
+		}
+
+		request, _ := http.NewRequest(http.MethodPut, "/me/password", nil)
+		request.Header.Set("Authorization", "Bearer token")
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusOK, response.Result().StatusCode)
+
+		assertHandlerExpectations(t, mockServicePrv)
+		mockAuthSvc.AssertExpectations(t)
+		mockUsersSvc.AssertExpectations(t)
+	})
+
+	t.Run("Returns 401 when the api key is not valid", func(t *testing.T) {
+		mockServicePrv.On("GetAuthService").Return(mockAuthSvc).Once()
+
+		mockAuthSvc.On("ParseAPIKey", "key").Return(nil, &appErrors.UnauthorizedError{Msg: "Invalid api key"}).Once()
+
+		handler := Handler{
+			HandlerFunc:     f,
+			ServiceProvider: mockServicePrv,
+			RequireAuth:     true,
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		request.Header.Set("Authorization", "ApiKey key")
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusUnauthorized, response.Result().StatusCode)
+		assertProblem(t, response, appErrors.CodeUnauthorized, "Invalid auth token")
+
+		assertHandlerExpectations(t, mockServicePrv)
+		mockAuthSvc.AssertExpectations(t)
+	})
+
+	t.Run("Accepts an api key instead of a jwt token", func(t *testing.T) {
+		mockServicePrv.On("GetAuthService").Return(mockAuthSvc).Once()
+		mockServicePrv.On("GetUsersService").Return(mockUsersSvc).Once()
+
+		mockAuthSvc.On("ParseAPIKey", "key").Return(&models.JwtClaimsInfo{UserID: "1", Permissions: []string{models.PermissionListsRead}}, nil).Once()
+		mockUsersSvc.On("GetUserByID", "1", &models.User{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(1).(*models.User).IsActive = true
+		})
+
+		apiKeyFunc := func(r *http.Request, serviceProvider services.ServiceProvider) handlerResult {
+			assert.Equal(t, "1", getUserIDFromContext(r))
+			assert.True(t, isAPIKeyAuth(r))
+			return okResult{nil, http.StatusOK}
+		}
+
+		handler := Handler{
+			HandlerFunc:     apiKeyFunc,
+			ServiceProvider: mockServicePrv,
+			RequireAuth:     true,
+			Permissions:     Permissions{http.MethodGet: models.PermissionListsRead},
+		}
+
+		request, _ := http.NewRequest(http.MethodGet, "/wadus", nil)
+		request.Header.Set("Authorization", "ApiKey key")
+		response := httptest.NewRecorder()
+
+		handler.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusOK, response.Result().StatusCode)
+
+		assertHandlerExpectations(t, mockServicePrv)
+		mockAuthSvc.AssertExpectations(t)
+		mockUsersSvc.AssertExpectations(t)
+	})
+
+}
+
+// assertProblem checks the response is a problem details json with the code and the message
+func assertProblem(t *testing.T, response *httptest.ResponseRecorder, code string, msg string) problemDetails {
+	t.Helper()
+
+	got := problemDetails{}
+	assert.Equal(t, "application/problem+json", response.Result().Header.Get("content-type"))
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&got))
+	assert.Equal(t, response.Result().StatusCode, got.Status)
+	assert.Equal(t, http.StatusText(got.Status), got.Title)
+	assert.Equal(t, code, got.Code)
+	assert.Equal(t, msg, got.Detail)
+	assert.Equal(t, response.Result().Header.Get("X-Request-ID"), got.RequestID)
+
+	return got
 }
 
-func 	assertHandlerExpectations(t *testing.T, sp *mockedServiceProvider, cs *mockedCountersService) {
+func assertHandlerExpectations(t *testing.T, sp *mockedServiceProvider) {
 	t.Helper()
 
 	sp.AssertExpectations(t)
-	cs.AssertExpectations(t)
 }
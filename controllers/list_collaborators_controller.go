@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/services"
+)
+
+const collaboratorsPath = "collaborators"
+
+// ListCollaboratorsHandler is the handler for the /lists/{listId}/collaborators endpoints
+func ListCollaboratorsHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	listID, collaboratorID, _ := getListResourceIDsFromURL(r.URL, collaboratorsPath)
+
+	switch {
+	case r.Method == http.MethodGet && collaboratorID == "":
+		return processListCollaboratorsGET(r, servicePrv, listID)
+	case r.Method == http.MethodPost && collaboratorID == "":
+		return processListCollaboratorsPOST(r, servicePrv, listID)
+	case r.Method == http.MethodPut && collaboratorID != "":
+		return processListCollaboratorPUT(r, servicePrv, listID, collaboratorID)
+	case r.Method == http.MethodDelete && collaboratorID != "":
+		return processListCollaboratorDELETE(r, servicePrv, listID, collaboratorID)
+	default:
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+}
+
+func processListCollaboratorsGET(r *http.Request, servicePrv services.ServiceProvider, listID string) handlerResult {
+	collaborators := []models.Collaborator{}
+	err := servicePrv.GetListsService().GetListCollaborators(listID, getUserIDFromContext(r), &collaborators)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{collaborators, http.StatusOK}
+}
+
+func processListCollaboratorsPOST(r *http.Request, servicePrv services.ServiceProvider, listID string) handlerResult {
+	var dto models.CollaboratorDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return errorResult{&appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}}
+	}
+
+	c := dto.ToCollaborator()
+	err := servicePrv.GetListsService().AddListCollaborator(listID, getUserIDFromContext(r), &c)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{c, http.StatusCreated}
+}
+
+func processListCollaboratorPUT(r *http.Request, servicePrv services.ServiceProvider, listID string, collaboratorID string) handlerResult {
+	var dto models.CollaboratorDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return errorResult{&appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}}
+	}
+
+	err := servicePrv.GetListsService().UpdateListCollaborator(listID, collaboratorID, getUserIDFromContext(r), dto.Role)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{nil, http.StatusNoContent}
+}
+
+func processListCollaboratorDELETE(r *http.Request, servicePrv services.ServiceProvider, listID string, collaboratorID string) handlerResult {
+	err := servicePrv.GetListsService().RemoveListCollaborator(listID, collaboratorID, getUserIDFromContext(r))
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{nil, http.StatusNoContent}
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListCollaborators(t *testing.T) {
+	testListsSrv := new(mockedListsService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	userID := "userId"
+
+	t.Run("GET returns the collaborators of the list", func(t *testing.T) {
+		data := models.SampleListSlice()[0].Collaborators
+
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("GetListCollaborators", "l1", userID, &[]models.Collaborator{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(2).(*[]models.Collaborator) = data
+		})
+
+		request, _ := http.NewRequest(http.MethodGet, "/lists/l1/collaborators", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{data, http.StatusOK}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("POST invites the user", func(t *testing.T) {
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("AddListCollaborator", "l1", userID, &models.Collaborator{UserName: "bob", Role: models.RoleEditor}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(2).(*models.Collaborator).UserID = "u2"
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/lists/l1/collaborators", strings.NewReader(`{"userName":"bob","role":"editor"}`))
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		want := okResult{models.Collaborator{UserID: "u2", UserName: "bob", Role: models.RoleEditor}, http.StatusCreated}
+		assert.Equal(t, want, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("PUT changes the role of the collaborator", func(t *testing.T) {
+		err := errors.New("wadus")
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("UpdateListCollaborator", "l1", "u2", userID, models.RoleViewer).Return(err).Once()
+
+		request, _ := http.NewRequest(http.MethodPut, "/lists/l1/collaborators/u2", strings.NewReader(`{"role":"viewer"}`))
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, errorResult{err}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("DELETE removes the collaborator", func(t *testing.T) {
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("RemoveListCollaborator", "l1", "u2", userID).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodDelete, "/lists/l1/collaborators/u2", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("returns an okResult with a 405 status when the method is not allowed for the url", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodDelete, "/lists/l1/collaborators", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusMethodNotAllowed}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+}
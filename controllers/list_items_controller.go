@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/services"
+)
+
+// clearCompletedPath is the path used to clear the done items of a list, which
+// can't be an item id
+const clearCompletedPath = "clear-completed"
+
+// ListItemsHandler is the handler for the /lists/{listId}/items endpoints
+func ListItemsHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	listID, itemID, action, _ := getListItemIDsFromURL(r.URL)
+
+	switch {
+	case action == "toggle" && r.Method == http.MethodPost:
+		return processListItemTogglePOST(r, servicePrv, listID, itemID)
+	case action != "":
+		return okResult{nil, http.StatusNotFound}
+	case itemID == clearCompletedPath && r.Method == http.MethodPost:
+		return processListItemsClearCompletedPOST(r, servicePrv, listID)
+	case r.Method == http.MethodGet && itemID == "":
+		return processListItemsGET(r, servicePrv, listID)
+	case r.Method == http.MethodGet:
+		return processListItemGET(r, servicePrv, listID, itemID)
+	case r.Method == http.MethodPost && itemID == "":
+		return processListItemsPOST(r, servicePrv, listID)
+	case r.Method == http.MethodPut && itemID != "":
+		return processListItemPUT(r, servicePrv, listID, itemID)
+	case r.Method == http.MethodPatch && itemID != "":
+		return processListItemPATCH(r, servicePrv, listID, itemID)
+	case r.Method == http.MethodDelete && itemID != "":
+		return processListItemDELETE(r, servicePrv, listID, itemID)
+	default:
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+}
+
+func processListItemsGET(r *http.Request, servicePrv services.ServiceProvider, listID string) handlerResult {
+	items := []models.Item{}
+	err := servicePrv.GetListsService().GetUserListItems(listID, getUserIDFromContext(r), &items)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{items, http.StatusOK}
+}
+
+func processListItemGET(r *http.Request, servicePrv services.ServiceProvider, listID string, itemID string) handlerResult {
+	item := models.Item{}
+	err := servicePrv.GetListsService().GetUserListItem(listID, itemID, getUserIDFromContext(r), &item)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{item, http.StatusOK}
+}
+
+func processListItemsPOST(r *http.Request, servicePrv services.ServiceProvider, listID string) handlerResult {
+	item, err := parseItemBody(r)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	id, err := servicePrv.GetListsService().AddUserListItem(listID, getUserIDFromContext(r), &item)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{id, http.StatusCreated}
+}
+
+func processListItemPUT(r *http.Request, servicePrv services.ServiceProvider, listID string, itemID string) handlerResult {
+	item, err := parseItemBody(r)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	err = servicePrv.GetListsService().UpdateUserListItem(listID, itemID, getUserIDFromContext(r), &item)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{item, http.StatusOK}
+}
+
+func processListItemPATCH(r *http.Request, servicePrv services.ServiceProvider, listID string, itemID string) handlerResult {
+	var patch models.ItemPatchDto
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return errorResult{&appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}}
+	}
+
+	item := models.Item{}
+	err := servicePrv.GetListsService().PatchUserListItem(listID, itemID, getUserIDFromContext(r), patch, &item)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{item, http.StatusOK}
+}
+
+func processListItemTogglePOST(r *http.Request, servicePrv services.ServiceProvider, listID string, itemID string) handlerResult {
+	item := models.Item{}
+	err := servicePrv.GetListsService().ToggleUserListItem(listID, itemID, getUserIDFromContext(r), &item)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{item, http.StatusOK}
+}
+
+// processListItemsClearCompletedPOST removes the done items, or archives them when the mode
+// query parameter is archive
+func processListItemsClearCompletedPOST(r *http.Request, servicePrv services.ServiceProvider, listID string) handlerResult {
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "remove" && mode != "archive" {
+		return errorResult{&appErrors.BadRequestError{Msg: "Invalid mode", InternalError: nil}}
+	}
+
+	err := servicePrv.GetListsService().ClearCompletedUserListItems(listID, getUserIDFromContext(r), mode == "archive")
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{nil, http.StatusNoContent}
+}
+
+func processListItemDELETE(r *http.Request, servicePrv services.ServiceProvider, listID string, itemID string) handlerResult {
+	err := servicePrv.GetListsService().RemoveUserListItem(listID, itemID, getUserIDFromContext(r))
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{nil, http.StatusNoContent}
+}
+
+// getListItemIDsFromURL returns the ids and the action of a /lists/{listId}/items[/{itemId}[/{action}]]
+// url and false when the url is not an items one
+func getListItemIDsFromURL(u *url.URL) (string, string, string, bool) {
+	if !strings.HasPrefix(u.Path, "/lists/") {
+		return "", "", "", false
+	}
+
+	parts := strings.Split(u.Path[len("/lists/"):], "/")
+	if len(parts) < 2 || len(parts) > 4 || parts[1] != "items" {
+		return "", "", "", false
+	}
+
+	parts = append(parts, "", "")
+
+	return parts[0], parts[2], parts[3], true
+}
+
+func parseItemBody(r *http.Request) (models.Item, error) {
+	decoder := json.NewDecoder(r.Body)
+	var dto models.ItemDto
+	err := decoder.Decode(&dto)
+	if err != nil {
+		return models.Item{}, &appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}
+	}
+
+	return dto.ToItem(), nil
+}
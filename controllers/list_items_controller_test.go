@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListItems(t *testing.T) {
+	testListsSrv := new(mockedListsService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	userID := "userId"
+
+	t.Run("GET returns the items of the list", func(t *testing.T) {
+		data := models.SampleListSlice()[0].Items
+
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("GetUserListItems", "l1", userID, &[]models.Item{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(2).(*[]models.Item) = data
+		})
+
+		request, _ := http.NewRequest(http.MethodGet, "/lists/l1/items", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{data, http.StatusOK}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("GET WITH AN ID returns an errorResult with the service error when the query fails", func(t *testing.T) {
+		err := errors.New("wadus")
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("GetUserListItem", "l1", "i1", userID, &models.Item{}).Return(err).Once()
+
+		request, _ := http.NewRequest(http.MethodGet, "/lists/l1/items/i1", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, errorResult{err}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("POST adds the item", func(t *testing.T) {
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("AddUserListItem", "l1", userID, &models.Item{Title: "t", Description: "d"}).Return("i1", nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPost, "/lists/l1/items", strings.NewReader(`{"title":"t","description":"d"}`))
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{"i1", http.StatusCreated}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("PUT with invalid body should return an errorResult with a BadRequestError", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPut, "/lists/l1/items/i1", strings.NewReader("wadus"))
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.Equal(t, true, isErrorResult, "should be an error result")
+		assert.IsType(t, &appErrors.BadRequestError{}, errorRes.err)
+		assert.Equal(t, "Invalid body", errorRes.err.Error())
+
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("PUT replaces the item", func(t *testing.T) {
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("UpdateUserListItem", "l1", "i1", userID, &models.Item{Title: "t"}).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPut, "/lists/l1/items/i1", strings.NewReader(`{"title":"t"}`))
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{models.Item{Title: "t"}, http.StatusOK}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("PATCH only sends the fields in the body", func(t *testing.T) {
+		title := "t"
+		updated := models.Item{ID: "i1", Title: title, Description: "d"}
+
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("PatchUserListItem", "l1", "i1", userID, models.ItemPatchDto{Title: &title}, &models.Item{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(4).(*models.Item) = updated
+		})
+
+		request, _ := http.NewRequest(http.MethodPatch, "/lists/l1/items/i1", strings.NewReader(`{"title":"t"}`))
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{updated, http.StatusOK}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("DELETE removes the item", func(t *testing.T) {
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("RemoveUserListItem", "l1", "i1", userID).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodDelete, "/lists/l1/items/i1", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("POST toggle returns the toggled item", func(t *testing.T) {
+		toggled := models.Item{ID: "i1", Done: true}
+
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("ToggleUserListItem", "l1", "i1", userID, &models.Item{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(3).(*models.Item) = toggled
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/lists/l1/items/i1/toggle", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{toggled, http.StatusOK}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("POST clear-completed archives the done items", func(t *testing.T) {
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("ClearCompletedUserListItems", "l1", userID, true).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPost, "/lists/l1/items/clear-completed?mode=archive", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("POST clear-completed returns a bad request error when the mode is not valid", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/lists/l1/items/clear-completed?mode=wadus", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.Equal(t, true, isErrorResult, "should be an error result")
+		assert.IsType(t, &appErrors.BadRequestError{}, errorRes.err)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("returns an okResult with a 405 status when the method is not allowed for the url", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodDelete, "/lists/l1/items", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusMethodNotAllowed}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+}
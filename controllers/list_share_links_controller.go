@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/services"
+)
+
+const shareLinksPath = "share-links"
+
+// ListShareLinksHandler is the handler for the /lists/{listId}/share-links endpoints
+func ListShareLinksHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	listID, token, _ := getListResourceIDsFromURL(r.URL, shareLinksPath)
+
+	switch {
+	case r.Method == http.MethodGet && token == "":
+		return processListShareLinksGET(r, servicePrv, listID)
+	case r.Method == http.MethodPost && token == "":
+		return processListShareLinksPOST(r, servicePrv, listID)
+	case r.Method == http.MethodDelete && token != "":
+		return processListShareLinkDELETE(r, servicePrv, listID, token)
+	default:
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+}
+
+func processListShareLinksGET(r *http.Request, servicePrv services.ServiceProvider, listID string) handlerResult {
+	links := []models.ShareLink{}
+	err := servicePrv.GetListsService().GetListShareLinks(listID, getUserIDFromContext(r), &links)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{links, http.StatusOK}
+}
+
+// processListShareLinksPOST creates a share link. The body is optional and only contains
+// the expiry time.
+func processListShareLinksPOST(r *http.Request, servicePrv services.ServiceProvider, listID string) handlerResult {
+	var dto models.ShareLinkDto
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+			return errorResult{&appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}}
+		}
+	}
+
+	link := models.ShareLink{ExpiresAt: dto.ExpiresAt}
+	err := servicePrv.GetListsService().AddListShareLink(listID, getUserIDFromContext(r), &link)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{link, http.StatusCreated}
+}
+
+func processListShareLinkDELETE(r *http.Request, servicePrv services.ServiceProvider, listID string, token string) handlerResult {
+	err := servicePrv.GetListsService().RemoveListShareLink(listID, token, getUserIDFromContext(r))
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{nil, http.StatusNoContent}
+}
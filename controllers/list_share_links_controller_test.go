@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListShareLinks(t *testing.T) {
+	testListsSrv := new(mockedListsService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	userID := "userId"
+
+	t.Run("POST without body creates a link which never expires", func(t *testing.T) {
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("AddListShareLink", "l1", userID, &models.ShareLink{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(2).(*models.ShareLink).Token = "token"
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/lists/l1/share-links", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{models.ShareLink{Token: "token"}, http.StatusCreated}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("POST creates a link with the expiry time", func(t *testing.T) {
+		expires := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("AddListShareLink", "l1", userID, &models.ShareLink{ExpiresAt: expires}).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPost, "/lists/l1/share-links", strings.NewReader(`{"expiresAt":"2020-05-01T10:00:00Z"}`))
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{models.ShareLink{ExpiresAt: expires}, http.StatusCreated}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("DELETE revokes the link", func(t *testing.T) {
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("RemoveListShareLink", "l1", "token", userID).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodDelete, "/lists/l1/share-links/token", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("GET /shared returns the list of the token", func(t *testing.T) {
+		data := models.SharedListDto{Name: "list", Items: []models.Item{}}
+
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("GetSharedList", "token", &models.SharedListDto{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(1).(*models.SharedListDto) = data
+		})
+
+		request, _ := http.NewRequest(http.MethodGet, "/shared/token", nil)
+
+		got := SharedListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{data, http.StatusOK}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("GET /shared returns the not found error of the service", func(t *testing.T) {
+		err := &appErrors.NotFoundError{Model: "shared list"}
+
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("GetSharedList", "wadus", &models.SharedListDto{}).Return(err).Once()
+
+		request, _ := http.NewRequest(http.MethodGet, "/shared/wadus", nil)
+
+		got := SharedListsHandler(request, testSrvProvider)
+
+		assert.Equal(t, errorResult{err}, got)
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+}
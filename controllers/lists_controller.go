@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
 	"github.com/AngelVlc/lists-backend/models"
@@ -12,6 +14,18 @@ import (
 
 // ListsHandler is the handler for the lists endpoints
 func ListsHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if _, _, _, isItemsURL := getListItemIDsFromURL(r.URL); isItemsURL {
+		return ListItemsHandler(r, servicePrv)
+	}
+
+	if _, _, isCollaboratorsURL := getListResourceIDsFromURL(r.URL, collaboratorsPath); isCollaboratorsURL {
+		return ListCollaboratorsHandler(r, servicePrv)
+	}
+
+	if _, _, isShareLinksURL := getListResourceIDsFromURL(r.URL, shareLinksPath); isShareLinksURL {
+		return ListShareLinksHandler(r, servicePrv)
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		return processListsGET(r, servicePrv)
@@ -32,8 +46,12 @@ func processListsGET(r *http.Request, servicePrv services.ServiceProvider) handl
 
 	listSrv := servicePrv.GetListsService()
 	if listID == "" {
-		r := []models.GetListsResultDto{}
-		err := listSrv.GetUserLists(userID, &r)
+		opts, err := parseListsOptions(r.URL)
+		if err != nil {
+			return errorResult{err}
+		}
+		r := models.GetListsPageDto{}
+		err = listSrv.GetUserLists(userID, opts, &r)
 		if err != nil {
 			return errorResult{err}
 		}
@@ -44,7 +62,12 @@ func processListsGET(r *http.Request, servicePrv services.ServiceProvider) handl
 	if err != nil {
 		return errorResult{err}
 	}
-	return okResult{l, http.StatusOK}
+
+	headers := map[string]string{"ETag": listETag(l.Version)}
+	if matchesETag(r.Header.Get("If-None-Match"), l.Version) {
+		return okResultWithHeaders{okResult{nil, http.StatusNotModified}, headers}
+	}
+	return okResultWithHeaders{okResult{l, http.StatusOK}, headers}
 }
 
 func processListsPOST(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
@@ -71,20 +94,29 @@ func processListsPUT(r *http.Request, servicePrv services.ServiceProvider) handl
 	if err != nil {
 		return errorResult{err}
 	}
+	version, err := parseIfMatch(r)
+	if err != nil {
+		return errorResult{err}
+	}
 	listSrv := servicePrv.GetListsService()
-	err = listSrv.UpdateUserList(listID, userID, &l)
+	err = listSrv.UpdateUserList(listID, userID, &l, version)
 	if err != nil {
 		return errorResult{err}
 	}
-	return okResult{l, http.StatusOK}
+	return okResultWithHeaders{okResult{l, http.StatusOK}, map[string]string{"ETag": listETag(l.Version)}}
 }
 
 func processListsDELETE(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
 	listID := getListIDFromURL(r.URL)
 	userID := getUserIDFromContext(r)
 
+	version, err := parseIfMatch(r)
+	if err != nil {
+		return errorResult{err}
+	}
+
 	listSrv := servicePrv.GetListsService()
-	err := listSrv.RemoveUserList(listID, userID)
+	err = listSrv.RemoveUserList(listID, userID, version)
 	if err != nil {
 		return errorResult{err}
 	}
@@ -101,6 +133,83 @@ func getListIDFromURL(u *url.URL) string {
 	return listID
 }
 
+// getListResourceIDsFromURL returns the ids of a /lists/{listId}/{resource}[/{id}] url and
+// false when the url is not one of the given resource
+func getListResourceIDsFromURL(u *url.URL, resource string) (string, string, bool) {
+	if !strings.HasPrefix(u.Path, "/lists/") {
+		return "", "", false
+	}
+
+	parts := strings.Split(u.Path[len("/lists/"):], "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] != resource {
+		return "", "", false
+	}
+
+	parts = append(parts, "")
+
+	return parts[0], parts[2], true
+}
+
+// parseListsOptions reads the pagination options from the query string. The sort
+// parameter is the field name, prefixed with - to sort in descending order.
+func parseListsOptions(u *url.URL) (models.GetListsOptions, error) {
+	q := u.Query()
+
+	opts := models.GetListsOptions{
+		Cursor: q.Get("cursor"),
+		Name:   q.Get("name"),
+		SortBy: q.Get("sort"),
+	}
+
+	if strings.HasPrefix(opts.SortBy, "-") {
+		opts.SortBy = opts.SortBy[1:]
+		opts.Descending = true
+	}
+
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return models.GetListsOptions{}, &appErrors.BadRequestError{Msg: "Invalid limit", InternalError: err}
+		}
+		opts.Limit = limit
+	}
+
+	return opts, nil
+}
+
+// listETag returns the entity tag for the given list version
+func listETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// matchesETag returns true when the If-None-Match header contains the tag of the version
+func matchesETag(header string, version int) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == listETag(version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseIfMatch returns the list version required by the If-Match header. It returns
+// nil when the header is missing or any version is accepted.
+func parseIfMatch(r *http.Request) (*int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || header != listETag(version) {
+		return nil, &appErrors.ConflictError{Msg: "Invalid If-Match header", InternalError: err}
+	}
+
+	return &version, nil
+}
+
 func parseListBody(r *http.Request) (models.List, error) {
 	decoder := json.NewDecoder(r.Body)
 	var dto models.ListDto
@@ -25,13 +25,13 @@ func (us *mockedListsService) AddUserList(userID string, l *models.List) (string
 	return args.String(0), args.Error(1)
 }
 
-func (us *mockedListsService) RemoveUserList(id string, userID string) error {
-	args := us.Called(id, userID)
+func (us *mockedListsService) RemoveUserList(id string, userID string, version *int) error {
+	args := us.Called(id, userID, version)
 	return args.Error(0)
 }
 
-func (us *mockedListsService) UpdateUserList(id string, userID string, l *models.List) error {
-	args := us.Called(id, userID, l)
+func (us *mockedListsService) UpdateUserList(id string, userID string, l *models.List, version *int) error {
+	args := us.Called(id, userID, l, version)
 	return args.Error(0)
 }
 
@@ -40,8 +40,88 @@ func (us *mockedListsService) GetSingleUserList(i string, u string, l *models.Li
 	return args.Error(0)
 }
 
-func (us *mockedListsService) GetUserLists(u string, r *[]models.GetListsResultDto) error {
-	args := us.Called(u, r)
+func (us *mockedListsService) GetUserLists(u string, opts models.GetListsOptions, r *models.GetListsPageDto) error {
+	args := us.Called(u, opts, r)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) GetUserListItems(l string, u string, items *[]models.Item) error {
+	args := us.Called(l, u, items)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) GetUserListItem(l string, i string, u string, item *models.Item) error {
+	args := us.Called(l, i, u, item)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) AddUserListItem(l string, u string, item *models.Item) (string, error) {
+	args := us.Called(l, u, item)
+	return args.String(0), args.Error(1)
+}
+
+func (us *mockedListsService) UpdateUserListItem(l string, i string, u string, item *models.Item) error {
+	args := us.Called(l, i, u, item)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) PatchUserListItem(l string, i string, u string, patch models.ItemPatchDto, item *models.Item) error {
+	args := us.Called(l, i, u, patch, item)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) ToggleUserListItem(l string, i string, u string, item *models.Item) error {
+	args := us.Called(l, i, u, item)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) GetListCollaborators(l string, u string, collaborators *[]models.Collaborator) error {
+	args := us.Called(l, u, collaborators)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) AddListCollaborator(l string, u string, c *models.Collaborator) error {
+	args := us.Called(l, u, c)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) UpdateListCollaborator(l string, c string, u string, role string) error {
+	args := us.Called(l, c, u, role)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) RemoveListCollaborator(l string, c string, u string) error {
+	args := us.Called(l, c, u)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) GetListShareLinks(l string, u string, links *[]models.ShareLink) error {
+	args := us.Called(l, u, links)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) AddListShareLink(l string, u string, link *models.ShareLink) error {
+	args := us.Called(l, u, link)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) RemoveListShareLink(l string, token string, u string) error {
+	args := us.Called(l, token, u)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) GetSharedList(token string, r *models.SharedListDto) error {
+	args := us.Called(token, r)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) ClearCompletedUserListItems(l string, u string, archive bool) error {
+	args := us.Called(l, u, archive)
+	return args.Error(0)
+}
+
+func (us *mockedListsService) RemoveUserListItem(l string, i string, u string) error {
+	args := us.Called(l, i, u)
 	return args.Error(0)
 }
 
@@ -55,11 +135,11 @@ func TestLists(t *testing.T) {
 	}
 
 	t.Run("GET returns an okResult when there is no error", func(t *testing.T) {
-		data := models.SampleGetListsResultDto()
+		data := models.GetListsPageDto{Lists: models.SampleGetListsResultDto(), NextCursor: "next", Total: 10}
 
 		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
-		testListsSrv.On("GetUserLists", jwtInfo.UserID, &[]models.GetListsResultDto{}).Return(nil).Once().Run(func(args mock.Arguments) {
-			arg := args.Get(1).(*[]models.GetListsResultDto)
+		testListsSrv.On("GetUserLists", jwtInfo.UserID, models.GetListsOptions{}, &models.GetListsPageDto{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			arg := args.Get(2).(*models.GetListsPageDto)
 			*arg = data
 		})
 
@@ -77,7 +157,7 @@ func TestLists(t *testing.T) {
 	t.Run("GET returns an errorResult with the service error when the query fails", func(t *testing.T) {
 		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
 		err := errors.New("wadus")
-		testListsSrv.On("GetUserLists", jwtInfo.UserID, &[]models.GetListsResultDto{}).Return(err).Once()
+		testListsSrv.On("GetUserLists", jwtInfo.UserID, models.GetListsOptions{}, &models.GetListsPageDto{}).Return(err).Once()
 
 		request, _ := http.NewRequest(http.MethodGet, "/lists", nil)
 		request = addUserIDToContext(jwtInfo.UserID, request)
@@ -91,6 +171,39 @@ func TestLists(t *testing.T) {
 		assertListsExpectations(t, testSrvProvider, testListsSrv)
 	})
 
+	t.Run("GET sends the pagination options to the service", func(t *testing.T) {
+		opts := models.GetListsOptions{Limit: 5, Cursor: "abc", SortBy: "createdAt", Descending: true, Name: "shop"}
+
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("GetUserLists", jwtInfo.UserID, opts, &models.GetListsPageDto{}).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodGet, "/lists?limit=5&cursor=abc&sort=-createdAt&name=shop", nil)
+		request = addUserIDToContext(jwtInfo.UserID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		want := okResult{models.GetListsPageDto{}, http.StatusOK}
+
+		assert.Equal(t, want, got, "should be equal")
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("GET returns an errorResult with a bad request error when the limit is not valid", func(t *testing.T) {
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+
+		request, _ := http.NewRequest(http.MethodGet, "/lists?limit=wadus", nil)
+		request = addUserIDToContext(jwtInfo.UserID, request)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		errorResult, isErrorResult := got.(errorResult)
+		assert.Equal(t, true, isErrorResult, "should be an error result")
+
+		assert.IsType(t, &appErrors.BadRequestError{}, errorResult.err)
+		assert.Equal(t, "Invalid limit", errorResult.err.Error())
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
 	t.Run("GET WITH AN ID returns an errorResult with the service error when the query fails", func(t *testing.T) {
 		id := bson.NewObjectId().Hex()
 		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
@@ -123,7 +236,29 @@ func TestLists(t *testing.T) {
 
 		got := ListsHandler(request, testSrvProvider)
 
-		want := okResult{data, http.StatusOK}
+		want := okResultWithHeaders{okResult{data, http.StatusOK}, map[string]string{"ETag": `"0"`}}
+
+		assert.Equal(t, want, got, "should be equal")
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
+	t.Run("GET WITH AN ID returns a 304 when the list has not changed", func(t *testing.T) {
+		data := models.SampleListSlice()[0]
+		data.Version = 3
+
+		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
+		testListsSrv.On("GetSingleUserList", data.ID, jwtInfo.UserID, &models.List{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			arg := args.Get(2).(*models.List)
+			*arg = data
+		})
+
+		request, _ := http.NewRequest(http.MethodGet, "/lists/"+data.ID, nil)
+		request = addUserIDToContext(jwtInfo.UserID, request)
+		request.Header.Set("If-None-Match", `"2", "3"`)
+
+		got := ListsHandler(request, testSrvProvider)
+
+		want := okResultWithHeaders{okResult{nil, http.StatusNotModified}, map[string]string{"ETag": `"3"`}}
 
 		assert.Equal(t, want, got, "should be equal")
 		assertListsExpectations(t, testSrvProvider, testListsSrv)
@@ -193,7 +328,7 @@ func TestLists(t *testing.T) {
 
 		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
 		err := errors.New("wadus")
-		testListsSrv.On("RemoveUserList", id, jwtInfo.UserID).Return(err).Once()
+		testListsSrv.On("RemoveUserList", id, jwtInfo.UserID, (*int)(nil)).Return(err).Once()
 
 		request, _ := http.NewRequest(http.MethodDelete, "/lists/"+id, nil)
 		request = addUserIDToContext(jwtInfo.UserID, request)
@@ -210,7 +345,7 @@ func TestLists(t *testing.T) {
 		id := bson.NewObjectId().Hex()
 
 		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
-		testListsSrv.On("RemoveUserList", id, jwtInfo.UserID).Return(nil).Once()
+		testListsSrv.On("RemoveUserList", id, jwtInfo.UserID, (*int)(nil)).Return(nil).Once()
 
 		request, _ := http.NewRequest(http.MethodDelete, "/lists/"+id, nil)
 		request = addUserIDToContext(jwtInfo.UserID, request)
@@ -244,7 +379,7 @@ func TestLists(t *testing.T) {
 
 		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
 		err := errors.New("wadus")
-		testListsSrv.On("UpdateUserList", id, jwtInfo.UserID, mock.Anything).Return(err).Once()
+		testListsSrv.On("UpdateUserList", id, jwtInfo.UserID, mock.Anything, (*int)(nil)).Return(err).Once()
 
 		body, _ := json.Marshal(listDto)
 		request, _ := http.NewRequest(http.MethodPut, "/lists/"+id, bytes.NewBuffer(body))
@@ -267,20 +402,40 @@ func TestLists(t *testing.T) {
 		id := bson.NewObjectId().Hex()
 
 		testSrvProvider.On("GetListsService").Return(testListsSrv).Once()
-		testListsSrv.On("UpdateUserList", id, jwtInfo.UserID, &data).Return(nil).Once()
+		version := 2
+		testListsSrv.On("UpdateUserList", id, jwtInfo.UserID, &data, &version).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(2).(*models.List).Version = 3
+		})
 
 		body, _ := json.Marshal(listDto)
 		request, _ := http.NewRequest(http.MethodPut, "/lists/"+id, bytes.NewBuffer(body))
 		request = addUserIDToContext(jwtInfo.UserID, request)
 		request.Header.Set("Content-type", "application/json")
+		request.Header.Set("If-Match", `"2"`)
 
 		got := ListsHandler(request, testSrvProvider)
-		want := okResult{data, http.StatusOK}
+		data.Version = 3
+		want := okResultWithHeaders{okResult{data, http.StatusOK}, map[string]string{"ETag": `"3"`}}
 
 		assert.Equal(t, want, got, "should be equal")
 		assertListsExpectations(t, testSrvProvider, testListsSrv)
 	})
 
+	t.Run("DELETE with an invalid If-Match header should return an errorResult with a ConflictError", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodDelete, "/lists/"+bson.NewObjectId().Hex(), nil)
+		request = addUserIDToContext(jwtInfo.UserID, request)
+		request.Header.Set("If-Match", "wadus")
+
+		got := ListsHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.Equal(t, true, isErrorResult, "should be an error result")
+		assert.IsType(t, &appErrors.ConflictError{}, errorRes.err)
+		assert.Equal(t, "Invalid If-Match header", errorRes.err.Error())
+
+		assertListsExpectations(t, testSrvProvider, testListsSrv)
+	})
+
 	t.Run("returns and okResult with a 405 status when the method is not GET, POST, PUT or DELETE", func(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodPatch, "/lists", nil)
 		request = addUserIDToContext(jwtInfo.UserID, request)
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/services"
+)
+
+const meAPIKeysPath = "/me/api-keys"
+
+// MeAPIKeysHandler is the handler for the /me/api-keys endpoints. The api keys can't be
+// managed with an api key, so a key can't be used to get a key with more permissions.
+func MeAPIKeysHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if isAPIKeyAuth(r) {
+		return errorResult{&appErrors.ForbiddenError{Msg: "The api keys can't be managed with an api key"}}
+	}
+
+	var keyID string
+	if len(r.URL.Path) > len(meAPIKeysPath) {
+		keyID = r.URL.Path[len(meAPIKeysPath+"/"):]
+	}
+
+	switch {
+	case r.Method == http.MethodGet && keyID == "":
+		return processMeAPIKeysGET(r, servicePrv)
+	case r.Method == http.MethodPost && keyID == "":
+		return processMeAPIKeysPOST(r, servicePrv)
+	case r.Method == http.MethodDelete && keyID != "":
+		return processMeAPIKeyDELETE(r, servicePrv, keyID)
+	default:
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+}
+
+func processMeAPIKeysGET(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	keys := []models.APIKeyResultDto{}
+	err := servicePrv.GetAuthService().GetAPIKeys(getUserIDFromContext(r), &keys)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{keys, http.StatusOK}
+}
+
+// processMeAPIKeysPOST creates an api key. The response is the only one which contains
+// the key.
+func processMeAPIKeysPOST(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	var dto models.APIKeyDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return errorResult{&appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}}
+	}
+
+	key := models.APIKeyResultDto{}
+	err := servicePrv.GetAuthService().AddAPIKey(getUserIDFromContext(r), dto, &key)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{key, http.StatusCreated}
+}
+
+func processMeAPIKeyDELETE(r *http.Request, servicePrv services.ServiceProvider, keyID string) handlerResult {
+	err := servicePrv.GetAuthService().RemoveAPIKey(keyID, getUserIDFromContext(r))
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{nil, http.StatusNoContent}
+}
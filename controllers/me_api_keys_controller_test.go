@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMeAPIKeysHandler(t *testing.T) {
+	testAuthSrv := new(mockedAuthService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	userID := "userId"
+
+	t.Run("GET returns the api keys of the user", func(t *testing.T) {
+		keys := []models.APIKeyResultDto{{ID: "id", Name: "ci", Prefix: "lak_prefix"}}
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("GetAPIKeys", userID, &[]models.APIKeyResultDto{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(1).(*[]models.APIKeyResultDto) = keys
+		})
+
+		request, _ := http.NewRequest(http.MethodGet, "/me/api-keys", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{keys, http.StatusOK}, got)
+		assertMeAPIKeysExpectations(t, testSrvProvider, testAuthSrv)
+	})
+
+	t.Run("POST creates an api key", func(t *testing.T) {
+		dto := models.APIKeyDto{Name: "ci", Scopes: []string{models.PermissionListsRead}}
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("AddAPIKey", userID, dto, &models.APIKeyResultDto{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(2).(*models.APIKeyResultDto).Key = "lak_key"
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/me/api-keys", strings.NewReader(`{"name":"ci","scopes":["lists:read"]}`))
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{models.APIKeyResultDto{Key: "lak_key"}, http.StatusCreated}, got)
+		assertMeAPIKeysExpectations(t, testSrvProvider, testAuthSrv)
+	})
+
+	t.Run("POST returns an errorResult with a BadRequestError when the body isn't valid", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/me/api-keys", strings.NewReader("wadus"))
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.True(t, isErrorResult)
+		assert.IsType(t, &appErrors.BadRequestError{}, errorRes.err)
+		assertMeAPIKeysExpectations(t, testSrvProvider, testAuthSrv)
+	})
+
+	t.Run("DELETE revokes an api key", func(t *testing.T) {
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("RemoveAPIKey", "id", userID).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodDelete, "/me/api-keys/id", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertMeAPIKeysExpectations(t, testSrvProvider, testAuthSrv)
+	})
+
+	t.Run("returns an errorResult with a ForbiddenError when the request uses an api key", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/me/api-keys", strings.NewReader(`{"name":"ci","scopes":["lists:write"]}`))
+		request = addUserIDToContext(userID, request)
+		request = Handler{}.addAPIKeyAuthToContext(request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.True(t, isErrorResult)
+		assert.IsType(t, &appErrors.ForbiddenError{}, errorRes.err)
+		assertMeAPIKeysExpectations(t, testSrvProvider, testAuthSrv)
+	})
+
+	t.Run("returns an okResult with a 405 status when the method isn't allowed", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodDelete, "/me/api-keys", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusMethodNotAllowed}, got)
+		assertMeAPIKeysExpectations(t, testSrvProvider, testAuthSrv)
+	})
+}
+
+func assertMeAPIKeysExpectations(t *testing.T, sp *mockedServiceProvider, as *mockedAuthService) {
+	t.Helper()
+
+	sp.AssertExpectations(t)
+	as.AssertExpectations(t)
+}
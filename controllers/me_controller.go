@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/services"
+)
+
+// MeHandler is the handler for the /me endpoints, which manage the account of the
+// authenticated user
+func MeHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	switch {
+	case r.URL.Path == "/me/password" && r.Method == http.MethodPut:
+		return processMePasswordPUT(r, servicePrv)
+	case r.URL.Path == meAPIKeysPath || strings.HasPrefix(r.URL.Path, meAPIKeysPath+"/"):
+		return MeAPIKeysHandler(r, servicePrv)
+	case r.URL.Path == meTotpPath || strings.HasPrefix(r.URL.Path, meTotpPath+"/"):
+		return MeTotpHandler(r, servicePrv)
+	case r.URL.Path != "/me":
+		return okResult{nil, http.StatusNotFound}
+	case r.Method == http.MethodGet:
+		return processMeGET(r, servicePrv)
+	case r.Method == http.MethodPatch:
+		return processMePATCH(r, servicePrv)
+	case r.Method == http.MethodDelete:
+		return processMeDELETE(r, servicePrv)
+	default:
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+}
+
+func processMeGET(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	u := models.User{}
+	err := servicePrv.GetUsersService().GetUserByID(getUserIDFromContext(r), &u)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{u.ToResultDto(), http.StatusOK}
+}
+
+// processMePATCH changes the user name, the only field the users can change by themselves.
+// It can't be done with an api key, as the keys only give access to the lists.
+func processMePATCH(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if isAPIKeyAuth(r) {
+		return errorResult{&appErrors.ForbiddenError{Msg: "The account can't be changed with an api key"}}
+	}
+
+	var patch models.UserPatchDto
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return errorResult{&appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}}
+	}
+
+	userID := getUserIDFromContext(r)
+	u := models.GetUsersResultDto{}
+	err := servicePrv.GetUsersService().UpdateUser(userID, userID, models.UserPatchDto{UserName: patch.UserName}, &u)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{u, http.StatusOK}
+}
+
+// processMePasswordPUT changes the password of the user and revokes its refresh tokens and
+// its api keys, so a leaked password can't keep being used through them
+func processMePasswordPUT(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if isAPIKeyAuth(r) {
+		return errorResult{&appErrors.ForbiddenError{Msg: "The password can't be changed with an api key"}}
+	}
+
+	var dto models.ChangePasswordDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return errorResult{&appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}}
+	}
+
+	userID := getUserIDFromContext(r)
+
+	err := servicePrv.GetUsersService().ChangePassword(userID, dto)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	authSrv := servicePrv.GetAuthService()
+
+	if err := authSrv.RevokeUserRefreshTokens(userID); err != nil {
+		return errorResult{err}
+	}
+
+	if err := authSrv.RevokeUserAPIKeys(userID); err != nil {
+		return errorResult{err}
+	}
+
+	return okResult{nil, http.StatusNoContent}
+}
+
+// processMeDELETE removes the account of the user, which can't be done with an api key
+func processMeDELETE(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if isAPIKeyAuth(r) {
+		return errorResult{&appErrors.ForbiddenError{Msg: "The account can't be removed with an api key"}}
+	}
+
+	err := servicePrv.GetUsersService().RemoveAccount(getUserIDFromContext(r))
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{nil, http.StatusNoContent}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMeHandler(t *testing.T) {
+	testUsersSrv := new(mockedUsersService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	userID := "userId"
+
+	t.Run("GET returns the current user", func(t *testing.T) {
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testUsersSrv.On("GetUserByID", userID, &models.User{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(1).(*models.User) = models.User{ID: userID, UserName: "user", PasswordHash: "hash"}
+		})
+
+		request, _ := http.NewRequest(http.MethodGet, "/me", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{models.GetUsersResultDto{ID: userID, UserName: "user"}, http.StatusOK}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+	})
+
+	t.Run("PATCH only changes the user name", func(t *testing.T) {
+		name := "new"
+
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testUsersSrv.On("UpdateUser", userID, userID, models.UserPatchDto{UserName: &name}, &models.GetUsersResultDto{}).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPatch, "/me", strings.NewReader(`{"userName":"new","role":"admin"}`))
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{models.GetUsersResultDto{}, http.StatusOK}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+	})
+
+	t.Run("PUT /me/password changes the password and revokes the refresh tokens and the api keys", func(t *testing.T) {
+		dto := models.ChangePasswordDto{OldPassword: "old", NewPassword: "new", ConfirmNewPassword: "new"}
+		testAuthSrv := new(mockedAuthService)
+
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testUsersSrv.On("ChangePassword", userID, dto).Return(nil).Once()
+		testAuthSrv.On("RevokeUserRefreshTokens", userID).Return(nil).Once()
+		testAuthSrv.On("RevokeUserAPIKeys", userID).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPut, "/me/password", strings.NewReader(`{"oldPassword":"old","newPassword":"new","confirmNewPassword":"new"}`))
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+		testAuthSrv.AssertExpectations(t)
+	})
+
+	t.Run("DELETE removes the account", func(t *testing.T) {
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testUsersSrv.On("RemoveAccount", userID).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodDelete, "/me", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+	})
+
+	t.Run("returns an errorResult with a ForbiddenError when the account is changed with an api key", func(t *testing.T) {
+		for _, req := range []struct{ method, path, body string }{
+			{http.MethodPatch, "/me", `{"userName":"new"}`},
+			{http.MethodPut, "/me/password", `{"oldPassword":"old","newPassword":"new","confirmNewPassword":"new"}`},
+			{http.MethodDelete, "/me", ""},
+		} {
+			request, _ := http.NewRequest(req.method, req.path, strings.NewReader(req.body))
+			request = Handler{}.addAPIKeyAuthToContext(addUserIDToContext(userID, request))
+
+			got := MeHandler(request, testSrvProvider)
+
+			errorRes, isErrorResult := got.(errorResult)
+			assert.True(t, isErrorResult, req.method)
+			assert.IsType(t, &appErrors.ForbiddenError{}, errorRes.err, req.method)
+		}
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+	})
+
+	t.Run("returns an okResult with a 404 status when the url does not exist", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/me/wadus", nil)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNotFound}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+	})
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/services"
+)
+
+const meTotpPath = "/me/totp"
+
+// MeTotpHandler is the handler for the /me/totp endpoints, which manage the two-factor
+// authentication of the user. It can't be managed with an api key.
+func MeTotpHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if isAPIKeyAuth(r) {
+		return errorResult{&appErrors.ForbiddenError{Msg: "The two-factor authentication can't be managed with an api key"}}
+	}
+
+	switch {
+	case r.URL.Path == meTotpPath+"/confirm" && r.Method == http.MethodPost:
+		return processMeTotpConfirmPOST(r, servicePrv)
+	case r.URL.Path != meTotpPath:
+		return okResult{nil, http.StatusNotFound}
+	case r.Method == http.MethodPost:
+		return processMeTotpPOST(r, servicePrv)
+	case r.Method == http.MethodDelete:
+		return processMeTotpDELETE(r, servicePrv)
+	default:
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+}
+
+// processMeTotpPOST starts the enrollment, returning the new secret and its otpauth uri
+func processMeTotpPOST(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	dto := models.TotpEnrollmentDto{}
+	err := servicePrv.GetAuthService().EnrollTotp(getUserIDFromContext(r), &dto)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{dto, http.StatusCreated}
+}
+
+// processMeTotpConfirmPOST enables the two-factor authentication with a code of the new
+// secret, returning the recovery codes
+func processMeTotpConfirmPOST(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	dto, err := parseTotpCodeBody(r)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	codes := models.RecoveryCodesDto{}
+	err = servicePrv.GetAuthService().ConfirmTotp(getUserIDFromContext(r), dto.Code, &codes)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{codes, http.StatusOK}
+}
+
+func processMeTotpDELETE(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	dto, err := parseTotpCodeBody(r)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	err = servicePrv.GetAuthService().DisableTotp(getUserIDFromContext(r), dto.Code)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{nil, http.StatusNoContent}
+}
+
+func parseTotpCodeBody(r *http.Request) (models.TotpCodeDto, error) {
+	var dto models.TotpCodeDto
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		return models.TotpCodeDto{}, &appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}
+	}
+
+	if dto.Code == "" {
+		return models.TotpCodeDto{}, &appErrors.BadRequestError{Msg: "Code is mandatory", InternalError: nil}
+	}
+
+	return dto, nil
+}
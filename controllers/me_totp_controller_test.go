@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMeTotpHandler(t *testing.T) {
+	testAuthSrv := new(mockedAuthService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	userID := "userId"
+
+	t.Run("POST starts the enrollment", func(t *testing.T) {
+		dto := models.TotpEnrollmentDto{Secret: "SECRET", URI: "otpauth://totp/lists:user?secret=SECRET"}
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("EnrollTotp", userID, &models.TotpEnrollmentDto{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(1).(*models.TotpEnrollmentDto) = dto
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/me/totp", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{dto, http.StatusCreated}, got)
+		assertMeTotpExpectations(t, testSrvProvider, testAuthSrv)
+	})
+
+	t.Run("POST /me/totp/confirm enables the two-factor authentication", func(t *testing.T) {
+		codes := models.RecoveryCodesDto{RecoveryCodes: []string{"aaaaaaaa-bbbbbbbb"}}
+
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("ConfirmTotp", userID, "123456", &models.RecoveryCodesDto{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(2).(*models.RecoveryCodesDto) = codes
+		})
+
+		request, _ := http.NewRequest(http.MethodPost, "/me/totp/confirm", strings.NewReader(`{"code":"123456"}`))
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{codes, http.StatusOK}, got)
+		assertMeTotpExpectations(t, testSrvProvider, testAuthSrv)
+	})
+
+	t.Run("POST /me/totp/confirm returns an errorResult with a BadRequestError without code", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/me/totp/confirm", strings.NewReader(`{}`))
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.True(t, isErrorResult)
+		assert.IsType(t, &appErrors.BadRequestError{}, errorRes.err)
+		assert.Equal(t, "Code is mandatory", errorRes.err.Error())
+		assertMeTotpExpectations(t, testSrvProvider, testAuthSrv)
+	})
+
+	t.Run("DELETE disables the two-factor authentication", func(t *testing.T) {
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testAuthSrv.On("DisableTotp", userID, "123456").Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodDelete, "/me/totp", strings.NewReader(`{"code":"123456"}`))
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertMeTotpExpectations(t, testSrvProvider, testAuthSrv)
+	})
+
+	t.Run("returns an errorResult with a ForbiddenError when the request uses an api key", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/me/totp", nil)
+		request = addUserIDToContext(userID, request)
+		request = Handler{}.addAPIKeyAuthToContext(request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.True(t, isErrorResult)
+		assert.IsType(t, &appErrors.ForbiddenError{}, errorRes.err)
+		assertMeTotpExpectations(t, testSrvProvider, testAuthSrv)
+	})
+
+	t.Run("returns an okResult with a 405 status when the method isn't allowed", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/me/totp", nil)
+		request = addUserIDToContext(userID, request)
+
+		got := MeHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusMethodNotAllowed}, got)
+		assertMeTotpExpectations(t, testSrvProvider, testAuthSrv)
+	})
+}
+
+func assertMeTotpExpectations(t *testing.T, sp *mockedServiceProvider, as *mockedAuthService) {
+	t.Helper()
+
+	sp.AssertExpectations(t)
+	as.AssertExpectations(t)
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/services"
+)
+
+// ForgotPasswordHandler is the handler for the auth/password/forgot endpoint, which sends a
+// password reset mail. It answers the same when the email address isn't used.
+func ForgotPasswordHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if r.Method != http.MethodPost {
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+
+	decoder := json.NewDecoder(r.Body)
+
+	var dto models.ForgotPasswordDto
+	if err := decoder.Decode(&dto); err != nil {
+		return errorResult{&appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}}
+	}
+
+	if len(dto.Email) == 0 {
+		return errorResult{&appErrors.BadRequestError{Msg: "Email is mandatory", InternalError: nil}}
+	}
+
+	err := servicePrv.GetPasswordResetService().RequestPasswordReset(dto.Email)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	return okResult{nil, http.StatusNoContent}
+}
+
+// ResetPasswordHandler is the handler for the auth/password/reset endpoint, which sets the
+// new password with the token of the password reset mail. The refresh tokens and the api
+// keys of the user are revoked, so the sessions and the keys created with the old password
+// stop working.
+func ResetPasswordHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if r.Method != http.MethodPost {
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+
+	dto, err := parseResetPasswordBody(r)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	userID, err := servicePrv.GetPasswordResetService().ResetPassword(dto)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	authSrv := servicePrv.GetAuthService()
+
+	err = authSrv.RevokeUserRefreshTokens(userID)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	err = authSrv.RevokeUserAPIKeys(userID)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	return okResult{nil, http.StatusNoContent}
+}
+
+func parseResetPasswordBody(r *http.Request) (models.ResetPasswordDto, error) {
+	decoder := json.NewDecoder(r.Body)
+
+	var dto models.ResetPasswordDto
+	err := decoder.Decode(&dto)
+	if err != nil {
+		return models.ResetPasswordDto{}, &appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}
+	}
+
+	if len(dto.Token) == 0 {
+		return models.ResetPasswordDto{}, &appErrors.BadRequestError{Msg: "Token is mandatory", InternalError: nil}
+	}
+
+	if len(dto.NewPassword) == 0 {
+		return models.ResetPasswordDto{}, &appErrors.BadRequestError{Msg: "NewPassword is mandatory", InternalError: nil}
+	}
+
+	return dto, nil
+}
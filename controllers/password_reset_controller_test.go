@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockedPasswordResetService struct {
+	mock.Mock
+}
+
+func (s *mockedPasswordResetService) RequestPasswordReset(email string) error {
+	args := s.Called(email)
+	return args.Error(0)
+}
+
+func (s *mockedPasswordResetService) ResetPassword(dto models.ResetPasswordDto) (string, error) {
+	args := s.Called(dto)
+	return args.String(0), args.Error(1)
+}
+
+func TestForgotPasswordHandler(t *testing.T) {
+	testResetSrv := new(mockedPasswordResetService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	t.Run("POST sends the password reset mail", func(t *testing.T) {
+		testSrvProvider.On("GetPasswordResetService").Return(testResetSrv).Once()
+		testResetSrv.On("RequestPasswordReset", "wadus@example.com").Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/password/forgot", strings.NewReader(`{"email":"wadus@example.com"}`))
+
+		got := ForgotPasswordHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		testSrvProvider.AssertExpectations(t)
+		testResetSrv.AssertExpectations(t)
+	})
+
+	t.Run("POST returns an errorResult with a BadRequestError without email", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/password/forgot", strings.NewReader(`{}`))
+
+		got := ForgotPasswordHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.True(t, isErrorResult)
+		assert.Equal(t, "Email is mandatory", errorRes.err.Error())
+		testSrvProvider.AssertExpectations(t)
+	})
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	testResetSrv := new(mockedPasswordResetService)
+	testAuthSrv := new(mockedAuthService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	dto := models.ResetPasswordDto{Token: "theToken", NewPassword: "pass", ConfirmNewPassword: "pass"}
+	body := `{"token":"theToken","newPassword":"pass","confirmNewPassword":"pass"}`
+
+	t.Run("POST sets the new password and revokes the refresh tokens and the api keys of the user", func(t *testing.T) {
+		testSrvProvider.On("GetPasswordResetService").Return(testResetSrv).Once()
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testResetSrv.On("ResetPassword", dto).Return("userId", nil).Once()
+		testAuthSrv.On("RevokeUserRefreshTokens", "userId").Return(nil).Once()
+		testAuthSrv.On("RevokeUserAPIKeys", "userId").Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/password/reset", strings.NewReader(body))
+
+		got := ResetPasswordHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		testSrvProvider.AssertExpectations(t)
+		testResetSrv.AssertExpectations(t)
+		testAuthSrv.AssertExpectations(t)
+	})
+
+	t.Run("POST returns the errorResult of the service without revoking the refresh tokens", func(t *testing.T) {
+		testSrvProvider.On("GetPasswordResetService").Return(testResetSrv).Once()
+		testResetSrv.On("ResetPassword", dto).Return("", &appErrors.BadRequestError{Msg: "Invalid or expired token"}).Once()
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/password/reset", strings.NewReader(body))
+
+		got := ResetPasswordHandler(request, testSrvProvider)
+
+		assert.Equal(t, errorResult{&appErrors.BadRequestError{Msg: "Invalid or expired token"}}, got)
+		testSrvProvider.AssertExpectations(t)
+		testResetSrv.AssertExpectations(t)
+		testAuthSrv.AssertExpectations(t)
+	})
+
+	t.Run("POST returns an errorResult with a BadRequestError without token", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/password/reset", strings.NewReader(`{"newPassword":"pass"}`))
+
+		got := ResetPasswordHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.True(t, isErrorResult)
+		assert.Equal(t, "Token is mandatory", errorRes.err.Error())
+		testSrvProvider.AssertExpectations(t)
+	})
+}
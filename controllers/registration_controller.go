@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/services"
+)
+
+// RegisterHandler is the handler for the auth/register endpoint, which lets the users sign
+// up themselves when the registration is enabled
+func RegisterHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if r.Method != http.MethodPost {
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+
+	dto, err := parseRegistrationBody(r)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	err = servicePrv.GetRegistrationService().Register(dto)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	return okResult{nil, http.StatusCreated}
+}
+
+// VerifyEmailHandler is the handler for the auth/register/verify endpoint, which activates
+// the users with the token of the verification mail
+func VerifyEmailHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if r.Method != http.MethodPost {
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+
+	decoder := json.NewDecoder(r.Body)
+
+	var dto models.VerifyEmailDto
+	if err := decoder.Decode(&dto); err != nil {
+		return errorResult{&appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}}
+	}
+
+	if len(dto.Token) == 0 {
+		return errorResult{&appErrors.BadRequestError{Msg: "Token is mandatory", InternalError: nil}}
+	}
+
+	err := servicePrv.GetRegistrationService().VerifyEmail(dto.Token)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	return okResult{nil, http.StatusNoContent}
+}
+
+func parseRegistrationBody(r *http.Request) (models.RegistrationDto, error) {
+	decoder := json.NewDecoder(r.Body)
+
+	var dto models.RegistrationDto
+	err := decoder.Decode(&dto)
+	if err != nil {
+		return models.RegistrationDto{}, &appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}
+	}
+
+	if len(dto.UserName) == 0 {
+		return models.RegistrationDto{}, &appErrors.BadRequestError{Msg: "UserName is mandatory", InternalError: nil}
+	}
+
+	if len(dto.Email) == 0 {
+		return models.RegistrationDto{}, &appErrors.BadRequestError{Msg: "Email is mandatory", InternalError: nil}
+	}
+
+	if len(dto.NewPassword) == 0 {
+		return models.RegistrationDto{}, &appErrors.BadRequestError{Msg: "NewPassword is mandatory", InternalError: nil}
+	}
+
+	return dto, nil
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockedRegistrationService struct {
+	mock.Mock
+}
+
+func (s *mockedRegistrationService) Register(dto models.RegistrationDto) error {
+	args := s.Called(dto)
+	return args.Error(0)
+}
+
+func (s *mockedRegistrationService) VerifyEmail(token string) error {
+	args := s.Called(token)
+	return args.Error(0)
+}
+
+func TestRegisterHandler(t *testing.T) {
+	testRegistrationSrv := new(mockedRegistrationService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	t.Run("POST registers the user", func(t *testing.T) {
+		dto := models.RegistrationDto{UserName: "wadus", Email: "wadus@example.com", NewPassword: "pass", ConfirmNewPassword: "pass"}
+
+		testSrvProvider.On("GetRegistrationService").Return(testRegistrationSrv).Once()
+		testRegistrationSrv.On("Register", dto).Return(nil).Once()
+
+		body := `{"userName":"wadus","email":"wadus@example.com","newPassword":"pass","confirmNewPassword":"pass"}`
+		request, _ := http.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body))
+
+		got := RegisterHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusCreated}, got)
+		assertRegistrationExpectations(t, testSrvProvider, testRegistrationSrv)
+	})
+
+	t.Run("POST returns an errorResult with a BadRequestError without email", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(`{"userName":"wadus","newPassword":"pass"}`))
+
+		got := RegisterHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.True(t, isErrorResult)
+		assert.IsType(t, &appErrors.BadRequestError{}, errorRes.err)
+		assert.Equal(t, "Email is mandatory", errorRes.err.Error())
+		assertRegistrationExpectations(t, testSrvProvider, testRegistrationSrv)
+	})
+
+	t.Run("POST returns the errorResult of the service", func(t *testing.T) {
+		testSrvProvider.On("GetRegistrationService").Return(testRegistrationSrv).Once()
+		testRegistrationSrv.On("Register", mock.Anything).Return(&appErrors.ForbiddenError{Msg: "The registration is disabled"}).Once()
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(`{"userName":"wadus","email":"wadus@example.com","newPassword":"pass"}`))
+
+		got := RegisterHandler(request, testSrvProvider)
+
+		assert.Equal(t, errorResult{&appErrors.ForbiddenError{Msg: "The registration is disabled"}}, got)
+		assertRegistrationExpectations(t, testSrvProvider, testRegistrationSrv)
+	})
+
+	t.Run("returns an okResult with a 405 status when the method isn't allowed", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/auth/register", nil)
+
+		got := RegisterHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusMethodNotAllowed}, got)
+	})
+}
+
+func TestVerifyEmailHandler(t *testing.T) {
+	testRegistrationSrv := new(mockedRegistrationService)
+
+	testSrvProvider := new(mockedServiceProvider)
+
+	t.Run("POST verifies the email address", func(t *testing.T) {
+		testSrvProvider.On("GetRegistrationService").Return(testRegistrationSrv).Once()
+		testRegistrationSrv.On("VerifyEmail", "theToken").Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPost, "/auth/register/verify", strings.NewReader(`{"token":"theToken"}`))
+
+		got := VerifyEmailHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertRegistrationExpectations(t, testSrvProvider, testRegistrationSrv)
+	})
+
+	t.Run("POST returns an errorResult with a BadRequestError without token", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/register/verify", strings.NewReader(`{}`))
+
+		got := VerifyEmailHandler(request, testSrvProvider)
+
+		errorRes, isErrorResult := got.(errorResult)
+		assert.True(t, isErrorResult)
+		assert.Equal(t, "Token is mandatory", errorRes.err.Error())
+		assertRegistrationExpectations(t, testSrvProvider, testRegistrationSrv)
+	})
+}
+
+func assertRegistrationExpectations(t *testing.T, sp *mockedServiceProvider, rs *mockedRegistrationService) {
+	t.Helper()
+
+	sp.AssertExpectations(t)
+	rs.AssertExpectations(t)
+}
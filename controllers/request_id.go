@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestIDHeader is the header with the id of the request. The one sent by the client or a
+// proxy is used when it is valid and the response always contains it.
+const requestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// requestIDSymbols are the symbols allowed in the request ids besides the letters and the
+// digits, which include the ones of the trace ids of the usual proxies
+const requestIDSymbols = "-_.:="
+
+// newRequestID returns a random version 4 uuid
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// the time is unique enough to follow the request in the logs
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// isValidRequestID returns true when the request id can be written in the logs and the
+// response headers as it is
+func isValidRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !isDigit && !strings.ContainsRune(requestIDSymbols, c) {
+			return false
+		}
+	}
+
+	return true
+}
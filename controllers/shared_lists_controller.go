@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/services"
+)
+
+// SharedListsHandler is the handler for the /shared/{token} endpoint, which doesn't
+// require authentication
+func SharedListsHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	if r.Method != http.MethodGet {
+		return okResult{nil, http.StatusMethodNotAllowed}
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, "/shared/")
+	if token == "" || strings.Contains(token, "/") {
+		return okResult{nil, http.StatusNotFound}
+	}
+
+	l := models.SharedListDto{}
+	err := servicePrv.GetListsService().GetSharedList(token, &l)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{l, http.StatusOK}
+}
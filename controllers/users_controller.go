@@ -3,6 +3,8 @@ package controllers
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
 	"github.com/AngelVlc/lists-backend/models"
@@ -11,14 +13,49 @@ import (
 
 // UsersHandler is the handler for the users endpoints
 func UsersHandler(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
-	switch r.Method {
-	case http.MethodPost:
+	userID := getUserIDFromURL(r.URL)
+
+	switch {
+	case r.Method == http.MethodGet && userID == "":
+		return processUsersGET(r, servicePrv)
+	case r.Method == http.MethodGet:
+		return processUserGET(r, servicePrv, userID)
+	case r.Method == http.MethodPost && userID == "":
 		return processUsersPOST(r, servicePrv)
+	case r.Method == http.MethodPut && userID != "":
+		return processUserPUT(r, servicePrv, userID)
+	case r.Method == http.MethodPatch && userID != "":
+		return processUserPATCH(r, servicePrv, userID)
+	case r.Method == http.MethodDelete && userID != "":
+		return processUserDELETE(r, servicePrv, userID)
 	default:
 		return okResult{nil, http.StatusMethodNotAllowed}
 	}
 }
 
+func processUsersGET(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
+	opts, err := parseUsersOptions(r.URL)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	page := models.GetUsersPageDto{}
+	err = servicePrv.GetUsersService().GetUsers(opts, &page)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{page, http.StatusOK}
+}
+
+func processUserGET(r *http.Request, servicePrv services.ServiceProvider, userID string) handlerResult {
+	u := models.User{}
+	err := servicePrv.GetUsersService().GetUserByID(userID, &u)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{u.ToResultDto(), http.StatusOK}
+}
+
 func processUsersPOST(r *http.Request, servicePrv services.ServiceProvider) handlerResult {
 	dto, err := parseUserBody(r)
 	if err != nil {
@@ -32,6 +69,91 @@ func processUsersPOST(r *http.Request, servicePrv services.ServiceProvider) hand
 	return okResult{id, http.StatusCreated}
 }
 
+// processUserPUT replaces the user name and the role, which isn't changed when it's empty.
+// The password is only changed when the body contains a new one.
+func processUserPUT(r *http.Request, servicePrv services.ServiceProvider, userID string) handlerResult {
+	dto, err := parseUserBody(r)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	return updateUser(r, servicePrv, userID, dto.ToUserPatchDto())
+}
+
+func processUserPATCH(r *http.Request, servicePrv services.ServiceProvider, userID string) handlerResult {
+	var patch models.UserPatchDto
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return errorResult{&appErrors.BadRequestError{Msg: "Invalid body", InternalError: err}}
+	}
+
+	return updateUser(r, servicePrv, userID, patch)
+}
+
+// updateUser changes the user. When its password is changed its refresh tokens and its api
+// keys are revoked, so the old password can't keep being used through them.
+func updateUser(r *http.Request, servicePrv services.ServiceProvider, userID string, patch models.UserPatchDto) handlerResult {
+	u := models.GetUsersResultDto{}
+	err := servicePrv.GetUsersService().UpdateUser(userID, getUserIDFromContext(r), patch, &u)
+	if err != nil {
+		return errorResult{err}
+	}
+
+	if patch.NewPassword != nil {
+		authSrv := servicePrv.GetAuthService()
+
+		if err := authSrv.RevokeUserRefreshTokens(userID); err != nil {
+			return errorResult{err}
+		}
+
+		if err := authSrv.RevokeUserAPIKeys(userID); err != nil {
+			return errorResult{err}
+		}
+	}
+
+	return okResult{u, http.StatusOK}
+}
+
+// processUserDELETE removes the user and its lists, or gives the lists to the user in the
+// transferTo query parameter
+func processUserDELETE(r *http.Request, servicePrv services.ServiceProvider, userID string) handlerResult {
+	transferTo := r.URL.Query().Get("transferTo")
+
+	err := servicePrv.GetUsersService().RemoveUser(userID, getUserIDFromContext(r), transferTo)
+	if err != nil {
+		return errorResult{err}
+	}
+	return okResult{nil, http.StatusNoContent}
+}
+
+func getUserIDFromURL(u *url.URL) string {
+	var userID string
+
+	if len(u.Path) > len("/users") {
+		userID = u.Path[len("/users/"):]
+	}
+
+	return userID
+}
+
+// parseUsersOptions reads the pagination options from the query string
+func parseUsersOptions(u *url.URL) (models.GetUsersOptions, error) {
+	q := u.Query()
+
+	opts := models.GetUsersOptions{
+		Cursor: q.Get("cursor"),
+	}
+
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return models.GetUsersOptions{}, &appErrors.BadRequestError{Msg: "Invalid limit", InternalError: err}
+		}
+		opts.Limit = limit
+	}
+
+	return opts, nil
+}
+
 func parseUserBody(r *http.Request) (models.UserDto, error) {
 	decoder := json.NewDecoder(r.Body)
 	var dto models.UserDto
@@ -42,6 +42,36 @@ func (us *mockedUsersService) GetUserByUserName(userName string, u *models.User)
 	return args.Error(0)
 }
 
+func (us *mockedUsersService) GetUsers(opts models.GetUsersOptions, r *models.GetUsersPageDto) error {
+	args := us.Called(opts, r)
+	return args.Error(0)
+}
+
+func (us *mockedUsersService) UpdateUser(id string, currentUserID string, patch models.UserPatchDto, r *models.GetUsersResultDto) error {
+	args := us.Called(id, currentUserID, patch, r)
+	return args.Error(0)
+}
+
+func (us *mockedUsersService) RemoveUser(id string, currentUserID string, transferTo string) error {
+	args := us.Called(id, currentUserID, transferTo)
+	return args.Error(0)
+}
+
+func (us *mockedUsersService) ChangePassword(id string, dto models.ChangePasswordDto) error {
+	args := us.Called(id, dto)
+	return args.Error(0)
+}
+
+func (us *mockedUsersService) SetPassword(id string, newPassword string, confirmNewPassword string) error {
+	args := us.Called(id, newPassword, confirmNewPassword)
+	return args.Error(0)
+}
+
+func (us *mockedUsersService) RemoveAccount(id string) error {
+	args := us.Called(id)
+	return args.Error(0)
+}
+
 func TestUsersHandler(t *testing.T) {
 	testUsersSrv := new(mockedUsersService)
 
@@ -101,6 +131,102 @@ func TestUsersHandler(t *testing.T) {
 		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
 	})
 
+	t.Run("GET returns a page of users", func(t *testing.T) {
+		page := models.GetUsersPageDto{Users: []models.GetUsersResultDto{{ID: "1", UserName: "user1"}}, Total: 1}
+
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testUsersSrv.On("GetUsers", models.GetUsersOptions{Limit: 10, Cursor: "c"}, &models.GetUsersPageDto{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(1).(*models.GetUsersPageDto) = page
+		})
+
+		request, _ := http.NewRequest(http.MethodGet, "/users?limit=10&cursor=c", nil)
+
+		got := UsersHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{page, http.StatusOK}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+	})
+
+	t.Run("GET WITH AN ID returns the user without the password hash", func(t *testing.T) {
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testUsersSrv.On("GetUserByID", "1", &models.User{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(1).(*models.User) = models.User{ID: "1", UserName: "user1", PasswordHash: "hash", Role: models.UserRoleAdmin}
+		})
+
+		request, _ := http.NewRequest(http.MethodGet, "/users/1", nil)
+
+		got := UsersHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{models.GetUsersResultDto{ID: "1", UserName: "user1", Role: models.UserRoleAdmin}, http.StatusOK}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+	})
+
+	t.Run("PATCH changes the given fields of the user", func(t *testing.T) {
+		role := models.UserRoleReader
+
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testUsersSrv.On("UpdateUser", "1", "admin", models.UserPatchDto{Role: &role}, &models.GetUsersResultDto{}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(3).(*models.GetUsersResultDto) = models.GetUsersResultDto{ID: "1", UserName: "user1"}
+		})
+
+		request, _ := http.NewRequest(http.MethodPatch, "/users/1", strings.NewReader(`{"role":"reader"}`))
+		request = addUserIDToContext("admin", request)
+
+		got := UsersHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{models.GetUsersResultDto{ID: "1", UserName: "user1"}, http.StatusOK}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+	})
+
+	t.Run("PUT only changes the password when a new one is given", func(t *testing.T) {
+		name := "user1"
+		role := models.UserRoleAdmin
+
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testUsersSrv.On("UpdateUser", "1", "admin", models.UserPatchDto{UserName: &name, Role: &role}, &models.GetUsersResultDto{}).Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPut, "/users/1", strings.NewReader(`{"userName":"user1","role":"admin"}`))
+		request = addUserIDToContext("admin", request)
+
+		got := UsersHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{models.GetUsersResultDto{}, http.StatusOK}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+	})
+
+	t.Run("PATCH revokes the refresh tokens and the api keys of the user when the password changes", func(t *testing.T) {
+		testAuthSrv := new(mockedAuthService)
+		pass := "newPass"
+
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testSrvProvider.On("GetAuthService").Return(testAuthSrv).Once()
+		testUsersSrv.On("UpdateUser", "1", "admin", models.UserPatchDto{NewPassword: &pass, ConfirmNewPassword: &pass}, &models.GetUsersResultDto{}).Return(nil).Once()
+		testAuthSrv.On("RevokeUserRefreshTokens", "1").Return(nil).Once()
+		testAuthSrv.On("RevokeUserAPIKeys", "1").Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodPatch, "/users/1", strings.NewReader(`{"newPassword":"newPass","confirmNewPassword":"newPass"}`))
+		request = addUserIDToContext("admin", request)
+
+		got := UsersHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{models.GetUsersResultDto{}, http.StatusOK}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+		testAuthSrv.AssertExpectations(t)
+	})
+
+	t.Run("DELETE removes the user transferring its lists", func(t *testing.T) {
+		testSrvProvider.On("GetUsersService").Return(testUsersSrv).Once()
+		testUsersSrv.On("RemoveUser", "1", "admin", "2").Return(nil).Once()
+
+		request, _ := http.NewRequest(http.MethodDelete, "/users/1?transferTo=2", nil)
+		request = addUserIDToContext("admin", request)
+
+		got := UsersHandler(request, testSrvProvider)
+
+		assert.Equal(t, okResult{nil, http.StatusNoContent}, got)
+		assertUsersExpectations(t, testSrvProvider, testUsersSrv)
+	})
+
 	t.Run("returns and okResult with a 405 status when the method is not GET, POST, PUT or DELETE", func(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodPatch, "/users", nil)
 
@@ -118,7 +244,7 @@ func userDtoToCreate() models.UserDto {
 		UserName:           "newUser1",
 		NewPassword:        "password",
 		ConfirmNewPassword: "password",
-		IsAdmin:            true,
+		Role:               models.UserRoleAdmin,
 	}
 }
 
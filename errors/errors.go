@@ -2,6 +2,22 @@ package errors
 
 import (
 	"fmt"
+	"time"
+)
+
+// The codes of the errors, which let the clients know what happened without checking the
+// messages. They can't change.
+const (
+	CodeUnexpected         = "unexpected_error"
+	CodeInternal           = "internal_error"
+	CodeNotFound           = "not_found"
+	CodeBadRequest         = "bad_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeConflict           = "conflict"
+	CodeTooManyRequests    = "too_many_requests"
+	CodeValidation         = "validation_failed"
+	CodeInvalidCredentials = "invalid_credentials"
 )
 
 // UnexpectedError is used for unexpected errors
@@ -33,6 +49,14 @@ func (e *BadRequestError) Error() string {
 	return e.Msg
 }
 
+// InvalidCredentialsError happens when the user name or the password of a login is wrong. It
+// is the same for both, so it doesn't tell which user names exist.
+type InvalidCredentialsError struct{}
+
+func (e *InvalidCredentialsError) Error() string {
+	return "Invalid user name or password"
+}
+
 // UnauthorizedError happens when the request is unauthorized
 type UnauthorizedError struct {
 	Msg           string
@@ -42,3 +66,49 @@ type UnauthorizedError struct {
 func (e *UnauthorizedError) Error() string {
 	return e.Msg
 }
+
+// ForbiddenError happens when the user can't do something with a document they can access
+type ForbiddenError struct {
+	Msg           string
+	InternalError error
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Msg
+}
+
+// ConflictError happens when a document has been changed since the client read it
+type ConflictError struct {
+	Msg           string
+	InternalError error
+}
+
+func (e *ConflictError) Error() string {
+	return e.Msg
+}
+
+// TooManyRequestsError happens when a client has to wait before trying again
+type TooManyRequestsError struct {
+	Msg        string
+	RetryAfter time.Duration
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return e.Msg
+}
+
+// FieldError describes why the value of a field of the request isn't valid
+type FieldError struct {
+	Field string `json:"field"`
+	Msg   string `json:"message"`
+}
+
+// ValidationError happens when some fields of the request aren't valid
+type ValidationError struct {
+	Msg    string
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return e.Msg
+}
@@ -1,81 +1,466 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math/big"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
 	"github.com/AngelVlc/lists-backend/models"
 	"github.com/AngelVlc/lists-backend/services"
 	"github.com/AngelVlc/lists-backend/stores"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
+// requestMetricsInterval is how often the requests are added to the requests counter
+const requestMetricsInterval = time.Second * 10
+
+// shutdownTimeout is how long the requests in progress are waited for when the server stops
+const shutdownTimeout = time.Second * 10
+
 func main() {
 	port := os.Getenv("PORT")
 	addr := fmt.Sprintf(":%v", port)
 
-	jwtSecret := os.Getenv("JWT_SECRET")
+	ms := newSession()
+
+	ph := newPasswordHasher()
+
+	jwtp := newJwtProvider()
+
+	policy := newPasswordPolicy()
+
+	sp := services.NewMyServiceProvider(ms, ph, policy, jwtp, newAuthConfig(), newMailer(), newRegistrationConfig(), newPasswordResetConfig())
+
+	checkAdminUser(sp, ph, newAdminConfig(policy))
+
+	rm := services.NewMyRequestMetrics(sp.GetCountersService())
+	done := make(chan struct{})
+	metricsFlushed := make(chan struct{})
+	go func() {
+		rm.Run(requestMetricsInterval, done)
+		close(metricsFlushed)
+	}()
+
+	server := &http.Server{Addr: addr, Handler: newServer(sp, rm, os.Getenv("TRUST_PROXY_HEADERS") == "true")}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		log.Printf("Listening on port %v ...\n", port)
+
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatalf("could not listen on port %v %v", port, err)
+		}
+	}()
+
+	<-stop
+	log.Printf("Shutting down ...")
+
+	// the requests in progress are counted before the last flush of the metrics
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down the server: %v", err)
+	}
+
+	close(done)
+	<-metricsFlushed
+}
+
+func newSession() stores.MongoSession {
+	switch storeType := os.Getenv("STORE_TYPE"); storeType {
+	case "", "mongo":
+		return stores.NewMyMongoSession(os.Getenv("MONGODB_URI"))
+	case "memory":
+		return stores.NewMyMemorySession()
+	case "sqlite":
+		return stores.NewMyRelationalSession("sqlite3", os.Getenv("DATABASE_URL"))
+	case "postgres":
+		return stores.NewMyRelationalSession("postgres", os.Getenv("DATABASE_URL"))
+	default:
+		log.Fatalf("unknown store type %q", storeType)
+		return nil
+	}
+}
+
+// newJwtProvider returns a provider which signs the tokens with the private key file in
+// JWT_PRIVATE_KEY_FILE, identified by JWT_KEY_ID, or with the JWT_SECRET when there is no key.
+// The public keys of the previous key pairs are given in JWT_PUBLIC_KEY_FILES as a comma
+// separated list of kid=path pairs.
+func newJwtProvider() services.JwtProvider {
+	privateKeyFile := os.Getenv("JWT_PRIVATE_KEY_FILE")
+	if privateKeyFile == "" {
+		return services.NewMyJwtProvider(os.Getenv("JWT_SECRET"))
+	}
+
+	privateKey, err := ioutil.ReadFile(privateKeyFile)
+	if err != nil {
+		log.Fatalf("error reading the jwt private key: %v", err)
+	}
+
+	publicKeys := map[string][]byte{}
+	for _, pair := range strings.Split(os.Getenv("JWT_PUBLIC_KEY_FILES"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			log.Fatalf("invalid jwt public key %q, the format is kid=path", pair)
+		}
+
+		kid := strings.TrimSpace(parts[0])
+		if publicKeys[kid], err = ioutil.ReadFile(strings.TrimSpace(parts[1])); err != nil {
+			log.Fatalf("error reading the jwt public key %q: %v", kid, err)
+		}
+	}
+
+	jwtp, err := services.NewMyKeyPairJwtProvider(privateKey, os.Getenv("JWT_KEY_ID"), publicKeys)
+	if err != nil {
+		log.Fatalf("error loading the jwt keys: %v", err)
+	}
+
+	return jwtp
+}
+
+// newAuthConfig returns the settings of the tokens from the JWT_TOKEN_DURATION,
+// JWT_REFRESH_TOKEN_DURATION, JWT_CHALLENGE_TOKEN_DURATION, JWT_ISSUER, JWT_AUDIENCE and
+// JWT_CLOCK_SKEW variables
+func newAuthConfig() services.AuthConfig {
+	c := services.DefaultAuthConfig()
+	c.Issuer = os.Getenv("JWT_ISSUER")
+	c.Audience = os.Getenv("JWT_AUDIENCE")
+
+	durations := map[string]*time.Duration{
+		"JWT_TOKEN_DURATION":           &c.TokenDuration,
+		"JWT_REFRESH_TOKEN_DURATION":   &c.RefreshTokenDuration,
+		"JWT_CHALLENGE_TOKEN_DURATION": &c.ChallengeTokenDuration,
+		"JWT_CLOCK_SKEW":               &c.ClockSkew,
+	}
+	for name, d := range durations {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			log.Fatalf("invalid %v %q", name, v)
+		}
+		*d = parsed
+	}
+
+	return c
+}
+
+// newPasswordHasher returns the hasher of the passwords. PASSWORD_HASH_ALGORITHM is bcrypt,
+// the default, or argon2id and BCRYPT_COST is the cost of the bcrypt hashes. The hashes with
+// another algorithm or cost are replaced when the users log in.
+func newPasswordHasher() services.PasswordHasher {
+	c := services.DefaultPasswordHasherConfig()
+	if v := os.Getenv("PASSWORD_HASH_ALGORITHM"); v != "" {
+		c.Algorithm = v
+	}
+
+	if v := os.Getenv("BCRYPT_COST"); v != "" {
+		cost, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("invalid BCRYPT_COST %q", v)
+		}
+		c.BcryptCost = cost
+	}
+
+	ph, err := services.NewMyPasswordHasher(c)
+	if err != nil {
+		log.Fatalf("error creating the password hasher: %v", err)
+	}
+
+	return ph
+}
+
+// newPasswordPolicy returns the policy of the new passwords. PASSWORD_MIN_LENGTH and
+// PASSWORD_MIN_CHARACTER_CLASSES change its defaults and PASSWORD_DENY_LIST_FILE contains
+// more denied passwords, one per line.
+func newPasswordPolicy() services.PasswordPolicy {
+	p := services.DefaultPasswordPolicy()
+
+	ints := map[string]*int{
+		"PASSWORD_MIN_LENGTH":            &p.MinLength,
+		"PASSWORD_MIN_CHARACTER_CLASSES": &p.MinCharacterClasses,
+	}
+	for name, i := range ints {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			log.Fatalf("invalid %v %q", name, v)
+		}
+		*i = parsed
+	}
+
+	if denyListFile := os.Getenv("PASSWORD_DENY_LIST_FILE"); denyListFile != "" {
+		content, err := ioutil.ReadFile(denyListFile)
+		if err != nil {
+			log.Fatalf("error reading the password deny list: %v", err)
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				p.Deny(line)
+			}
+		}
+	}
+
+	return p
+}
+
+// newMailer returns a mailer which sends the mails through the smtp server in SMTP_ADDR,
+// from SMTP_FROM and authenticated with SMTP_USER and SMTP_PASSWORD. Without a server the
+// mails are written to the MAIL_FILE file or, without it, to the log.
+func newMailer() services.Mailer {
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return services.NewMySMTPMailer(addr, os.Getenv("SMTP_FROM"), os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASSWORD"))
+	}
+
+	mailFile := os.Getenv("MAIL_FILE")
+	if mailFile == "" {
+		return services.NewMyLogMailer(log.Writer())
+	}
+
+	f, err := os.OpenFile(mailFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Fatalf("error opening the mail file: %v", err)
+	}
+
+	return services.NewMyLogMailer(f)
+}
+
+// newRegistrationConfig returns the settings of the self-registration, which is enabled
+// when REGISTRATION_ENABLED is "true". The verification mails link to REGISTRATION_VERIFY_URL
+// and their links expire after REGISTRATION_TOKEN_DURATION.
+func newRegistrationConfig() services.RegistrationConfig {
+	c := services.DefaultRegistrationConfig()
+	c.Enabled = os.Getenv("REGISTRATION_ENABLED") == "true"
+	c.VerificationURL = os.Getenv("REGISTRATION_VERIFY_URL")
+
+	if c.Enabled && c.VerificationURL == "" {
+		log.Fatalf("REGISTRATION_VERIFY_URL is required when the registration is enabled")
+	}
 
-	mongoUri := os.Getenv("MONGODB_URI")
-	ms := stores.NewMyMongoSession(mongoUri)
+	if v := os.Getenv("REGISTRATION_TOKEN_DURATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid REGISTRATION_TOKEN_DURATION %q", v)
+		}
+		c.VerificationTokenDuration = d
+	}
 
-	bp := services.NewMyBcryptProvider()
+	return c
+}
 
-	jwtp := services.NewMyJwtProvider(jwtSecret)
+// newPasswordResetConfig returns the settings of the password reset, which is enabled when
+// PASSWORD_RESET_URL, the page the mails link to, is set. The links expire after
+// PASSWORD_RESET_TOKEN_DURATION.
+func newPasswordResetConfig() services.PasswordResetConfig {
+	c := services.DefaultPasswordResetConfig()
+	c.URL = os.Getenv("PASSWORD_RESET_URL")
 
-	sp := services.NewMyServiceProvider(ms, bp, jwtp)
+	if v := os.Getenv("PASSWORD_RESET_TOKEN_DURATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid PASSWORD_RESET_TOKEN_DURATION %q", v)
+		}
+		c.TokenDuration = d
+	}
 
-	checkAdminUser(sp)
-	checkRequestsCounter(sp)
+	return c
+}
 
-	server := newServer(sp)
+// defaultAdminUserName and defaultAdminPassword are the name and the password of the admin
+// created by the previous versions. The password can't be used in production.
+const (
+	defaultAdminUserName = "admin"
+	defaultAdminPassword = "admin"
+)
 
-	log.Printf("Listening on port %v ...\n", port)
+// adminConfig contains the settings of the admin created on a new database
+type adminConfig struct {
+	userName   string
+	password   string
+	production bool
+	// policy is the one of the new passwords, which the generated password must follow
+	policy services.PasswordPolicy
+}
 
-	if err := http.ListenAndServe(addr, server); err != nil {
-		log.Fatalf("could not listen on port %v %v", port, err)
+// newAdminConfig returns the admin settings from the ADMIN_USER_NAME and ADMIN_PASSWORD
+// variables. The app runs in production mode when APP_ENV is "production".
+func newAdminConfig(policy services.PasswordPolicy) adminConfig {
+	c := adminConfig{
+		userName:   os.Getenv("ADMIN_USER_NAME"),
+		password:   os.Getenv("ADMIN_PASSWORD"),
+		production: os.Getenv("APP_ENV") == "production",
+		policy:     policy,
+	}
+	if c.userName == "" {
+		c.userName = defaultAdminUserName
 	}
+
+	return c
 }
 
-func checkAdminUser(sp services.ServiceProvider) {
+// checkAdminUser creates the admin when it doesn't exist. Without a configured password it
+// gets a one-time password, which is only written to the log. In both cases the admin must
+// change it on the first login.
+func checkAdminUser(sp services.ServiceProvider, ph services.PasswordHasher, c adminConfig) {
+	if c.production && c.password == defaultAdminPassword {
+		log.Fatalf("the default admin password can't be used in production")
+	}
+
 	us := sp.GetUsersService()
 
+	// the admin created by the previous versions can still exist with another admin configured
+	if c.userName != defaultAdminUserName {
+		legacy := models.User{}
+		err := us.GetUserByUserName(defaultAdminUserName, &legacy)
+		if err == nil {
+			checkDefaultAdminPassword(ph, legacy, c.production)
+		} else if _, ok := err.(*appErrors.NotFoundError); !ok {
+			log.Fatalf("error getting the %q user: %v", defaultAdminUserName, err)
+		}
+	}
+
 	u := models.User{}
-	err := us.GetUserByUserName("admin", &u)
+	err := us.GetUserByUserName(c.userName, &u)
 
 	if err == nil {
 		log.Printf("Admin user already exists")
+		checkDefaultAdminPassword(ph, u, c.production)
 		return
 	}
 
-	if _, ok := err.(*appErrors.NotFoundError); ok {
-		log.Printf("Admin user does not exist")
+	if _, ok := err.(*appErrors.NotFoundError); !ok {
+		log.Fatalf("error getting admin user: %v", err)
+	}
 
-		n := models.UserDto{
-			UserName:           "admin",
-			NewPassword:        "admin",
-			ConfirmNewPassword: "admin",
-			IsAdmin:            true,
-		}
-		_, err = us.AddUser(&n)
+	log.Printf("Admin user does not exist")
 
-		if err != nil {
-			log.Fatalf("error creating admin user: %v", err)
-		}
+	password := c.password
+	if password == "" {
+		password = newSetupPassword(c.policy, c.userName)
+	}
+
+	n := models.UserDto{
+		UserName:           c.userName,
+		NewPassword:        password,
+		ConfirmNewPassword: password,
+		Role:               models.UserRoleAdmin,
+		MustChangePassword: true,
+	}
+	if _, err = us.AddUser(&n); err != nil {
+		log.Fatalf("error creating admin user: %v", err)
+	}
+
+	if c.password == "" {
+		log.Printf("Created admin user %q with the one-time password %q, which must be changed on the first login", c.userName, password)
+	} else {
+		log.Printf("Created admin user %q, its password must be changed on the first login", c.userName)
+	}
+}
+
+// checkDefaultAdminPassword stops the app in production when the user has the default admin
+// password and warns about it otherwise. The hash is compared without logging the user in,
+// so the user isn't changed.
+func checkDefaultAdminPassword(ph services.PasswordHasher, u models.User, production bool) {
+	if ph.CompareHashAndPassword([]byte(u.PasswordHash), []byte(defaultAdminPassword)) != nil {
+		return
+	}
+
+	if production {
+		log.Fatalf("the user %q has the default admin password, which can't be used in production", u.UserName)
+	}
+	log.Printf("The user %q has the default admin password, change it", u.UserName)
+}
+
+// setupPasswordLength is the length of the generated admin passwords, unless the policy
+// requires longer ones
+const setupPasswordLength = 24
+
+// setupPasswordClasses are the characters of each class used in the generated passwords
+var setupPasswordClasses = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"0123456789",
+	"-_.!@#%+=",
+}
 
-		log.Printf("Created admin user")
+// newSetupPassword returns a random password for the admin which follows the policy. It
+// contains every character class, so it only depends on the policy lengths.
+func newSetupPassword(policy services.PasswordPolicy, userName string) string {
+	length := setupPasswordLength
+	if policy.MinLength > length {
+		length = policy.MinLength
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		length = policy.MaxLength
+	}
+
+	// a generated password is only denied by chance, so another one is tried
+	for attempt := 0; attempt < 10; attempt++ {
+		password := randomSetupPassword(length)
+		if err := policy.Check(password, userName); err == nil {
+			return password
+		}
 	}
+
+	log.Fatalf("error generating an admin password which follows the password policy, set ADMIN_PASSWORD")
+	return ""
 }
 
-func checkRequestsCounter(sp services.ServiceProvider) {
-	s := sp.GetCountersService()
+// randomSetupPassword returns a random password with at least a character of each class
+// when it is long enough
+func randomSetupPassword(length int) string {
+	all := strings.Join(setupPasswordClasses, "")
+
+	b := make([]byte, length)
+	for i := range b {
+		chars := all
+		if i < len(setupPasswordClasses) {
+			chars = setupPasswordClasses[i]
+		}
+		b[i] = chars[randomInt(len(chars))]
+	}
+
+	// the characters of each class aren't left at the start
+	for i := len(b) - 1; i > 0; i-- {
+		j := randomInt(i + 1)
+		b[i], b[j] = b[j], b[i]
+	}
 
-	n := "requests"
+	return string(b)
+}
 
-	if !s.ExistsCounter(n) {
-		log.Println("Created requests counter")
-		s.AddCounter(n)
+// randomInt returns a random number from 0 to n-1
+func randomInt(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		log.Fatalf("error generating the admin password: %v", err)
 	}
+
+	return int(v.Int64())
 }
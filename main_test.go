@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/services"
+	"github.com/AngelVlc/lists-backend/stores"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckAdminUser(t *testing.T) {
+	ph, _ := services.NewMyPasswordHasher(services.PasswordHasherConfig{Algorithm: services.HashAlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+
+	newSessionAndServiceProvider := func() (stores.MongoSession, services.ServiceProvider) {
+		session := stores.NewMyMemorySession()
+		return session, services.NewMyServiceProvider(session, ph, services.DefaultPasswordPolicy(), nil, services.DefaultAuthConfig(), nil, services.DefaultRegistrationConfig(), services.DefaultPasswordResetConfig())
+	}
+
+	newServiceProvider := func() services.ServiceProvider {
+		_, sp := newSessionAndServiceProvider()
+		return sp
+	}
+
+	t.Run("creates the admin with the configured name and password", func(t *testing.T) {
+		sp := newServiceProvider()
+
+		checkAdminUser(sp, ph, adminConfig{userName: "root", password: "thePassword"})
+
+		u, err := sp.GetUsersService().CheckIfUserPasswordIsOk("root", "thePassword")
+		assert.Nil(t, err)
+		assert.Equal(t, models.UserRoleAdmin, u.Role)
+		assert.True(t, u.MustChangePassword)
+	})
+
+	t.Run("creates the admin with a generated password when it isn't configured", func(t *testing.T) {
+		sp := newServiceProvider()
+
+		checkAdminUser(sp, ph, adminConfig{userName: "admin", policy: services.DefaultPasswordPolicy()})
+
+		u := models.User{}
+		assert.Nil(t, sp.GetUsersService().GetUserByUserName("admin", &u))
+		assert.True(t, u.MustChangePassword)
+
+		_, err := sp.GetUsersService().CheckIfUserPasswordIsOk("admin", defaultAdminPassword)
+		assert.NotNil(t, err, "the admin shouldn't have the default password")
+	})
+
+	t.Run("doesn't change an existing admin", func(t *testing.T) {
+		sp := newServiceProvider()
+		checkAdminUser(sp, ph, adminConfig{userName: "admin", password: "thePassword"})
+
+		checkAdminUser(sp, ph, adminConfig{userName: "admin", password: "otherPassword"})
+
+		_, err := sp.GetUsersService().CheckIfUserPasswordIsOk("admin", "thePassword")
+		assert.Nil(t, err)
+	})
+
+	t.Run("checks the default password of the admins without changing them", func(t *testing.T) {
+		session, sp := newSessionAndServiceProvider()
+
+		// the hash has another cost, so logging the admin in would replace it
+		hash, _ := bcrypt.GenerateFromPassword([]byte(defaultAdminPassword), bcrypt.MinCost+1)
+		legacy := models.User{UserName: defaultAdminUserName, PasswordHash: string(hash), Role: models.UserRoleAdmin, IsActive: true}
+		_, err := session.GetRepository("users").Add(&legacy)
+		assert.Nil(t, err)
+
+		checkAdminUser(sp, ph, adminConfig{userName: "root", password: "thePassword"})
+		checkAdminUser(sp, ph, adminConfig{userName: defaultAdminUserName})
+
+		u := models.User{}
+		assert.Nil(t, sp.GetUsersService().GetUserByUserName(defaultAdminUserName, &u))
+		assert.Equal(t, legacy.PasswordHash, u.PasswordHash)
+
+		assert.Nil(t, sp.GetUsersService().GetUserByUserName("root", &models.User{}))
+	})
+}
+
+func TestNewSetupPassword(t *testing.T) {
+	p := newSetupPassword(services.PasswordPolicy{}, "admin")
+
+	assert.Len(t, p, 24)
+	assert.NotEqual(t, p, newSetupPassword(services.PasswordPolicy{}, "admin"))
+
+	policy := services.DefaultPasswordPolicy()
+	policy.MinLength = 30
+	policy.MinCharacterClasses = 4
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, policy.Check(newSetupPassword(policy, "admin"), "admin"))
+	}
+}
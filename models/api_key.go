@@ -0,0 +1,42 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey lets scripts and integrations act on behalf of a user with some of its permissions.
+// Only the hash of the key is stored. The scope contains the permissions separated by spaces
+// and the keys with a zero ExpiresAt never expire.
+type APIKey struct {
+	ID        string    `bson:"_id"`
+	UserID    string    `bson:"userId"`
+	Name      string    `bson:"name"`
+	Prefix    string    `bson:"prefix"`
+	KeyHash   string    `bson:"keyHash"`
+	Scope     string    `bson:"scope"`
+	CreatedAt time.Time `bson:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// Scopes returns the permissions granted to the key
+func (k *APIKey) Scopes() []string {
+	return strings.Fields(k.Scope)
+}
+
+// IsExpired returns true when the key has expired at the given time
+func (k *APIKey) IsExpired(t time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !t.Before(k.ExpiresAt)
+}
+
+// ToResultDto returns the APIKeyResultDto for the key, without the key itself
+func (k *APIKey) ToResultDto() APIKeyResultDto {
+	return APIKeyResultDto{
+		ID:        k.ID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes(),
+		CreatedAt: k.CreatedAt,
+		ExpiresAt: k.ExpiresAt,
+	}
+}
@@ -1,12 +1,49 @@
 package models
 
+import "time"
+
 // Login is the model used for login
 type Login struct {
 	UserName string `json:"userName"`
 	Password string `json:"password"`
 }
 
+// TotpLogin is the model used for the second step of the login of the users with the
+// two-factor authentication enabled
+type TotpLogin struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
 // RefreshToken is the model used for refreshing the token
 type RefreshToken struct {
 	RefreshToken string `json:"refreshToken"`
 }
+
+// RefreshTokenFamily is the model used to store the refresh tokens issued after a login.
+// Each refresh replaces the token id, so only the last token of the family is valid.
+type RefreshTokenFamily struct {
+	ID        string    `bson:"_id"`
+	UserID    string    `bson:"userId"`
+	TokenID   string    `bson:"tokenId"`
+	CreatedAt time.Time `bson:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// JSONWebKey is a public key used to verify the jwt tokens, in JSON Web Key format
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JwksDto is the JSON Web Key Set with the keys used to verify the jwt tokens
+type JwksDto struct {
+	Keys []JSONWebKey `json:"keys"`
+}
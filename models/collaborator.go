@@ -0,0 +1,32 @@
+package models
+
+// The roles a user can have in a list. The owner is the user who created it.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+// roleLevels sorts the roles by the permissions they grant
+var roleLevels = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// Collaborator is a user who can access a list owned by another user
+type Collaborator struct {
+	UserID   string `json:"userId" bson:"userId"`
+	UserName string `json:"userName" bson:"userName"`
+	Role     string `json:"role" bson:"role"`
+}
+
+// IsCollaboratorRole returns true when the role can be given to a collaborator
+func IsCollaboratorRole(role string) bool {
+	return role == RoleViewer || role == RoleEditor
+}
+
+// HasRole returns true when the role grants at least the permissions of the required one
+func HasRole(role string, required string) bool {
+	return roleLevels[role] > 0 && roleLevels[role] >= roleLevels[required]
+}
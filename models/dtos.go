@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // ListDto is the struct used as DTO for a List
 type ListDto struct {
 	Name  string
@@ -14,10 +16,121 @@ func (dto *ListDto) ToList() List {
 	}
 }
 
-// GetListsResultDto is the struct used as result for the Get method
+// ItemDto is the struct used as DTO for an Item
+type ItemDto struct {
+	Title       string
+	Description string
+	Done        bool
+}
+
+// ToItem returns an Item from the Dto
+func (dto *ItemDto) ToItem() Item {
+	return Item{
+		Title:       dto.Title,
+		Description: dto.Description,
+		Done:        dto.Done,
+	}
+}
+
+// ItemPatchDto is the struct used to change some fields of an Item. The nil
+// fields are not changed.
+type ItemPatchDto struct {
+	Title       *string
+	Description *string
+	Done        *bool
+}
+
+// CollaboratorDto is the struct used as DTO for a Collaborator. The user name is only
+// used to invite a user.
+type CollaboratorDto struct {
+	UserName string
+	Role     string
+}
+
+// ToCollaborator returns a Collaborator from the Dto
+func (dto *CollaboratorDto) ToCollaborator() Collaborator {
+	return Collaborator{
+		UserName: dto.UserName,
+		Role:     dto.Role,
+	}
+}
+
+// ShareLinkDto is the struct used as DTO for a ShareLink
+type ShareLinkDto struct {
+	ExpiresAt time.Time
+}
+
+// APIKeyDto is the struct used as DTO to create an APIKey
+type APIKeyDto struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+// APIKeyResultDto is the struct used as result for an APIKey. The key is only returned
+// when it is created.
+type APIKeyResultDto struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Key       string    `json:"key,omitempty"`
+}
+
+// TotpEnrollmentDto is the struct used as result when the two-factor authentication
+// enrollment starts. The URI can be shown as a QR code to add the secret to an app.
+type TotpEnrollmentDto struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TotpCodeDto is the struct used as DTO to send a two-factor authentication code, which
+// can be a recovery code too
+type TotpCodeDto struct {
+	Code string
+}
+
+// RecoveryCodesDto is the struct used as result when the two-factor authentication is
+// enabled. The recovery codes can only be read there.
+type RecoveryCodesDto struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// SharedListDto is the struct used as result for a list read with a share link
+type SharedListDto struct {
+	Name      string    `json:"name"`
+	Items     []Item    `json:"items"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// GetListsResultDto is the struct used as result for the Get method. The counts
+// don't include the archived items.
 type GetListsResultDto struct {
-	ID   string `json:"id" bson:"_id"`
-	Name string `json:"name" bson:"name"`
+	ID             string    `json:"id" bson:"_id"`
+	Name           string    `json:"name" bson:"name"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt" bson:"updatedAt"`
+	ItemsCount     int       `json:"itemsCount" bson:"-"`
+	DoneItemsCount int       `json:"doneItemsCount" bson:"-"`
+}
+
+// GetListsOptions contains the options used to get a page of lists
+type GetListsOptions struct {
+	Limit      int
+	Cursor     string
+	SortBy     string
+	Descending bool
+	Name       string
+}
+
+// GetListsPageDto is the struct used as result for a page of lists. NextCursor is
+// empty when there aren't more lists.
+type GetListsPageDto struct {
+	Lists      []GetListsResultDto `json:"lists"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+	Total      int                 `json:"total"`
 }
 
 // UserDto is the struct used as DTO for a user
@@ -25,20 +138,112 @@ type UserDto struct {
 	UserName           string
 	NewPassword        string
 	ConfirmNewPassword string
-	IsAdmin            bool
+	Role               string
+	// MustChangePassword forces the user to change the password before using the API
+	MustChangePassword bool
 }
 
-// ToUser returns a User from the Dto
+// ToUser returns an active User from the Dto. Its role is the user role when the Dto
+// doesn't have one.
 func (dto *UserDto) ToUser() User {
+	role := dto.Role
+	if role == "" {
+		role = UserRoleUser
+	}
+
 	return User{
-		UserName: dto.UserName,
-		IsAdmin:  dto.IsAdmin,
+		UserName:           dto.UserName,
+		Role:               role,
+		IsActive:           true,
+		MustChangePassword: dto.MustChangePassword,
+	}
+}
+
+// UserPatchDto is the struct used to change some fields of a User. The nil fields are
+// not changed.
+type UserPatchDto struct {
+	UserName           *string
+	NewPassword        *string
+	ConfirmNewPassword *string
+	Role               *string
+	IsActive           *bool
+}
+
+// ToUserPatchDto returns the UserPatchDto which replaces the user with the Dto. The
+// password is only changed when a new one is given.
+func (dto *UserDto) ToUserPatchDto() UserPatchDto {
+	patch := UserPatchDto{
+		UserName: &dto.UserName,
+	}
+
+	if dto.Role != "" {
+		patch.Role = &dto.Role
 	}
+
+	if dto.NewPassword != "" || dto.ConfirmNewPassword != "" {
+		patch.NewPassword = &dto.NewPassword
+		patch.ConfirmNewPassword = &dto.ConfirmNewPassword
+	}
+
+	return patch
+}
+
+// RegistrationDto is the struct used by the users to sign up
+type RegistrationDto struct {
+	UserName           string
+	Email              string
+	NewPassword        string
+	ConfirmNewPassword string
+}
+
+// VerifyEmailDto is the struct used to verify the email address of a new user with the
+// token of the verification mail
+type VerifyEmailDto struct {
+	Token string
+}
+
+// ForgotPasswordDto is the struct used to ask for a password reset mail
+type ForgotPasswordDto struct {
+	Email string
+}
+
+// ResetPasswordDto is the struct used to set a new password with the token of the password
+// reset mail
+type ResetPasswordDto struct {
+	Token              string
+	NewPassword        string
+	ConfirmNewPassword string
+}
+
+// ChangePasswordDto is the struct used by the users to change their password
+type ChangePasswordDto struct {
+	OldPassword        string
+	NewPassword        string
+	ConfirmNewPassword string
+}
+
+// GetUsersOptions contains the options used to get a page of users, which are sorted
+// by their user name
+type GetUsersOptions struct {
+	Limit  int
+	Cursor string
+}
+
+// GetUsersPageDto is the struct used as result for a page of users. NextCursor is
+// empty when there aren't more users.
+type GetUsersPageDto struct {
+	Users      []GetUsersResultDto `json:"users"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+	Total      int                 `json:"total"`
 }
 
 // GetUsersResultDto is the struct used as result for the GetUsers method
 type GetUsersResultDto struct {
-	ID       string `json:"id" bson:"_id"`
-	UserName string `json:"userName" bson:"userName"`
-	IsAdmin  bool   `json:"isAdmin" bson:"isAdmin"`
+	ID                 string `json:"id" bson:"_id"`
+	UserName           string `json:"userName" bson:"userName"`
+	Role               string `json:"role" bson:"role"`
+	IsActive           bool   `json:"isActive" bson:"isActive"`
+	Email              string `json:"email,omitempty" bson:"email"`
+	TotpEnabled        bool   `json:"totpEnabled" bson:"totpEnabled"`
+	MustChangePassword bool   `json:"mustChangePassword" bson:"mustChangePassword"`
 }
@@ -1,7 +1,16 @@
 package models
 
-// Item is the model for a single list item
+import "time"
+
+// Item is the model for a single list item. The archived items are kept in the
+// list but they aren't counted.
 type Item struct {
-	Title       string `json:"title" bson:"title"`
-	Description string `json:"description" bson:"description"`
+	ID          string    `json:"id" bson:"id"`
+	Title       string    `json:"title" bson:"title"`
+	Description string    `json:"description" bson:"description"`
+	Done        bool      `json:"done" bson:"done"`
+	DoneAt      time.Time `json:"doneAt" bson:"doneAt"`
+	Archived    bool      `json:"archived" bson:"archived"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
 }
@@ -2,12 +2,15 @@ package models
 
 // JwtClaimsInfo is the struct which contains the jwt token claims
 type JwtClaimsInfo struct {
-	UserID       string
-	UserName string
-	IsAdmin  bool
+	UserID      string
+	UserName    string
+	Role        string
+	Permissions []string
 }
 
 // RefreshTokenClaimsInfo is the struct which contains the refresh token claims
 type RefreshTokenClaimsInfo struct {
-	UserID string
+	ID       string
+	FamilyID string
+	UserID   string
 }
@@ -1,9 +1,70 @@
 package models
 
+import "time"
+
 // List is the model for the list
 type List struct {
-	ID     string `json:"id" bson:"_id"`
-	Name   string `json:"name" bson:"name"`
-	Items  []Item `json:"items" bson:"items"`
-	UserID string `json:"userId" bson:"userId"`
+	ID            string         `json:"id" bson:"_id"`
+	Name          string         `json:"name" bson:"name"`
+	Items         []Item         `json:"items" bson:"items"`
+	UserID        string         `json:"userId" bson:"userId"`
+	Collaborators []Collaborator `json:"collaborators" bson:"collaborators"`
+	ShareLinks    []ShareLink    `json:"-" bson:"shareLinks"`
+	CreatedAt     time.Time      `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt" bson:"updatedAt"`
+	Version       int            `json:"version" bson:"version"`
+}
+
+// ToSharedListDto returns the SharedListDto for the list, without the archived items
+func (l *List) ToSharedListDto() SharedListDto {
+	dto := SharedListDto{
+		Name:      l.Name,
+		Items:     []Item{},
+		UpdatedAt: l.UpdatedAt,
+	}
+
+	for _, i := range l.Items {
+		if !i.Archived {
+			dto.Items = append(dto.Items, i)
+		}
+	}
+
+	return dto
+}
+
+// Role returns the role of the user in the list or an empty string when the user can't access it
+func (l *List) Role(userID string) string {
+	if l.UserID == userID {
+		return RoleOwner
+	}
+
+	for _, c := range l.Collaborators {
+		if c.UserID == userID {
+			return c.Role
+		}
+	}
+
+	return ""
+}
+
+// ToResultDto returns the GetListsResultDto for the list, counting its items
+func (l *List) ToResultDto() GetListsResultDto {
+	dto := GetListsResultDto{
+		ID:        l.ID,
+		Name:      l.Name,
+		CreatedAt: l.CreatedAt,
+		UpdatedAt: l.UpdatedAt,
+	}
+
+	for _, i := range l.Items {
+		if i.Archived {
+			continue
+		}
+		dto.ItemsCount++
+		if i.Done {
+			dto.DoneItemsCount++
+		}
+	}
+
+	return dto
 }
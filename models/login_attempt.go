@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginAttempt contains the failed logins of a user name or an ip address. The logins are
+// rejected until LockedUntil.
+type LoginAttempt struct {
+	ID            string    `bson:"_id"`
+	Key           string    `bson:"key"`
+	Failures      int       `bson:"failures"`
+	LastFailureAt time.Time `bson:"lastFailureAt"`
+	LockedUntil   time.Time `bson:"lockedUntil"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ShareLink gives read only access to a list to anyone who knows its token. The links
+// with a zero ExpiresAt never expire.
+type ShareLink struct {
+	Token     string    `json:"token" bson:"token"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+// IsExpired returns true when the link has expired at the given time
+func (l *ShareLink) IsExpired(t time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !t.Before(l.ExpiresAt)
+}
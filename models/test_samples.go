@@ -8,6 +8,17 @@ func SampleList() List {
 				Title: "newItem",
 			},
 		},
+		Collaborators: []Collaborator{
+			Collaborator{
+				UserID: "2",
+				Role:   RoleViewer,
+			},
+		},
+		ShareLinks: []ShareLink{
+			ShareLink{
+				Token: "token",
+			},
+		},
 	}
 
 	return l
@@ -27,6 +38,17 @@ func SampleListSlice() []List {
 				Description: "this is the second item",
 			},
 		},
+		Collaborators: []Collaborator{
+			Collaborator{
+				UserID: "2",
+				Role:   RoleEditor,
+			},
+		},
+		ShareLinks: []ShareLink{
+			ShareLink{
+				Token: "token1",
+			},
+		},
 	}
 
 	list2 := List{
@@ -42,6 +64,17 @@ func SampleListSlice() []List {
 				Description: "this is the second item",
 			},
 		},
+		Collaborators: []Collaborator{
+			Collaborator{
+				UserID: "1",
+				Role:   RoleViewer,
+			},
+		},
+		ShareLinks: []ShareLink{
+			ShareLink{
+				Token: "token2",
+			},
+		},
 	}
 
 	return []List{list1, list2}
@@ -65,6 +98,6 @@ func SampleUser() User {
 	return User{
 		UserName:     "user1",
 		PasswordHash: "pass",
-		IsAdmin:      true,
+		Role:         UserRoleAdmin,
 	}
 }
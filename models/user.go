@@ -5,5 +5,34 @@ type User struct {
 	ID           string `json:"id" bson:"_id"`
 	UserName     string `json:"userName" bson:"userName"`
 	PasswordHash string `json:"passwordHash" bson:"passwordHash"`
-	IsAdmin      bool   `json:"isAdmin" bson:"isAdmin"`
+	Role         string `json:"role" bson:"role"`
+	IsActive     bool   `json:"isActive" bson:"isActive"`
+	// Email is only known for the users who signed up themselves, which are inactive until
+	// they verify it
+	Email         string `json:"email" bson:"email"`
+	EmailVerified bool   `json:"emailVerified" bson:"emailVerified"`
+	// TotpSecret is the base32 secret of the two-factor authentication. It is stored when
+	// the enrollment starts, but it isn't required to log in until TotpEnabled is true.
+	TotpSecret  string `json:"-" bson:"totpSecret"`
+	TotpEnabled bool   `json:"totpEnabled" bson:"totpEnabled"`
+	// TotpLastStep is the time step of the last code used, so a code can't be used twice
+	TotpLastStep int64 `json:"-" bson:"totpLastStep"`
+	// RecoveryCodes contains the hashes of the unused recovery codes separated by spaces
+	RecoveryCodes string `json:"-" bson:"recoveryCodes"`
+	// MustChangePassword is true when the user can only change its password, like the
+	// admin created with a generated password
+	MustChangePassword bool `json:"mustChangePassword" bson:"mustChangePassword"`
+}
+
+// ToResultDto returns the GetUsersResultDto for the user, without the password hash
+func (u *User) ToResultDto() GetUsersResultDto {
+	return GetUsersResultDto{
+		ID:                 u.ID,
+		UserName:           u.UserName,
+		Role:               u.Role,
+		IsActive:           u.IsActive,
+		Email:              u.Email,
+		TotpEnabled:        u.TotpEnabled,
+		MustChangePassword: u.MustChangePassword,
+	}
 }
@@ -0,0 +1,44 @@
+package models
+
+// The permissions the routes can require
+const (
+	PermissionListsRead   = "lists:read"
+	PermissionListsWrite  = "lists:write"
+	PermissionUsersManage = "users:manage"
+	PermissionStatsRead   = "stats:read"
+)
+
+// The roles of the users
+const (
+	UserRoleReader = "reader"
+	UserRoleUser   = "user"
+	UserRoleAdmin  = "admin"
+)
+
+var userRolePermissions = map[string][]string{
+	UserRoleReader: {PermissionListsRead},
+	UserRoleUser:   {PermissionListsRead, PermissionListsWrite},
+	UserRoleAdmin:  {PermissionListsRead, PermissionListsWrite, PermissionUsersManage, PermissionStatsRead},
+}
+
+// IsUserRole returns true if the role is a valid user role
+func IsUserRole(role string) bool {
+	_, ok := userRolePermissions[role]
+	return ok
+}
+
+// RolePermissions returns the permissions granted to the role
+func RolePermissions(role string) []string {
+	return append([]string{}, userRolePermissions[role]...)
+}
+
+// HasPermission returns true if the permissions contain the permission
+func HasPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
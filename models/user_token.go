@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// The kinds of the user tokens
+const (
+	UserTokenEmailVerification = "emailVerification"
+	UserTokenPasswordReset     = "passwordReset"
+)
+
+// UserToken is a single use token sent to a user to confirm an action, like the verification
+// of its email address. Only the hash of the token is stored.
+type UserToken struct {
+	ID        string    `bson:"_id"`
+	UserID    string    `bson:"userId"`
+	Kind      string    `bson:"kind"`
+	TokenHash string    `bson:"tokenHash"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// IsExpired returns true when the token has expired at the given time
+func (t *UserToken) IsExpired(at time.Time) bool {
+	return !at.Before(t.ExpiresAt)
+}
@@ -4,37 +4,79 @@ import (
 	"net/http"
 
 	"github.com/AngelVlc/lists-backend/controllers"
+	"github.com/AngelVlc/lists-backend/models"
 	"github.com/AngelVlc/lists-backend/services"
 )
 
+// listsPermissions allows reading the lists with the lists:read permission and changing
+// them with the lists:write one
+var listsPermissions = controllers.Permissions{
+	http.MethodGet:        models.PermissionListsRead,
+	http.MethodHead:       models.PermissionListsRead,
+	controllers.AnyMethod: models.PermissionListsWrite,
+}
+
+var usersPermissions = controllers.Permissions{
+	controllers.AnyMethod: models.PermissionUsersManage,
+}
+
 type server struct {
-	serviceProvider services.ServiceProvider
+	serviceProvider   services.ServiceProvider
+	trustProxyHeaders bool
+	requestMetrics    services.RequestMetrics
 	http.Handler
 }
 
-func newServer(sp services.ServiceProvider) *server {
+// newServer returns the server with all the routes, which count the requests with the
+// request metrics. The proxy headers are only trusted when the app is behind a proxy which
+// sets them.
+func newServer(sp services.ServiceProvider, rm services.RequestMetrics, trustProxyHeaders bool) *server {
 	s := new(server)
 	s.serviceProvider = sp
+	s.requestMetrics = rm
+	s.trustProxyHeaders = trustProxyHeaders
 
 	router := http.NewServeMux()
 
-	router.Handle("/lists", s.getHandler(controllers.ListsHandler, true, false))
-	router.Handle("/lists/", s.getHandler(controllers.ListsHandler, true, false))
-	router.Handle("/users", s.getHandler(controllers.UsersHandler, true, true))
-	router.Handle("/users/", s.getHandler(controllers.UsersHandler, true, true))
-	router.Handle("/auth/token", s.getHandler(controllers.TokenHandler, false, false))
-	router.Handle("/auth/refreshtoken", s.getHandler(controllers.RefreshTokenHandler, false, false))
+	router.Handle("/lists", s.getHandler(controllers.ListsHandler, true, listsPermissions))
+	router.Handle("/lists/", s.getHandler(controllers.ListsHandler, true, listsPermissions))
+	router.Handle("/shared/", s.getHandler(controllers.SharedListsHandler, false, nil))
+	router.Handle("/me", s.getMeHandler())
+	router.Handle("/me/", s.getMeHandler())
+	router.Handle("/users", s.getHandler(controllers.UsersHandler, true, usersPermissions))
+	router.Handle("/users/", s.getHandler(controllers.UsersHandler, true, usersPermissions))
+	router.Handle("/auth/token", s.getHandler(controllers.TokenHandler, false, nil))
+	router.Handle("/auth/token/totp", s.getHandler(controllers.TotpTokenHandler, false, nil))
+	router.Handle("/auth/register", s.getHandler(controllers.RegisterHandler, false, nil))
+	router.Handle("/auth/register/verify", s.getHandler(controllers.VerifyEmailHandler, false, nil))
+	router.Handle("/auth/password/forgot", s.getHandler(controllers.ForgotPasswordHandler, false, nil))
+	router.Handle("/auth/password/reset", s.getHandler(controllers.ResetPasswordHandler, false, nil))
+	router.Handle("/auth/refreshtoken", s.getHandler(controllers.RefreshTokenHandler, false, nil))
+	router.Handle("/auth/logout", s.getHandler(controllers.LogoutHandler, false, nil))
+	router.Handle("/auth/logoutall", s.getHandler(controllers.LogoutAllHandler, true, nil))
+	router.Handle("/.well-known/jwks.json", s.getHandler(controllers.JwksHandler, false, nil))
 
 	s.Handler = router
 
 	return s
 }
 
-func (s *server) getHandler(handlerFunc controllers.HandlerFunc, requireAuth bool, requireAdmin bool) controllers.Handler {
+func (s *server) getHandler(handlerFunc controllers.HandlerFunc, requireAuth bool, permissions controllers.Permissions) controllers.Handler {
 	return controllers.Handler{
-		HandlerFunc:     handlerFunc,
-		ServiceProvider: s.serviceProvider,
-		RequireAuth:     requireAuth,
-		RequireAdmin:    requireAdmin,
+		HandlerFunc:       handlerFunc,
+		ServiceProvider:   s.serviceProvider,
+		RequireAuth:       requireAuth,
+		Permissions:       permissions,
+		TrustProxyHeaders: s.trustProxyHeaders,
+		RequestMetrics:    s.requestMetrics,
 	}
 }
+
+// getMeHandler returns the handler of the account endpoints, which are the only ones the
+// users who must change their password can use
+func (s *server) getMeHandler() controllers.Handler {
+	h := s.getHandler(controllers.MeHandler, true, nil)
+	h.AllowPasswordChange = true
+
+	return h
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/AngelVlc/lists-backend/models"
 	"github.com/AngelVlc/lists-backend/services"
 	"github.com/AngelVlc/lists-backend/stores"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestMain(m *testing.M) {
@@ -19,11 +23,9 @@ func TestMain(m *testing.M) {
 }
 
 func TestServer(t *testing.T) {
-	ms := stores.NewMyMongoSession(os.Getenv("MONGODB_URI_TEST"))
-	sp := services.NewMyServiceProvider(ms, nil, nil)
-	cs := sp.GetCountersService()
-	cs.AddCounter("requests")
-	server := newServer(sp)
+	ms := stores.NewMyMemorySession()
+	sp := services.NewMyServiceProvider(ms, nil, services.PasswordPolicy{}, nil, services.DefaultAuthConfig(), nil, services.DefaultRegistrationConfig(), services.DefaultPasswordResetConfig())
+	server := newServer(sp, services.NewMyRequestMetrics(sp.GetCountersService()), false)
 
 	t.Run("handles /users", func(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodGet, "/users", nil)
@@ -61,6 +63,24 @@ func TestServer(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, response.Result().StatusCode, "status are not equal")
 	})
 
+	t.Run("handles /shared/token without authentication", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/shared/wadus", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusNotFound, response.Result().StatusCode, "status are not equal")
+	})
+
+	t.Run("handles /me", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/me", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusUnauthorized, response.Result().StatusCode, "status are not equal")
+	})
+
 	t.Run("handles /auth/token", func(t *testing.T) {
 		request, _ := http.NewRequest(http.MethodGet, "/auth/token", nil)
 		response := httptest.NewRecorder()
@@ -79,4 +99,68 @@ func TestServer(t *testing.T) {
 		assert.Equal(t, http.StatusMethodNotAllowed, response.Result().StatusCode, "status are not equal")
 	})
 
+	t.Run("handles /.well-known/jwks.json without authentication", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/.well-known/jwks.json", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, response.Result().StatusCode, "status are not equal")
+	})
+
+	t.Run("handles /auth/logout", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/auth/logout", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, response.Result().StatusCode, "status are not equal")
+	})
+
+	t.Run("handles /auth/logoutall", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/auth/logoutall", nil)
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusUnauthorized, response.Result().StatusCode, "status are not equal")
+	})
+
+}
+
+func TestServerPasswordChange(t *testing.T) {
+	ms := stores.NewMyMemorySession()
+	ph, _ := services.NewMyPasswordHasher(services.PasswordHasherConfig{Algorithm: services.HashAlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	sp := services.NewMyServiceProvider(ms, ph, services.PasswordPolicy{}, services.NewMyJwtProvider("secret"), services.DefaultAuthConfig(), nil, services.DefaultRegistrationConfig(), services.DefaultPasswordResetConfig())
+	server := newServer(sp, services.NewMyRequestMetrics(sp.GetCountersService()), false)
+
+	_, err := sp.GetUsersService().AddUser(&models.UserDto{UserName: "wadus", NewPassword: "oldPass", ConfirmNewPassword: "oldPass"})
+	assert.Nil(t, err)
+
+	post := func(path string, token string, body string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response := httptest.NewRecorder()
+
+		server.ServeHTTP(response, request)
+
+		return response
+	}
+
+	response := post("/auth/token", "", `{"userName":"wadus","password":"oldPass"}`)
+	assert.Equal(t, http.StatusOK, response.Result().StatusCode)
+
+	tokens := map[string]string{}
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&tokens))
+
+	request, _ := http.NewRequest(http.MethodPut, "/me/password", strings.NewReader(`{"oldPassword":"oldPass","newPassword":"newPass","confirmNewPassword":"newPass"}`))
+	request.Header.Set("Authorization", "Bearer "+tokens["token"])
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusNoContent, response.Result().StatusCode)
+
+	response = post("/auth/refreshtoken", "", `{"refreshToken":"`+tokens["refreshToken"]+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, response.Result().StatusCode, "the refresh token of the old password should be revoked")
 }
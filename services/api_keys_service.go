@@ -0,0 +1,184 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+)
+
+// apiKeyPrefix starts all the api keys, so they can be told apart from the jwt tokens
+const apiKeyPrefix = "lak_"
+
+// apiKeyBytes is the number of random bytes of an api key
+const apiKeyBytes = 32
+
+// apiKeyVisibleChars is the number of characters of the key, after its prefix, which are
+// stored to identify it
+const apiKeyVisibleChars = 8
+
+// GetAPIKeys returns the api keys of the user
+func (s *MyAuthService) GetAPIKeys(userID string, r *[]models.APIKeyResultDto) error {
+	keys := []models.APIKey{}
+	err := s.apiKeysRepository().Get(&keys, stores.Query{Filter: stores.Eq("userId", userID), Sort: []stores.Sort{stores.Asc("createdAt")}})
+	if err != nil {
+		return err
+	}
+
+	*r = []models.APIKeyResultDto{}
+	for _, k := range keys {
+		*r = append(*r, k.ToResultDto())
+	}
+
+	return nil
+}
+
+// AddAPIKey creates an api key for the user. The scopes must be permissions of the user
+// role. The result is the only place where the key can be read.
+func (s *MyAuthService) AddAPIKey(userID string, dto models.APIKeyDto, r *models.APIKeyResultDto) error {
+	if strings.TrimSpace(dto.Name) == "" {
+		return &appErrors.BadRequestError{Msg: "Name is mandatory", InternalError: nil}
+	}
+
+	if len(dto.Scopes) == 0 {
+		return &appErrors.BadRequestError{Msg: "At least one scope is required", InternalError: nil}
+	}
+
+	u := models.User{}
+	err := s.usersRepository().GetOne(&u, stores.Query{Filter: stores.Eq(stores.IDField, userID), Projection: stores.Fields("role")})
+	if err != nil {
+		return err
+	}
+
+	permissions := models.RolePermissions(u.Role)
+	for _, scope := range dto.Scopes {
+		if !models.HasPermission(permissions, scope) {
+			return &appErrors.BadRequestError{Msg: fmt.Sprintf("The scope %q is not valid", scope), InternalError: nil}
+		}
+	}
+
+	t := now()
+	k := models.APIKey{
+		UserID:    userID,
+		Name:      dto.Name,
+		Scope:     strings.Join(dto.Scopes, " "),
+		CreatedAt: t,
+		ExpiresAt: dto.ExpiresAt.UTC(),
+	}
+	if k.IsExpired(t) {
+		return &appErrors.BadRequestError{Msg: "The expiry time must be in the future", InternalError: nil}
+	}
+
+	token, err := newRandomToken(apiKeyBytes)
+	if err != nil {
+		return &appErrors.UnexpectedError{Msg: "Error creating the api key", InternalError: err}
+	}
+
+	key := apiKeyPrefix + token
+	k.Prefix = key[:len(apiKeyPrefix)+apiKeyVisibleChars]
+	k.KeyHash = hashToken(key)
+
+	if _, err := s.apiKeysRepository().Add(&k); err != nil {
+		return err
+	}
+
+	*r = k.ToResultDto()
+	r.Key = key
+
+	return nil
+}
+
+// RemoveAPIKey revokes an api key of the user
+func (s *MyAuthService) RemoveAPIKey(id string, userID string) error {
+	if !s.apiKeysRepository().IsValidID(id) {
+		return &appErrors.BadRequestError{Msg: "Invalid id", InternalError: nil}
+	}
+
+	err := s.apiKeysRepository().Remove(stores.And(stores.Eq(stores.IDField, id), stores.Eq("userId", userID)))
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return &appErrors.NotFoundError{Model: "api key"}
+	}
+
+	return err
+}
+
+// RevokeUserAPIKeys revokes all the api keys of the user
+func (s *MyAuthService) RevokeUserAPIKeys(userID string) error {
+	keys := []models.APIKey{}
+	err := s.apiKeysRepository().Get(&keys, stores.Query{Filter: stores.Eq("userId", userID), Projection: stores.Fields("userId")})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := ignoreNotFound(s.apiKeysRepository().Remove(stores.Eq(stores.IDField, k.ID))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseAPIKey returns the claims of the user of a valid api key. Its permissions are the
+// scopes of the key which are still granted by the user role.
+func (s *MyAuthService) ParseAPIKey(key string) (*models.JwtClaimsInfo, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, getInvalidAPIKeyError()
+	}
+
+	k := models.APIKey{}
+	err := s.apiKeysRepository().GetOne(&k, stores.Query{Filter: stores.Eq("keyHash", hashToken(key))})
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return nil, getInvalidAPIKeyError()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if k.IsExpired(now()) {
+		return nil, getInvalidAPIKeyError()
+	}
+
+	u := models.User{}
+	err = s.usersRepository().GetOne(&u, stores.Query{Filter: stores.Eq(stores.IDField, k.UserID), Projection: stores.Fields("userName", "role")})
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return nil, getInvalidAPIKeyError()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := []string{}
+	for _, p := range models.RolePermissions(u.Role) {
+		if models.HasPermission(k.Scopes(), p) {
+			permissions = append(permissions, p)
+		}
+	}
+
+	info := models.JwtClaimsInfo{
+		UserID:      u.ID,
+		UserName:    u.UserName,
+		Role:        u.Role,
+		Permissions: permissions,
+	}
+	return &info, nil
+}
+
+func (s *MyAuthService) apiKeysRepository() stores.Repository {
+	return s.session.GetRepository("apiKeys")
+}
+
+// hashToken returns the hash stored for a random token, like an api key. The tokens are
+// random strings, so a fast hash is enough and lets them be found by it.
+func hashToken(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+func getInvalidAPIKeyError() error {
+	return &appErrors.UnauthorizedError{Msg: "Invalid api key"}
+}
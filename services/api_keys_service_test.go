@@ -0,0 +1,140 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthServiceAPIKeys(t *testing.T) {
+	session := stores.NewMyMemorySession()
+	service := NewMyAuthService(nil, session, DefaultAuthConfig())
+
+	currentTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	defer setNow(currentTime)()
+
+	u := models.User{UserName: "wadus", Role: models.UserRoleUser, IsActive: true}
+	_, err := session.GetRepository("users").Add(&u)
+	assert.Nil(t, err)
+
+	t.Run("AddAPIKey() should return a badRequestError when the name is empty", func(t *testing.T) {
+		err := service.AddAPIKey(u.ID, models.APIKeyDto{Scopes: []string{models.PermissionListsRead}}, &models.APIKeyResultDto{})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+	})
+
+	t.Run("AddAPIKey() should return a badRequestError when a scope isn't granted to the user", func(t *testing.T) {
+		dto := models.APIKeyDto{Name: "ci", Scopes: []string{models.PermissionListsRead, models.PermissionUsersManage}}
+		err := service.AddAPIKey(u.ID, dto, &models.APIKeyResultDto{})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, `The scope "users:manage" is not valid`, err.Error())
+	})
+
+	t.Run("AddAPIKey() should return a badRequestError when the key has already expired", func(t *testing.T) {
+		dto := models.APIKeyDto{Name: "ci", Scopes: []string{models.PermissionListsRead}, ExpiresAt: currentTime}
+		err := service.AddAPIKey(u.ID, dto, &models.APIKeyResultDto{})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+	})
+
+	t.Run("the api keys can be created, used, listed and revoked", func(t *testing.T) {
+		key := models.APIKeyResultDto{}
+		err := service.AddAPIKey(u.ID, models.APIKeyDto{Name: "ci", Scopes: []string{models.PermissionListsRead}}, &key)
+
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(key.Key, apiKeyPrefix))
+		assert.True(t, strings.HasPrefix(key.Key, key.Prefix))
+		assert.Equal(t, currentTime, key.CreatedAt)
+
+		info, err := service.ParseAPIKey(key.Key)
+		assert.Nil(t, err)
+		assert.Equal(t, &models.JwtClaimsInfo{UserID: u.ID, UserName: u.UserName, Role: u.Role, Permissions: []string{models.PermissionListsRead}}, info)
+
+		keys := []models.APIKeyResultDto{}
+		assert.Nil(t, service.GetAPIKeys(u.ID, &keys))
+		assert.Equal(t, 1, len(keys))
+		assert.Equal(t, key.ID, keys[0].ID)
+		assert.Equal(t, key.Prefix, keys[0].Prefix)
+		assert.Equal(t, []string{models.PermissionListsRead}, keys[0].Scopes)
+		assert.True(t, currentTime.Equal(keys[0].CreatedAt))
+		assert.True(t, keys[0].ExpiresAt.IsZero())
+		assert.Empty(t, keys[0].Key, "the key should only be returned when it is created")
+
+		stored := models.APIKey{}
+		assert.Nil(t, session.GetRepository("apiKeys").GetOne(&stored, stores.Query{Filter: stores.Eq(stores.IDField, key.ID)}))
+		assert.NotContains(t, stored.KeyHash, key.Key, "the key shouldn't be stored")
+
+		assert.IsType(t, &appErrors.NotFoundError{}, service.RemoveAPIKey(key.ID, "otherUser"))
+
+		assert.Nil(t, service.RemoveAPIKey(key.ID, u.ID))
+
+		_, err = service.ParseAPIKey(key.Key)
+		assert.IsType(t, &appErrors.UnauthorizedError{}, err, "the key should be revoked")
+	})
+
+	t.Run("ParseAPIKey() should return an unauthorizedError when the key has expired", func(t *testing.T) {
+		key := models.APIKeyResultDto{}
+		dto := models.APIKeyDto{Name: "ci", Scopes: []string{models.PermissionListsRead}, ExpiresAt: currentTime.Add(time.Hour)}
+		assert.Nil(t, service.AddAPIKey(u.ID, dto, &key))
+
+		defer setNow(currentTime.Add(time.Hour))()
+
+		_, err := service.ParseAPIKey(key.Key)
+		assert.IsType(t, &appErrors.UnauthorizedError{}, err)
+	})
+
+	t.Run("ParseAPIKey() should return an unauthorizedError when the key doesn't exist", func(t *testing.T) {
+		_, err := service.ParseAPIKey(apiKeyPrefix + "wadus")
+		assert.IsType(t, &appErrors.UnauthorizedError{}, err)
+
+		_, err = service.ParseAPIKey("wadus")
+		assert.IsType(t, &appErrors.UnauthorizedError{}, err)
+	})
+
+	t.Run("ParseAPIKey() should only grant the scopes still granted by the user role", func(t *testing.T) {
+		key := models.APIKeyResultDto{}
+		dto := models.APIKeyDto{Name: "bot", Scopes: []string{models.PermissionListsRead, models.PermissionListsWrite}}
+		assert.Nil(t, service.AddAPIKey(u.ID, dto, &key))
+
+		assert.Nil(t, session.GetRepository("users").Modify(stores.Eq(stores.IDField, u.ID), stores.Set("role", models.UserRoleReader)))
+
+		info, err := service.ParseAPIKey(key.Key)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{models.PermissionListsRead}, info.Permissions)
+	})
+
+	t.Run("RevokeUserAPIKeys() should revoke all the api keys of the user", func(t *testing.T) {
+		otherUser := models.User{UserName: "other", Role: models.UserRoleUser, IsActive: true}
+		_, err := session.GetRepository("users").Add(&otherUser)
+		assert.Nil(t, err)
+
+		other := models.APIKeyResultDto{}
+		assert.Nil(t, service.AddAPIKey(otherUser.ID, models.APIKeyDto{Name: "other", Scopes: []string{models.PermissionListsRead}}, &other))
+
+		first := models.APIKeyResultDto{}
+		second := models.APIKeyResultDto{}
+		assert.Nil(t, service.AddAPIKey(u.ID, models.APIKeyDto{Name: "first", Scopes: []string{models.PermissionListsRead}}, &first))
+		assert.Nil(t, service.AddAPIKey(u.ID, models.APIKeyDto{Name: "second", Scopes: []string{models.PermissionListsRead}}, &second))
+
+		assert.Nil(t, service.RevokeUserAPIKeys(u.ID))
+
+		keys := []models.APIKeyResultDto{}
+		assert.Nil(t, service.GetAPIKeys(u.ID, &keys))
+		assert.Empty(t, keys)
+
+		_, err = service.ParseAPIKey(second.Key)
+		assert.IsType(t, &appErrors.UnauthorizedError{}, err)
+
+		assert.Nil(t, service.GetAPIKeys(otherUser.ID, &keys))
+		assert.Equal(t, 1, len(keys), "the keys of the other users should be kept")
+
+		_, err = service.ParseAPIKey(other.Key)
+		assert.Nil(t, err)
+	})
+}
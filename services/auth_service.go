@@ -1,48 +1,207 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
 	"time"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
 	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
 )
 
 // AuthService is the interface an auth service must implement
 type AuthService interface {
 	CreateTokens(u *models.User) (map[string]string, error)
+	RefreshTokens(rtInfo *models.RefreshTokenClaimsInfo, u *models.User) (map[string]string, error)
 	ParseToken(token string) (*models.JwtClaimsInfo, error)
 	ParseRefreshToken(refreshTokenString string) (*models.RefreshTokenClaimsInfo, error)
+	RevokeRefreshToken(rtInfo *models.RefreshTokenClaimsInfo) error
+	RevokeUserRefreshTokens(userID string) error
+	GetJwks() models.JwksDto
+	GetAPIKeys(userID string, r *[]models.APIKeyResultDto) error
+	AddAPIKey(userID string, dto models.APIKeyDto, r *models.APIKeyResultDto) error
+	RemoveAPIKey(id string, userID string) error
+	RevokeUserAPIKeys(userID string) error
+	ParseAPIKey(key string) (*models.JwtClaimsInfo, error)
+	EnrollTotp(userID string, r *models.TotpEnrollmentDto) error
+	ConfirmTotp(userID string, code string, r *models.RecoveryCodesDto) error
+	DisableTotp(userID string, code string) error
+	CheckTotpCode(userID string, code string) error
+	CreateChallengeToken(u *models.User) (map[string]string, error)
+	ParseChallengeToken(challengeToken string) (string, error)
 }
 
+// AuthConfig contains the settings of the tokens. The issuer and the audience are only
+// added to the tokens and checked when they aren't empty.
+type AuthConfig struct {
+	TokenDuration        time.Duration
+	RefreshTokenDuration time.Duration
+	Issuer               string
+	Audience             string
+	// ChallengeTokenDuration is the time the users with the two-factor authentication
+	// enabled have to send a code after sending their password
+	ChallengeTokenDuration time.Duration
+	// ClockSkew is the difference allowed between the clocks of the servers when checking
+	// the times of the tokens
+	ClockSkew time.Duration
+}
+
+// DefaultAuthConfig returns the default settings of the tokens
+func DefaultAuthConfig() AuthConfig {
+	return AuthConfig{
+		TokenDuration:          time.Minute * 15,
+		RefreshTokenDuration:   time.Hour * 24,
+		ChallengeTokenDuration: time.Minute * 5,
+		ClockSkew:              time.Second * 30,
+	}
+}
+
+// the values of the tokenType claim, so a refresh token can't be used as a jwt token
+const (
+	accessTokenType    = "access"
+	refreshTokenType   = "refresh"
+	challengeTokenType = "challenge"
+)
+
+// refreshTokenIDBytes is the number of random bytes of a refresh token id
+const refreshTokenIDBytes = 16
+
 // MyAuthService is the service for auth methods
 type MyAuthService struct {
-	jwtPrv JwtProvider
+	jwtPrv  JwtProvider
+	session stores.MongoSession
+	config  AuthConfig
 }
 
 // NewMyAuthService returns a new auth service
-func NewMyAuthService(jwtp JwtProvider) *MyAuthService {
-	return &MyAuthService{jwtp}
+func NewMyAuthService(jwtp JwtProvider, s stores.MongoSession, config AuthConfig) *MyAuthService {
+	return &MyAuthService{jwtp, s, config}
 }
 
-// CreateTokens returns a new jwt token and a refresh token for the given user
+// CreateTokens returns a new jwt token and a refresh token for the given user. The
+// refresh token starts a new family of refresh tokens.
 func (s *MyAuthService) CreateTokens(u *models.User) (map[string]string, error) {
+	st, err := s.signToken(u)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenID, err := newRandomToken(refreshTokenIDBytes)
+	if err != nil {
+		return nil, &appErrors.UnexpectedError{Msg: "Error creating jwt refresh token", InternalError: err}
+	}
+
+	f := models.RefreshTokenFamily{
+		UserID:    u.ID,
+		TokenID:   tokenID,
+		CreatedAt: now(),
+		ExpiresAt: now().Add(s.config.RefreshTokenDuration),
+	}
+	if _, err := s.refreshTokensRepository().Add(&f); err != nil {
+		return nil, err
+	}
+
+	return s.getTokens(st, &f)
+}
+
+// RefreshTokens returns a new jwt token and replaces the refresh token by a new one of the
+// same family. When the refresh token has already been used it could have been stolen, so
+// the whole family is revoked.
+func (s *MyAuthService) RefreshTokens(rtInfo *models.RefreshTokenClaimsInfo, u *models.User) (map[string]string, error) {
+	if !s.refreshTokensRepository().IsValidID(rtInfo.FamilyID) {
+		return nil, getInvalidRefreshTokenError()
+	}
+
+	st, err := s.signToken(u)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenID, err := newRandomToken(refreshTokenIDBytes)
+	if err != nil {
+		return nil, &appErrors.UnexpectedError{Msg: "Error creating jwt refresh token", InternalError: err}
+	}
+
+	f := models.RefreshTokenFamily{
+		ID:        rtInfo.FamilyID,
+		UserID:    u.ID,
+		TokenID:   tokenID,
+		ExpiresAt: now().Add(s.config.RefreshTokenDuration),
+	}
+	filter := stores.And(refreshTokenFamilyFilter(rtInfo), stores.Eq("tokenId", rtInfo.ID))
+	err = s.refreshTokensRepository().Modify(filter, stores.Set("tokenId", f.TokenID), stores.Set("expiresAt", f.ExpiresAt))
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		if err := s.RevokeRefreshToken(rtInfo); err != nil {
+			return nil, err
+		}
+		return nil, getInvalidRefreshTokenError()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getTokens(st, &f)
+}
+
+// RevokeRefreshToken revokes the family of the refresh token
+func (s *MyAuthService) RevokeRefreshToken(rtInfo *models.RefreshTokenClaimsInfo) error {
+	if !s.refreshTokensRepository().IsValidID(rtInfo.FamilyID) {
+		return nil
+	}
+
+	return ignoreNotFound(s.refreshTokensRepository().Remove(refreshTokenFamilyFilter(rtInfo)))
+}
+
+// RevokeUserRefreshTokens revokes all the refresh tokens of the user
+func (s *MyAuthService) RevokeUserRefreshTokens(userID string) error {
+	families := []models.RefreshTokenFamily{}
+	err := s.refreshTokensRepository().Get(&families, stores.Query{Filter: stores.Eq("userId", userID), Projection: stores.Fields("userId")})
+	if err != nil {
+		return err
+	}
+
+	for _, f := range families {
+		if err := ignoreNotFound(s.refreshTokensRepository().Remove(stores.Eq(stores.IDField, f.ID))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetJwks returns the public keys which can be used to verify the tokens
+func (s *MyAuthService) GetJwks() models.JwksDto {
+	return s.jwtPrv.GetJwks()
+}
+
+// signToken returns a new signed jwt token for the given user
+func (s *MyAuthService) signToken(u *models.User) (string, error) {
 	t := s.jwtPrv.NewToken()
 
 	tc := s.jwtPrv.GetTokenClaims(t)
 	tc["userName"] = u.UserName
-	tc["isAdmin"] = u.IsAdmin
+	tc["role"] = u.Role
+	tc["permissions"] = models.RolePermissions(u.Role)
 	tc["userId"] = u.ID
-	tc["exp"] = time.Now().Add(time.Minute * 15).Unix()
+	s.addStandardClaims(tc, accessTokenType, now().Add(s.config.TokenDuration))
 
 	st, err := s.jwtPrv.SignToken(t)
 	if err != nil {
-		return nil, &appErrors.UnexpectedError{Msg: "Error creating jwt token", InternalError: err}
+		return "", &appErrors.UnexpectedError{Msg: "Error creating jwt token", InternalError: err}
 	}
 
+	return st, nil
+}
+
+// getTokens returns the signed token with the last refresh token of the family
+func (s *MyAuthService) getTokens(st string, f *models.RefreshTokenFamily) (map[string]string, error) {
 	rt := s.jwtPrv.NewToken()
 	rtc := s.jwtPrv.GetTokenClaims(rt)
-	rtc["userId"] = u.ID
-	rtc["exp"] = time.Now().Add(time.Hour * 24).Unix()
+	rtc["jti"] = f.TokenID
+	rtc["fid"] = f.ID
+	rtc["userId"] = f.UserID
+	s.addStandardClaims(rtc, refreshTokenType, f.ExpiresAt)
 
 	srt, err := s.jwtPrv.SignToken(rt)
 	if err != nil {
@@ -57,6 +216,80 @@ func (s *MyAuthService) CreateTokens(u *models.User) (map[string]string, error)
 	return result, nil
 }
 
+// addStandardClaims adds the type, the times, the issuer and the audience to the claims
+func (s *MyAuthService) addStandardClaims(claims map[string]interface{}, tokenType string, expiresAt time.Time) {
+	n := now()
+
+	claims["tokenType"] = tokenType
+	claims["iat"] = n.Unix()
+	claims["nbf"] = n.Unix()
+	claims["exp"] = expiresAt.Unix()
+
+	if s.config.Issuer != "" {
+		claims["iss"] = s.config.Issuer
+	}
+
+	if s.config.Audience != "" {
+		claims["aud"] = s.config.Audience
+	}
+}
+
+// validateClaims returns an error when the claims aren't the ones of a valid token of the type
+func (s *MyAuthService) validateClaims(claims map[string]interface{}, tokenType string) error {
+	if parseStringClaim(claims["tokenType"]) != tokenType {
+		return errors.New("the token type is not valid")
+	}
+
+	n := now()
+
+	exp, ok := parseTimeClaim(claims["exp"])
+	if !ok || n.After(exp.Add(s.config.ClockSkew)) {
+		return errors.New("the token is expired")
+	}
+
+	if nbf, ok := parseTimeClaim(claims["nbf"]); ok && n.Before(nbf.Add(-s.config.ClockSkew)) {
+		return errors.New("the token is not valid yet")
+	}
+
+	if iat, ok := parseTimeClaim(claims["iat"]); ok && n.Before(iat.Add(-s.config.ClockSkew)) {
+		return errors.New("the token was issued in the future")
+	}
+
+	if s.config.Issuer != "" && parseStringClaim(claims["iss"]) != s.config.Issuer {
+		return errors.New("the token issuer is not valid")
+	}
+
+	if s.config.Audience != "" && !hasAudience(claims["aud"], s.config.Audience) {
+		return errors.New("the token audience is not valid")
+	}
+
+	return nil
+}
+
+func (s *MyAuthService) refreshTokensRepository() stores.Repository {
+	return s.session.GetRepository("refreshTokens")
+}
+
+func (s *MyAuthService) usersRepository() stores.Repository {
+	return s.session.GetRepository("users")
+}
+
+func refreshTokenFamilyFilter(rtInfo *models.RefreshTokenClaimsInfo) stores.Filter {
+	return stores.And(stores.Eq(stores.IDField, rtInfo.FamilyID), stores.Eq("userId", rtInfo.UserID))
+}
+
+func getInvalidRefreshTokenError() error {
+	return &appErrors.UnauthorizedError{Msg: "Invalid refresh token"}
+}
+
+func ignoreNotFound(err error) error {
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return nil
+	}
+
+	return err
+}
+
 // ParseToken takes a token string, parses it and if it is valid returns a JwtClaimsInfo
 // with its claims values
 func (s *MyAuthService) ParseToken(tokenString string) (*models.JwtClaimsInfo, error) {
@@ -69,6 +302,10 @@ func (s *MyAuthService) ParseToken(tokenString string) (*models.JwtClaimsInfo, e
 		return nil, &appErrors.UnauthorizedError{Msg: "Invalid token"}
 	}
 
+	if err := s.validateClaims(s.jwtPrv.GetTokenClaims(token), accessTokenType); err != nil {
+		return nil, &appErrors.UnauthorizedError{Msg: "Invalid token", InternalError: err}
+	}
+
 	return s.getJwtInfo(token), nil
 }
 
@@ -84,6 +321,10 @@ func (s *MyAuthService) ParseRefreshToken(refreshTokenString string) (*models.Re
 		return nil, &appErrors.UnauthorizedError{Msg: "Invalid refresh token"}
 	}
 
+	if err := s.validateClaims(s.jwtPrv.GetTokenClaims(refreshToken), refreshTokenType); err != nil {
+		return nil, &appErrors.UnauthorizedError{Msg: "Invalid refresh token", InternalError: err}
+	}
+
 	return s.getRefreshTokenInfo(refreshToken), nil
 }
 
@@ -92,9 +333,10 @@ func (s *MyAuthService) getJwtInfo(token interface{}) *models.JwtClaimsInfo {
 	claims := s.jwtPrv.GetTokenClaims(token)
 
 	info := models.JwtClaimsInfo{
-		UserName: parseStringClaim(claims["userName"]),
-		UserID:       parseStringClaim(claims["userId"]),
-		IsAdmin:  parseBoolClaim(claims["isAdmin"]),
+		UserName:    parseStringClaim(claims["userName"]),
+		UserID:      parseStringClaim(claims["userId"]),
+		Role:        parseStringClaim(claims["role"]),
+		Permissions: parseStringsClaim(claims["permissions"]),
 	}
 	return &info
 }
@@ -104,7 +346,9 @@ func (s *MyAuthService) getRefreshTokenInfo(refreshToken interface{}) *models.Re
 	claims := s.jwtPrv.GetTokenClaims(refreshToken)
 
 	info := models.RefreshTokenClaimsInfo{
-		UserID: parseStringClaim(claims["userId"]),
+		ID:       parseStringClaim(claims["jti"]),
+		FamilyID: parseStringClaim(claims["fid"]),
+		UserID:   parseStringClaim(claims["userId"]),
 	}
 	return &info
 }
@@ -114,7 +358,53 @@ func parseStringClaim(value interface{}) string {
 	return result
 }
 
-func parseBoolClaim(value interface{}) bool {
-	result, _ := value.(bool)
-	return result
+// parseStringsClaim returns the strings of an array claim, which is a []interface{} when the
+// claims come from a parsed token
+func parseStringsClaim(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		result := []string{}
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+
+	return nil
+}
+
+// parseTimeClaim returns the time of a numeric date claim, which is a float64 when the
+// claims come from a parsed token
+func parseTimeClaim(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case int64:
+		return time.Unix(v, 0), true
+	case json.Number:
+		n, err := v.Int64()
+		return time.Unix(n, 0), err == nil
+	}
+
+	return time.Time{}, false
+}
+
+// hasAudience returns true if the aud claim, a string or an array of strings, contains the audience
+func hasAudience(value interface{}, audience string) bool {
+	switch v := value.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if a == audience {
+				return true
+			}
+		}
+	}
+
+	return false
 }
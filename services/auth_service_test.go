@@ -3,11 +3,13 @@ package services
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
 	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -47,12 +49,20 @@ func (m *mockedJwtProvider) IsTokenValid(token interface{}) bool {
 	return args.Bool(0)
 }
 
+func (m *mockedJwtProvider) GetJwks() models.JwksDto {
+	args := m.Called()
+	return args.Get(0).(models.JwksDto)
+}
+
 func TestAuthServiceCreateToken(t *testing.T) {
 	mockedJwtProvider := new(mockedJwtProvider)
+	mockedRepository := new(mockedRepository)
+	mockedSession := new(mockedMongoSession)
+	mockedSession.On("GetRepository", "refreshTokens").Return(mockedRepository)
 
-	service := NewMyAuthService(mockedJwtProvider)
+	service := NewMyAuthService(mockedJwtProvider, mockedSession, DefaultAuthConfig())
 
-	u := models.User{}
+	u := models.User{ID: "userId"}
 	token := struct{}{}
 	claims := map[string]interface{}{}
 	refreshToken := struct{}{}
@@ -79,6 +89,7 @@ func TestAuthServiceCreateToken(t *testing.T) {
 		mockedJwtProvider.On("NewToken").Return(refreshToken).Once()
 		mockedJwtProvider.On("GetTokenClaims", refreshToken).Return(refreshTokenClaims).Once()
 		mockedJwtProvider.On("SignToken", token).Return("token", nil).Once()
+		mockedRepository.On("Add", mock.AnythingOfType("*models.RefreshTokenFamily")).Return("fid", nil).Once()
 		mockedJwtProvider.On("SignToken", refreshToken).Return("", errors.New("wadus")).Once()
 
 		tokens, err := service.CreateTokens(&u)
@@ -99,6 +110,9 @@ func TestAuthServiceCreateToken(t *testing.T) {
 		mockedJwtProvider.On("NewToken").Return(refreshToken).Once()
 		mockedJwtProvider.On("GetTokenClaims", refreshToken).Return(refreshTokenClaims).Once()
 		mockedJwtProvider.On("SignToken", token).Return(theToken, nil).Once()
+		mockedRepository.On("Add", mock.AnythingOfType("*models.RefreshTokenFamily")).Return("fid", nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.RefreshTokenFamily).ID = "fid"
+		})
 		mockedJwtProvider.On("SignToken", refreshToken).Return(theRefreshToken, nil).Once()
 
 		tokens, err := service.CreateTokens(&u)
@@ -110,15 +124,19 @@ func TestAuthServiceCreateToken(t *testing.T) {
 
 		assert.Equal(t, want, tokens)
 		assert.Nil(t, err)
+		assert.Equal(t, "fid", refreshTokenClaims["fid"])
+		assert.Equal(t, "userId", refreshTokenClaims["userId"])
+		assert.NotEmpty(t, refreshTokenClaims["jti"])
 
 		mockedJwtProvider.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
 	})
 }
 
 func TestAuthServiceParseToken(t *testing.T) {
 	mockedJwtProvider := new(mockedJwtProvider)
 
-	service := NewMyAuthService(mockedJwtProvider)
+	service := NewMyAuthService(mockedJwtProvider, new(mockedMongoSession), DefaultAuthConfig())
 
 	theToken := "theToken"
 
@@ -155,20 +173,24 @@ func TestAuthServiceParseToken(t *testing.T) {
 		token := struct{}{}
 
 		jwtInfo := models.JwtClaimsInfo{
-			UserName: "wadus",
-			IsAdmin:  true,
-			UserID:       "11",
+			UserName:    "wadus",
+			UserID:      "11",
+			Role:        models.UserRoleAdmin,
+			Permissions: []string{models.PermissionListsRead},
 		}
 
 		mockedJwtProvider.On("ParseToken", theToken).Return(token, nil).Once()
 		mockedJwtProvider.On("IsTokenValid", token).Return(true).Once()
 
 		c := map[string]interface{}{
-			"userName": "wadus",
-			"isAdmin":  true,
-			"userId":   "11",
+			"userName":    "wadus",
+			"role":        "admin",
+			"permissions": []interface{}{"lists:read"},
+			"userId":      "11",
+			"tokenType":   "access",
+			"exp":         float64(time.Now().Add(time.Minute).Unix()),
 		}
-		mockedJwtProvider.On("GetTokenClaims", token).Return(c).Once()
+		mockedJwtProvider.On("GetTokenClaims", token).Return(c).Twice()
 
 		res, err := service.ParseToken(theToken)
 
@@ -181,7 +203,7 @@ func TestAuthServiceParseToken(t *testing.T) {
 func TestAuthServiceParseRefreshToken(t *testing.T) {
 	mockedJwtProvider := new(mockedJwtProvider)
 
-	service := NewMyAuthService(mockedJwtProvider)
+	service := NewMyAuthService(mockedJwtProvider, new(mockedMongoSession), DefaultAuthConfig())
 
 	theRefreshToken := "theRefreshToken"
 
@@ -218,16 +240,22 @@ func TestAuthServiceParseRefreshToken(t *testing.T) {
 		refreshToken := struct{}{}
 
 		rtInfo := models.RefreshTokenClaimsInfo{
-			UserID: "id",
+			ID:       "jti",
+			FamilyID: "fid",
+			UserID:   "id",
 		}
 
 		mockedJwtProvider.On("ParseToken", theRefreshToken).Return(refreshToken, nil).Once()
 		mockedJwtProvider.On("IsTokenValid", refreshToken).Return(true).Once()
 
 		c := map[string]interface{}{
-			"userId": rtInfo.UserID,
+			"jti":       rtInfo.ID,
+			"fid":       rtInfo.FamilyID,
+			"userId":    rtInfo.UserID,
+			"tokenType": "refresh",
+			"exp":       float64(time.Now().Add(time.Minute).Unix()),
 		}
-		mockedJwtProvider.On("GetTokenClaims", refreshToken).Return(c).Once()
+		mockedJwtProvider.On("GetTokenClaims", refreshToken).Return(c).Twice()
 
 		res, err := service.ParseRefreshToken(theRefreshToken)
 
@@ -240,11 +268,15 @@ func TestAuthServiceParseRefreshToken(t *testing.T) {
 func TestAuthServiceJwtProviderIntegration(t *testing.T) {
 	jwtPrv := NewMyJwtProvider("theSecret")
 
-	service := NewMyAuthService(jwtPrv)
+	config := DefaultAuthConfig()
+	config.Issuer = "issuer"
+	config.Audience = "audience"
+
+	service := NewMyAuthService(jwtPrv, stores.NewMyMemorySession(), config)
 
 	u := models.User{
 		UserName: "wadus",
-		IsAdmin:  true,
+		Role:     models.UserRoleUser,
 		ID:       "theId",
 	}
 
@@ -257,12 +289,117 @@ func TestAuthServiceJwtProviderIntegration(t *testing.T) {
 	assert.Nil(t, err)
 
 	assert.Equal(t, u.UserName, jwtInfo.UserName)
-	assert.Equal(t, u.IsAdmin, jwtInfo.IsAdmin)
+	assert.Equal(t, u.Role, jwtInfo.Role)
+	assert.Equal(t, []string{models.PermissionListsRead, models.PermissionListsWrite}, jwtInfo.Permissions)
 	assert.Equal(t, u.ID, jwtInfo.UserID)
 
 	rtClaims, err := service.ParseRefreshToken(tokens["refreshToken"])
 	assert.NotNil(t, rtClaims)
 	assert.Nil(t, err)
 
+	_, err = service.ParseToken(tokens["refreshToken"])
+	assert.IsType(t, &appErrors.UnauthorizedError{}, err, "a refresh token can't be used as a jwt token")
+
+	_, err = service.ParseRefreshToken(tokens["token"])
+	assert.IsType(t, &appErrors.UnauthorizedError{}, err, "a jwt token can't be used as a refresh token")
+
+	otherConfig := config
+	otherConfig.Audience = "other"
+	_, err = NewMyAuthService(jwtPrv, nil, otherConfig).ParseToken(tokens["token"])
+	assert.IsType(t, &appErrors.UnauthorizedError{}, err, "the audience should be checked")
+
+	otherConfig = config
+	otherConfig.Issuer = "other"
+	_, err = NewMyAuthService(jwtPrv, nil, otherConfig).ParseToken(tokens["token"])
+	assert.IsType(t, &appErrors.UnauthorizedError{}, err, "the issuer should be checked")
+
 	assert.Equal(t, u.ID, rtClaims.UserID)
+
+	refreshed, err := service.RefreshTokens(rtClaims, &u)
+	assert.Nil(t, err)
+
+	newRtClaims, err := service.ParseRefreshToken(refreshed["refreshToken"])
+	assert.Nil(t, err)
+	assert.Equal(t, rtClaims.FamilyID, newRtClaims.FamilyID)
+	assert.NotEqual(t, rtClaims.ID, newRtClaims.ID)
+
+	_, err = service.RefreshTokens(rtClaims, &u)
+	assert.IsType(t, &appErrors.UnauthorizedError{}, err, "reusing a refresh token should fail")
+
+	_, err = service.RefreshTokens(newRtClaims, &u)
+	assert.IsType(t, &appErrors.UnauthorizedError{}, err, "the reuse should revoke the family")
+
+	tokens, err = service.CreateTokens(&u)
+	assert.Nil(t, err)
+
+	rtClaims, err = service.ParseRefreshToken(tokens["refreshToken"])
+	assert.Nil(t, err)
+
+	assert.Nil(t, service.RevokeRefreshToken(rtClaims))
+
+	_, err = service.RefreshTokens(rtClaims, &u)
+	assert.IsType(t, &appErrors.UnauthorizedError{}, err, "the refresh token should be revoked")
+
+	tokens, _ = service.CreateTokens(&u)
+	rtClaims, _ = service.ParseRefreshToken(tokens["refreshToken"])
+
+	assert.Nil(t, service.RevokeUserRefreshTokens(u.ID))
+
+	_, err = service.RefreshTokens(rtClaims, &u)
+	assert.IsType(t, &appErrors.UnauthorizedError{}, err, "all the refresh tokens should be revoked")
+}
+
+func TestAuthServiceValidateClaims(t *testing.T) {
+	config := DefaultAuthConfig()
+	config.Audience = "audience"
+	service := NewMyAuthService(nil, nil, config)
+
+	n := time.Now()
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"tokenType": "access",
+			"exp":       float64(n.Add(time.Minute).Unix()),
+			"nbf":       float64(n.Unix()),
+			"iat":       float64(n.Unix()),
+			"aud":       []interface{}{"other", "audience"},
+		}
+	}
+
+	t.Run("accepts the valid claims", func(t *testing.T) {
+		assert.Nil(t, service.validateClaims(validClaims(), "access"))
+	})
+
+	t.Run("allows the clock skew", func(t *testing.T) {
+		c := validClaims()
+		c["exp"] = float64(n.Add(-10 * time.Second).Unix())
+		c["nbf"] = float64(n.Add(10 * time.Second).Unix())
+		c["iat"] = float64(n.Add(10 * time.Second).Unix())
+
+		assert.Nil(t, service.validateClaims(c, "access"))
+	})
+
+	t.Run("rejects the expired tokens", func(t *testing.T) {
+		c := validClaims()
+		c["exp"] = float64(n.Add(-time.Minute).Unix())
+
+		assert.NotNil(t, service.validateClaims(c, "access"))
+
+		delete(c, "exp")
+		assert.NotNil(t, service.validateClaims(c, "access"))
+	})
+
+	t.Run("rejects the tokens which aren't valid yet", func(t *testing.T) {
+		c := validClaims()
+		c["nbf"] = float64(n.Add(time.Minute).Unix())
+
+		assert.NotNil(t, service.validateClaims(c, "access"))
+	})
+
+	t.Run("rejects the tokens of other type or audience", func(t *testing.T) {
+		assert.NotNil(t, service.validateClaims(validClaims(), "refresh"))
+
+		c := validClaims()
+		c["aud"] = "other"
+		assert.NotNil(t, service.validateClaims(c, "access"))
+	})
 }
@@ -0,0 +1,9 @@
+package services
+
+import "time"
+
+// now returns the current time. The stores keep the times with millisecond
+// precision, so it is truncated to avoid differences after saving them.
+var now = func() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
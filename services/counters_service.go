@@ -3,13 +3,13 @@ package services
 import (
 	"github.com/AngelVlc/lists-backend/models"
 	"github.com/AngelVlc/lists-backend/stores"
-	"gopkg.in/mgo.v2/bson"
 )
 
 // CountersService contains the methods for working with counters
 type CountersService interface {
 	AddCounter(name string) error
 	IncrementCounter(name string) error
+	IncrementCounterBy(name string, value int) error
 	ExistsCounter(name string) bool
 	GetCounterValue(name string) (int, error)
 }
@@ -38,7 +38,7 @@ func (s *MyCountersService) AddCounter(name string) error {
 
 // ExistsCounter returns true if the counter already exist
 func (s *MyCountersService) ExistsCounter(name string) bool {
-	err := s.countersRepository().GetOne(&models.Counter{}, bson.D{{"name", name}}, nil)
+	err := s.countersRepository().GetOne(&models.Counter{}, stores.Query{Filter: stores.Eq("name", name)})
 
 	if err == nil {
 		return true
@@ -50,7 +50,7 @@ func (s *MyCountersService) ExistsCounter(name string) bool {
 // GetCounterValue returns a counter's value
 func (s *MyCountersService) GetCounterValue(name string) (int, error) {
 	c := models.Counter{}
-	err := s.countersRepository().GetOne(&c, bson.D{{"name", name}}, nil)
+	err := s.countersRepository().GetOne(&c, stores.Query{Filter: stores.Eq("name", name)})
 
 	if err == nil {
 		return c.Value, nil
@@ -61,7 +61,12 @@ func (s *MyCountersService) GetCounterValue(name string) (int, error) {
 
 // IncrementCounter increments a counter
 func (s *MyCountersService) IncrementCounter(name string) error {
-	return s.countersRepository().Update(bson.D{{"name", name}}, bson.M{"$inc": bson.M{"value": 1}})
+	return s.IncrementCounterBy(name, 1)
+}
+
+// IncrementCounterBy adds the value to a counter
+func (s *MyCountersService) IncrementCounterBy(name string, value int) error {
+	return s.countersRepository().Modify(stores.Eq("name", name), stores.Inc("value", value))
 }
 
 func (s *MyCountersService) countersRepository() stores.Repository {
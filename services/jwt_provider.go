@@ -1,8 +1,16 @@
 package services
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"math/big"
+	"sort"
 
+	"github.com/AngelVlc/lists-backend/models"
 	"github.com/dgrijalva/jwt-go"
 )
 
@@ -13,21 +21,77 @@ type JwtProvider interface {
 	SignToken(token interface{}) (string, error)
 	ParseToken(tokenString string) (interface{}, error)
 	IsTokenValid(token interface{}) bool
+	GetJwks() models.JwksDto
 }
 
-// MyJwtProvider is the type used as JwtProvider
+// MyJwtProvider is the type used as JwtProvider. It signs the tokens with a shared
+// secret or with a private key. In the last case the tokens contain the id of the key
+// and can be verified with the public keys of the previous key pairs too.
 type MyJwtProvider struct {
-	secret string
+	method     jwt.SigningMethod
+	signingKey interface{}
+	keyID      string
+	publicKeys map[string]crypto.PublicKey
 }
 
-// NewMyJwtProvider returns a new MyJwtProvider
+// NewMyJwtProvider returns a new MyJwtProvider which uses HS256 with the secret
 func NewMyJwtProvider(secret string) *MyJwtProvider {
-	return &MyJwtProvider{secret}
+	return &MyJwtProvider{method: jwt.SigningMethodHS256, signingKey: []byte(secret)}
+}
+
+// NewMyKeyPairJwtProvider returns a new MyJwtProvider which signs the tokens with the RSA
+// or ECDSA private key in PEM format. The other public keys, indexed by their key id, are
+// used only to verify the tokens signed before rotating the key pair.
+func NewMyKeyPairJwtProvider(privateKeyPEM []byte, keyID string, publicKeysPEM map[string][]byte) (*MyJwtProvider, error) {
+	if keyID == "" {
+		return nil, errors.New("the key id is mandatory")
+	}
+
+	var signingKey interface{}
+	var publicKey crypto.PublicKey
+	if k, err := jwt.ParseRSAPrivateKeyFromPEM(privateKeyPEM); err == nil {
+		signingKey, publicKey = k, &k.PublicKey
+	} else if k, err := jwt.ParseECPrivateKeyFromPEM(privateKeyPEM); err == nil {
+		signingKey, publicKey = k, &k.PublicKey
+	} else {
+		return nil, errors.New("the private key must be a RSA or ECDSA key in PEM format")
+	}
+
+	method, err := getSigningMethod(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	p := MyJwtProvider{
+		method:     method,
+		signingKey: signingKey,
+		keyID:      keyID,
+		publicKeys: map[string]crypto.PublicKey{keyID: publicKey},
+	}
+
+	for kid, pem := range publicKeysPEM {
+		if _, exists := p.publicKeys[kid]; exists {
+			return nil, fmt.Errorf("the key id %q is duplicated", kid)
+		}
+
+		k, err := parsePublicKey(pem)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %q: %v", kid, err)
+		}
+		p.publicKeys[kid] = k
+	}
+
+	return &p, nil
 }
 
 // NewToken returns a new Jwt tooken
 func (p *MyJwtProvider) NewToken() interface{} {
-	return jwt.New(jwt.SigningMethodHS256)
+	t := jwt.New(p.method)
+	if p.keyID != "" {
+		t.Header["kid"] = p.keyID
+	}
+
+	return t
 }
 
 func (p *MyJwtProvider) getJwtToken(token interface{}) *jwt.Token {
@@ -42,20 +106,120 @@ func (p *MyJwtProvider) GetTokenClaims(token interface{}) map[string]interface{}
 
 // SignToken signs the given token
 func (p *MyJwtProvider) SignToken(token interface{}) (string, error) {
-	return p.getJwtToken(token).SignedString([]byte(p.secret))
+	return p.getJwtToken(token).SignedString(p.signingKey)
 }
 
-// ParseToken parses the string and checks the signing method
+// ParseToken parses the string and checks the signing method. The claims aren't validated,
+// so the times can be checked allowing some clock skew.
 func (p *MyJwtProvider) ParseToken(tokenString string) (interface{}, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	return parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if p.publicKeys == nil {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+			}
+			return p.signingKey, nil
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		key, ok := p.publicKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("Unknown key id: %v", token.Header["kid"])
+		}
+
+		// the algorithm must be the one of the key to avoid accepting tokens signed with
+		// the public key as a HMAC secret
+		if method, _ := getSigningMethod(key); method == nil || method.Alg() != token.Method.Alg() {
 			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(p.secret), nil
+
+		return key, nil
 	})
 }
 
-// IsTokenValid returns true if the given token is valid
+// IsTokenValid returns true if the signature of the given token is valid
 func (p *MyJwtProvider) IsTokenValid(token interface{}) bool {
 	return p.getJwtToken(token).Valid
 }
+
+// GetJwks returns the public keys used to verify the tokens as a JSON Web Key Set. It's
+// empty when the tokens are signed with a shared secret.
+func (p *MyJwtProvider) GetJwks() models.JwksDto {
+	kids := []string{}
+	for kid := range p.publicKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	jwks := models.JwksDto{Keys: []models.JSONWebKey{}}
+	for _, kid := range kids {
+		jwks.Keys = append(jwks.Keys, toJSONWebKey(kid, p.publicKeys[kid]))
+	}
+
+	return jwks
+}
+
+func parsePublicKey(pem []byte) (crypto.PublicKey, error) {
+	if k, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return k, nil
+	}
+
+	if k, err := jwt.ParseECPublicKeyFromPEM(pem); err == nil {
+		if _, err := getSigningMethod(k); err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+
+	return nil, errors.New("the key must be a RSA or ECDSA public key in PEM format")
+}
+
+// getSigningMethod returns the signing method used with the key
+func getSigningMethod(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().Name {
+		case "P-256":
+			return jwt.SigningMethodES256, nil
+		case "P-384":
+			return jwt.SigningMethodES384, nil
+		case "P-521":
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported curve %v", k.Curve.Params().Name)
+	}
+
+	return nil, errors.New("unsupported key type")
+}
+
+func toJSONWebKey(kid string, key crypto.PublicKey) models.JSONWebKey {
+	method, _ := getSigningMethod(key)
+
+	jwk := models.JSONWebKey{KeyID: kid, Use: "sig", Algorithm: method.Alg()}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = k.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(padBytes(k.X.Bytes(), size))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(padBytes(k.Y.Bytes(), size))
+	}
+
+	return jwk
+}
+
+// padBytes adds leading zeros to b until it has the given size
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+
+	return append(make([]byte, size-len(b)), b...)
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRSAKeyPEMs(t *testing.T) ([]byte, []byte) {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	return toTestKeyPEMs(t, &k.PublicKey, pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)})
+}
+
+func newTestECKeyPEMs(t *testing.T) ([]byte, []byte) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	der, err := x509.MarshalECPrivateKey(k)
+	assert.Nil(t, err)
+
+	return toTestKeyPEMs(t, &k.PublicKey, pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func toTestKeyPEMs(t *testing.T, public interface{}, privateBlock pem.Block) ([]byte, []byte) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	assert.Nil(t, err)
+
+	return pem.EncodeToMemory(&privateBlock), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func signTestToken(t *testing.T, p *MyJwtProvider) string {
+	token := p.NewToken()
+	p.GetTokenClaims(token)["userId"] = "id"
+
+	st, err := p.SignToken(token)
+	assert.Nil(t, err)
+
+	return st
+}
+
+func TestJwtProvider(t *testing.T) {
+	rsaPrivate, rsaPublic := newTestRSAKeyPEMs(t)
+	ecPrivate, ecPublic := newTestECKeyPEMs(t)
+
+	t.Run("signs and verifies the tokens with a secret", func(t *testing.T) {
+		p := NewMyJwtProvider("theSecret")
+
+		token, err := p.ParseToken(signTestToken(t, p))
+
+		assert.Nil(t, err)
+		assert.True(t, p.IsTokenValid(token))
+		assert.Empty(t, p.GetJwks().Keys)
+	})
+
+	t.Run("signs and verifies the tokens with a RSA key", func(t *testing.T) {
+		p, err := NewMyKeyPairJwtProvider(rsaPrivate, "rsa", nil)
+		assert.Nil(t, err)
+
+		token, err := p.ParseToken(signTestToken(t, p))
+
+		assert.Nil(t, err)
+		assert.True(t, p.IsTokenValid(token))
+		assert.Equal(t, "RS256", p.getJwtToken(token).Header["alg"])
+		assert.Equal(t, "rsa", p.getJwtToken(token).Header["kid"])
+	})
+
+	t.Run("signs and verifies the tokens with an ECDSA key", func(t *testing.T) {
+		p, err := NewMyKeyPairJwtProvider(ecPrivate, "ec", nil)
+		assert.Nil(t, err)
+
+		token, err := p.ParseToken(signTestToken(t, p))
+
+		assert.Nil(t, err)
+		assert.True(t, p.IsTokenValid(token))
+		assert.Equal(t, "ES256", p.getJwtToken(token).Header["alg"])
+	})
+
+	t.Run("verifies the tokens signed with the previous keys", func(t *testing.T) {
+		old, err := NewMyKeyPairJwtProvider(rsaPrivate, "rsa", nil)
+		assert.Nil(t, err)
+
+		p, err := NewMyKeyPairJwtProvider(ecPrivate, "ec", map[string][]byte{"rsa": rsaPublic})
+		assert.Nil(t, err)
+
+		_, err = p.ParseToken(signTestToken(t, old))
+		assert.Nil(t, err)
+
+		_, err = old.ParseToken(signTestToken(t, p))
+		assert.NotNil(t, err, "the key id is unknown")
+	})
+
+	t.Run("rejects the tokens signed with other algorithms", func(t *testing.T) {
+		p, err := NewMyKeyPairJwtProvider(rsaPrivate, "rsa", nil)
+		assert.Nil(t, err)
+
+		hmac := NewMyJwtProvider(string(rsaPublic))
+		hmac.keyID = "rsa"
+
+		_, err = p.ParseToken(signTestToken(t, hmac))
+		assert.NotNil(t, err)
+
+		_, err = hmac.ParseToken(signTestToken(t, p))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("returns the public keys as a JSON Web Key Set", func(t *testing.T) {
+		p, err := NewMyKeyPairJwtProvider(ecPrivate, "ec", map[string][]byte{"rsa": rsaPublic, "old": ecPublic})
+		assert.Nil(t, err)
+
+		keys := p.GetJwks().Keys
+
+		assert.Equal(t, 3, len(keys))
+		assert.Equal(t, "ec", keys[0].KeyID)
+		assert.Equal(t, "EC", keys[0].KeyType)
+		assert.Equal(t, "ES256", keys[0].Algorithm)
+		assert.Equal(t, "P-256", keys[0].Curve)
+		assert.Equal(t, 43, len(keys[0].X))
+		assert.Equal(t, "old", keys[1].KeyID)
+		assert.Equal(t, "rsa", keys[2].KeyID)
+		assert.Equal(t, "RSA", keys[2].KeyType)
+		assert.Equal(t, "RS256", keys[2].Algorithm)
+		assert.Equal(t, "AQAB", keys[2].E)
+		assert.NotEmpty(t, keys[2].N)
+	})
+
+	t.Run("returns an error when the keys are not valid", func(t *testing.T) {
+		_, err := NewMyKeyPairJwtProvider([]byte("wadus"), "kid", nil)
+		assert.NotNil(t, err)
+
+		_, err = NewMyKeyPairJwtProvider(rsaPrivate, "", nil)
+		assert.NotNil(t, err)
+
+		_, err = NewMyKeyPairJwtProvider(rsaPrivate, "rsa", map[string][]byte{"old": []byte("wadus")})
+		assert.NotNil(t, err)
+
+		_, err = NewMyKeyPairJwtProvider(rsaPrivate, "rsa", map[string][]byte{"rsa": rsaPublic})
+		assert.NotNil(t, err)
+	})
+}
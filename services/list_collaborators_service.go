@@ -0,0 +1,153 @@
+package services
+
+import (
+	"fmt"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+)
+
+// GetListCollaborators returns the collaborators of a list the user can access
+func (s *MyListsService) GetListCollaborators(listID string, userID string, collaborators *[]models.Collaborator) error {
+	if !s.listsRepository().IsValidID(listID) {
+		return s.getInvalidIDError(listID)
+	}
+
+	l := models.List{}
+	err := s.listsRepository().GetOne(&l, stores.Query{Filter: userListFilter(listID, userID, models.RoleViewer), Projection: stores.Fields("collaborators")})
+	if err != nil {
+		return err
+	}
+
+	*collaborators = l.Collaborators
+	if *collaborators == nil {
+		*collaborators = []models.Collaborator{}
+	}
+
+	return nil
+}
+
+// AddListCollaborator gives the role to the user with the collaborator user name in a list
+// owned by the user
+func (s *MyListsService) AddListCollaborator(listID string, userID string, c *models.Collaborator) error {
+	if !s.listsRepository().IsValidID(listID) {
+		return s.getInvalidIDError(listID)
+	}
+
+	if !models.IsCollaboratorRole(c.Role) {
+		return s.getInvalidRoleError(c.Role)
+	}
+
+	l := models.List{}
+	query := stores.Query{Filter: userListFilter(listID, userID, models.RoleViewer), Projection: stores.Fields("userId", "collaborators", "version")}
+	if err := s.listsRepository().GetOne(&l, query); err != nil {
+		return err
+	}
+
+	if l.Role(userID) != models.RoleOwner {
+		return s.getForbiddenError(models.RoleOwner)
+	}
+
+	u := models.User{}
+	err := s.session.GetRepository("users").GetOne(&u, stores.Query{Filter: stores.Eq("userName", c.UserName), Projection: stores.Fields("userName")})
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return &appErrors.BadRequestError{Msg: fmt.Sprintf("The user %q does not exist", c.UserName), InternalError: nil}
+	}
+	if err != nil {
+		return err
+	}
+
+	if l.Role(u.ID) != "" {
+		return &appErrors.BadRequestError{Msg: fmt.Sprintf("The user %q can already access the list", c.UserName), InternalError: nil}
+	}
+
+	c.UserID = u.ID
+
+	// the version avoids adding the same user twice when two requests run at the same time
+	filter := stores.And(userListFilter(listID, userID, models.RoleOwner), versionFilter(l.Version))
+	err = s.listsRepository().Modify(filter, stores.Push("collaborators", *c), stores.Set("updatedAt", now()), stores.Inc("version", 1))
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return s.getConflictError()
+	}
+
+	return err
+}
+
+// UpdateListCollaborator changes the role of a collaborator in a list owned by the user
+func (s *MyListsService) UpdateListCollaborator(listID string, collaboratorID string, userID string, role string) error {
+	if err := s.checkCollaboratorIDs(listID, collaboratorID); err != nil {
+		return err
+	}
+
+	if !models.IsCollaboratorRole(role) {
+		return s.getInvalidRoleError(role)
+	}
+
+	if err := s.checkListRole(listID, userID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	err := s.listsRepository().Modify(listCollaboratorFilter(listID, collaboratorID, userID, models.RoleOwner),
+		stores.SetElement("collaborators", stores.Eq("userId", collaboratorID), map[string]interface{}{"role": role}),
+		stores.Set("updatedAt", now()),
+		stores.Inc("version", 1))
+
+	return collaboratorNotFoundError(err)
+}
+
+// RemoveListCollaborator removes a collaborator from a list. The owner can remove anyone
+// and the collaborators can leave the list.
+func (s *MyListsService) RemoveListCollaborator(listID string, collaboratorID string, userID string) error {
+	if err := s.checkCollaboratorIDs(listID, collaboratorID); err != nil {
+		return err
+	}
+
+	role := models.RoleOwner
+	if collaboratorID == userID {
+		role = models.RoleViewer
+	}
+
+	if err := s.checkListRole(listID, userID, role); err != nil {
+		return err
+	}
+
+	err := s.listsRepository().Modify(listCollaboratorFilter(listID, collaboratorID, userID, role),
+		stores.Pull("collaborators", stores.Eq("userId", collaboratorID)),
+		stores.Set("updatedAt", now()),
+		stores.Inc("version", 1))
+
+	return collaboratorNotFoundError(err)
+}
+
+func (s *MyListsService) checkCollaboratorIDs(listID string, collaboratorID string) error {
+	if !s.listsRepository().IsValidID(listID) {
+		return s.getInvalidIDError(listID)
+	}
+
+	if !s.listsRepository().IsValidID(collaboratorID) {
+		return s.getInvalidIDError(collaboratorID)
+	}
+
+	return nil
+}
+
+func (s *MyListsService) getInvalidRoleError(role string) error {
+	return &appErrors.BadRequestError{Msg: fmt.Sprintf("%q is not a valid role", role), InternalError: nil}
+}
+
+// listCollaboratorFilter returns the filter which matches the list where the user has the role
+// when the collaborator is in it
+func listCollaboratorFilter(listID string, collaboratorID string, userID string, role string) stores.Filter {
+	return stores.And(userListFilter(listID, userID, role), stores.ElemMatch("collaborators", stores.Eq("userId", collaboratorID)))
+}
+
+// collaboratorNotFoundError returns the error of a change made after checking the user role, so
+// the list only can't be found when it doesn't contain the collaborator
+func collaboratorNotFoundError(err error) error {
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return &appErrors.NotFoundError{Model: "collaborator"}
+	}
+
+	return err
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListCollaboratorsService(t *testing.T) {
+	mockedSession := new(mockedMongoSession)
+	service := NewMyListsService(mockedSession)
+
+	mockedUsersRepository := new(mockedRepository)
+	mockedRepository := new(mockedRepository)
+
+	mockedSession.On("GetRepository", "lists").Return(mockedRepository)
+	mockedSession.On("GetRepository", "users").Return(mockedUsersRepository)
+
+	currentTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	defer setNow(currentTime)()
+
+	l := "listId"
+	u := "userId"
+	c := "collaboratorId"
+
+	roleQuery := stores.Query{Filter: userListFilter(l, u, models.RoleViewer), Projection: stores.Fields("userId", "collaborators")}
+	returnOwnedList := func(args mock.Arguments) {
+		args.Get(0).(*models.List).UserID = u
+	}
+
+	t.Run("AddListCollaborator() should push the collaborator with the id of the user", func(t *testing.T) {
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("GetOne", &models.List{}, stores.Query{Filter: userListFilter(l, u, models.RoleViewer), Projection: stores.Fields("userId", "collaborators", "version")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*models.List) = models.List{UserID: u, Version: 2}
+		})
+		mockedUsersRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq("userName", "bob"), Projection: stores.Fields("userName")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.User).ID = c
+		})
+		mockedRepository.On("Modify", stores.And(userListFilter(l, u, models.RoleOwner), stores.Eq("version", 2)), []stores.Modification{
+			stores.Push("collaborators", models.Collaborator{UserID: c, UserName: "bob", Role: models.RoleEditor}),
+			stores.Set("updatedAt", currentTime),
+			stores.Inc("version", 1),
+		}).Return(nil).Once()
+
+		collaborator := models.Collaborator{UserName: "bob", Role: models.RoleEditor}
+		err := service.AddListCollaborator(l, u, &collaborator)
+
+		assert.Nil(t, err)
+		assert.Equal(t, c, collaborator.UserID)
+
+		mockedRepository.AssertExpectations(t)
+		mockedUsersRepository.AssertExpectations(t)
+	})
+
+	t.Run("AddListCollaborator() should return a badRequestError when the role is not valid", func(t *testing.T) {
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+
+		err := service.AddListCollaborator(l, u, &models.Collaborator{UserName: "bob", Role: models.RoleOwner})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, `"owner" is not a valid role`, err.Error())
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateListCollaborator() should return a forbiddenError when the user is not the owner", func(t *testing.T) {
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("IsValidID", c).Return(true).Once()
+		mockedRepository.On("GetOne", &models.List{}, roleQuery).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.List).Collaborators = []models.Collaborator{{UserID: u, Role: models.RoleEditor}}
+		})
+
+		err := service.UpdateListCollaborator(l, c, u, models.RoleViewer)
+
+		assert.IsType(t, &appErrors.ForbiddenError{}, err)
+		assert.Equal(t, "The owner role is required", err.Error())
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateListCollaborator() should return a notFoundError when the collaborator is not in the list", func(t *testing.T) {
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("IsValidID", c).Return(true).Once()
+		mockedRepository.On("GetOne", &models.List{}, roleQuery).Return(nil).Once().Run(returnOwnedList)
+		mockedRepository.On("Modify", listCollaboratorFilter(l, c, u, models.RoleOwner), []stores.Modification{
+			stores.SetElement("collaborators", stores.Eq("userId", c), map[string]interface{}{"role": models.RoleViewer}),
+			stores.Set("updatedAt", currentTime),
+			stores.Inc("version", 1),
+		}).Return(&appErrors.NotFoundError{Model: "lists"}).Once()
+
+		err := service.UpdateListCollaborator(l, c, u, models.RoleViewer)
+
+		assert.IsType(t, &appErrors.NotFoundError{}, err)
+		assert.Equal(t, "collaborator not found", err.Error())
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("RemoveListCollaborator() should let the collaborators leave the list", func(t *testing.T) {
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("IsValidID", u).Return(true).Once()
+		mockedRepository.On("GetOne", &models.List{}, roleQuery).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.List).Collaborators = []models.Collaborator{{UserID: u, Role: models.RoleViewer}}
+		})
+		mockedRepository.On("Modify", listCollaboratorFilter(l, u, u, models.RoleViewer), []stores.Modification{
+			stores.Pull("collaborators", stores.Eq("userId", u)),
+			stores.Set("updatedAt", currentTime),
+			stores.Inc("version", 1),
+		}).Return(nil).Once()
+
+		err := service.RemoveListCollaborator(l, u, u)
+
+		assert.Nil(t, err)
+
+		mockedRepository.AssertExpectations(t)
+	})
+}
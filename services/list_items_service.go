@@ -0,0 +1,266 @@
+package services
+
+import (
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+)
+
+// GetUserListItems returns the items of a list
+func (s *MyListsService) GetUserListItems(listID string, userID string, items *[]models.Item) error {
+	l := models.List{}
+	if err := s.GetSingleUserList(listID, userID, &l); err != nil {
+		return err
+	}
+
+	*items = l.Items
+	if *items == nil {
+		*items = []models.Item{}
+	}
+
+	return nil
+}
+
+// GetUserListItem returns a single item of a list
+func (s *MyListsService) GetUserListItem(listID string, itemID string, userID string, item *models.Item) error {
+	if !s.listsRepository().IsValidID(itemID) {
+		return s.getInvalidIDError(itemID)
+	}
+
+	items := []models.Item{}
+	if err := s.GetUserListItems(listID, userID, &items); err != nil {
+		return err
+	}
+
+	for _, i := range items {
+		if i.ID == itemID {
+			*item = i
+			return nil
+		}
+	}
+
+	return &appErrors.NotFoundError{Model: "item"}
+}
+
+// AddUserListItem adds an item at the end of a list
+func (s *MyListsService) AddUserListItem(listID string, userID string, item *models.Item) (string, error) {
+	if !s.listsRepository().IsValidID(listID) {
+		return "", s.getInvalidIDError(listID)
+	}
+
+	t := now()
+	item.ID = ""
+	prepareItem(item, t)
+
+	err := s.listsRepository().Modify(userListFilter(listID, userID, models.RoleEditor), stores.Push("items", *item), stores.Set("updatedAt", t), stores.Inc("version", 1))
+	if err != nil {
+		return "", s.listAccessError(listID, userID, models.RoleEditor, err)
+	}
+
+	return item.ID, nil
+}
+
+// UpdateUserListItem replaces an item of a list and returns the updated item
+func (s *MyListsService) UpdateUserListItem(listID string, itemID string, userID string, item *models.Item) error {
+	values := map[string]interface{}{
+		"title":       item.Title,
+		"description": item.Description,
+		"done":        item.Done,
+	}
+
+	return s.modifyUserListItem(listID, itemID, userID, values, item)
+}
+
+// PatchUserListItem changes the given fields of an item and returns the updated item
+func (s *MyListsService) PatchUserListItem(listID string, itemID string, userID string, patch models.ItemPatchDto, item *models.Item) error {
+	values := map[string]interface{}{}
+	if patch.Title != nil {
+		values["title"] = *patch.Title
+	}
+	if patch.Description != nil {
+		values["description"] = *patch.Description
+	}
+	if patch.Done != nil {
+		values["done"] = *patch.Done
+	}
+
+	return s.modifyUserListItem(listID, itemID, userID, values, item)
+}
+
+// ToggleUserListItem changes the completion of an item and returns the updated item.
+// The change is only saved when the item hasn't been completed or reopened meanwhile.
+func (s *MyListsService) ToggleUserListItem(listID string, itemID string, userID string, item *models.Item) error {
+	if err := s.checkItemIDs(listID, itemID); err != nil {
+		return err
+	}
+
+	var err error
+	for attempt := 0; attempt < maxToggleAttempts; attempt++ {
+		current := models.Item{}
+		if err = s.GetUserListItem(listID, itemID, userID, &current); err != nil {
+			return err
+		}
+
+		filter := stores.And(userListFilter(listID, userID, models.RoleEditor), stores.ElemMatch("items", stores.And(stores.Eq("id", itemID), doneFilter(current.Done))))
+		err = s.listsRepository().Modify(filter, itemModifications(itemID, map[string]interface{}{"done": !current.Done}, now())...)
+		if _, isNotFound := err.(*appErrors.NotFoundError); !isNotFound {
+			break
+		}
+	}
+
+	if err != nil {
+		return s.listAccessError(listID, userID, models.RoleEditor, err)
+	}
+
+	return s.GetUserListItem(listID, itemID, userID, item)
+}
+
+// ClearCompletedUserListItems removes or archives the done items of a list
+func (s *MyListsService) ClearCompletedUserListItems(listID string, userID string, archive bool) error {
+	if !s.listsRepository().IsValidID(listID) {
+		return s.getInvalidIDError(listID)
+	}
+
+	t := now()
+
+	clear := stores.Pull("items", stores.Eq("done", true))
+	if archive {
+		clear = stores.SetElement("items", stores.And(stores.Eq("done", true), stores.Ne("archived", true)), map[string]interface{}{
+			"archived":  true,
+			"updatedAt": t,
+		})
+	}
+
+	err := s.listsRepository().Modify(userListFilter(listID, userID, models.RoleEditor), clear, stores.Set("updatedAt", t), stores.Inc("version", 1))
+
+	return s.listAccessError(listID, userID, models.RoleEditor, err)
+}
+
+// RemoveUserListItem removes an item from a list
+func (s *MyListsService) RemoveUserListItem(listID string, itemID string, userID string) error {
+	if err := s.checkItemIDs(listID, itemID); err != nil {
+		return err
+	}
+
+	err := s.listsRepository().Modify(userListItemFilter(listID, itemID, userID),
+		stores.Pull("items", stores.Eq("id", itemID)),
+		stores.Set("updatedAt", now()),
+		stores.Inc("version", 1))
+
+	return s.listAccessError(listID, userID, models.RoleEditor, err)
+}
+
+func (s *MyListsService) modifyUserListItem(listID string, itemID string, userID string, values map[string]interface{}, item *models.Item) error {
+	if err := s.checkItemIDs(listID, itemID); err != nil {
+		return err
+	}
+
+	err := s.listsRepository().Modify(userListItemFilter(listID, itemID, userID), itemModifications(itemID, values, now())...)
+	if err != nil {
+		return s.listAccessError(listID, userID, models.RoleEditor, err)
+	}
+
+	return s.GetUserListItem(listID, itemID, userID, item)
+}
+
+func (s *MyListsService) checkItemIDs(listID string, itemID string) error {
+	if !s.listsRepository().IsValidID(listID) {
+		return s.getInvalidIDError(listID)
+	}
+
+	if !s.listsRepository().IsValidID(itemID) {
+		return s.getInvalidIDError(itemID)
+	}
+
+	return nil
+}
+
+const maxToggleAttempts = 3
+
+// itemModifications returns the modifications which set the values of the item, keeping
+// its times and the list version up to date
+func itemModifications(itemID string, values map[string]interface{}, t time.Time) []stores.Modification {
+	mods := []stores.Modification{}
+
+	values["updatedAt"] = t
+	if done, isSet := values["done"].(bool); isSet {
+		if done {
+			// only the items which weren't done get the time, so it must be set before the done field
+			notDone := stores.And(stores.Eq("id", itemID), doneFilter(false))
+			mods = append(mods, stores.SetElement("items", notDone, map[string]interface{}{"doneAt": t}))
+		} else {
+			values["doneAt"] = time.Time{}
+		}
+	}
+
+	return append(mods, stores.SetElement("items", stores.Eq("id", itemID), values), stores.Set("updatedAt", t), stores.Inc("version", 1))
+}
+
+// doneFilter returns the filter which matches the items with the given completion. The
+// items saved before the done field existed don't have it.
+func doneFilter(done bool) stores.Filter {
+	if done {
+		return stores.Eq("done", true)
+	}
+
+	return stores.Ne("done", true)
+}
+
+// userListItemFilter returns the filter which matches the list the user can edit when it contains the item
+func userListItemFilter(listID string, itemID string, userID string) stores.Filter {
+	return stores.And(userListFilter(listID, userID, models.RoleEditor), stores.ElemMatch("items", stores.Eq("id", itemID)))
+}
+
+// prepareItems replaces the items sent in a list with new ones which only have their title,
+// description and done fields. The items with the id of an existing item keep its id, its
+// times and its archived field and the other ones get a new id, so the ids are always
+// valid and unique.
+func prepareItems(items []models.Item, existing []models.Item, t time.Time) {
+	existingByID := map[string]models.Item{}
+	for _, e := range existing {
+		// the items saved before the ids existed don't have one
+		if e.ID != "" {
+			existingByID[e.ID] = e
+		}
+	}
+
+	for i, item := range items {
+		prepared := models.Item{Title: item.Title, Description: item.Description, Done: item.Done}
+
+		if e, ok := existingByID[item.ID]; ok {
+			prepared.ID = e.ID
+			prepared.CreatedAt = e.CreatedAt
+			prepared.Archived = e.Archived
+			prepared.DoneAt = e.DoneAt
+			if e.Title == item.Title && e.Description == item.Description && e.Done == item.Done {
+				prepared.UpdatedAt = e.UpdatedAt
+			}
+
+			// an id sent twice is only kept once
+			delete(existingByID, item.ID)
+		}
+
+		prepareItem(&prepared, t)
+		items[i] = prepared
+	}
+}
+
+func prepareItem(item *models.Item, t time.Time) {
+	if item.ID == "" {
+		item.ID = stores.NewID()
+	}
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = t
+	}
+	if item.UpdatedAt.IsZero() {
+		item.UpdatedAt = t
+	}
+
+	if !item.Done {
+		item.DoneAt = time.Time{}
+	} else if item.DoneAt.IsZero() {
+		item.DoneAt = t
+	}
+}
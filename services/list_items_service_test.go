@@ -0,0 +1,259 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListItemsService(t *testing.T) {
+	mockedSession := new(mockedMongoSession)
+	service := NewMyListsService(mockedSession)
+
+	mockedRepository := new(mockedRepository)
+
+	mockedSession.On("GetRepository", "lists").Return(mockedRepository)
+
+	currentTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	defer setNow(currentTime)()
+
+	l := "listId"
+	i := "itemId"
+	u := "userId"
+
+	stored := models.List{Items: []models.Item{{ID: "other"}, {ID: i, Title: "stored", Done: true}}}
+	returnStoredList := func(args mock.Arguments) {
+		*args.Get(0).(*models.List) = stored
+	}
+
+	t.Run("AddUserListItem() should push the item with a new id and its times", func(t *testing.T) {
+		item := models.Item{Title: "title", Done: true}
+
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("Modify", userListFilter(l, u, models.RoleEditor), mock.Anything).Return(nil).Once().Run(func(args mock.Arguments) {
+			mods := args.Get(1).([]stores.Modification)
+			assert.Equal(t, stores.Push("items", item), mods[0])
+			assert.Equal(t, stores.Set("updatedAt", currentTime), mods[1])
+			assert.Equal(t, stores.Inc("version", 1), mods[2])
+		})
+
+		id, err := service.AddUserListItem(l, u, &item)
+
+		assert.Nil(t, err)
+		assert.NotEmpty(t, id)
+		assert.Equal(t, id, item.ID)
+		assert.Equal(t, currentTime, item.CreatedAt)
+		assert.Equal(t, currentTime, item.UpdatedAt)
+		assert.Equal(t, currentTime, item.DoneAt)
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateUserListItem() should set the item fields", func(t *testing.T) {
+		item := models.Item{Title: "title", Description: "desc"}
+
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("IsValidID", i).Return(true).Once()
+		mockedRepository.On("Modify", userListItemFilter(l, i, u), []stores.Modification{
+			stores.SetElement("items", stores.Eq("id", i), map[string]interface{}{
+				"title":       "title",
+				"description": "desc",
+				"done":        false,
+				"doneAt":      time.Time{},
+				"updatedAt":   currentTime,
+			}),
+			stores.Set("updatedAt", currentTime),
+			stores.Inc("version", 1),
+		}).Return(errors.New("error")).Once()
+
+		err := service.UpdateUserListItem(l, i, u, &item)
+
+		assert.NotNil(t, err)
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateUserListItem() should return a badRequestError when the item id is not valid", func(t *testing.T) {
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("IsValidID", "wadus").Return(false).Once()
+
+		err := service.UpdateUserListItem(l, "wadus", u, &models.Item{})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, `"wadus" is not a valid id`, err.Error())
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("PatchUserListItem() should only set the given fields and return the item", func(t *testing.T) {
+		done := true
+
+		mockedRepository.On("IsValidID", l).Return(true).Twice()
+		mockedRepository.On("IsValidID", i).Return(true).Twice()
+		mockedRepository.On("Modify", userListItemFilter(l, i, u), []stores.Modification{
+			stores.SetElement("items", stores.And(stores.Eq("id", i), stores.Ne("done", true)), map[string]interface{}{"doneAt": currentTime}),
+			stores.SetElement("items", stores.Eq("id", i), map[string]interface{}{"done": true, "updatedAt": currentTime}),
+			stores.Set("updatedAt", currentTime),
+			stores.Inc("version", 1),
+		}).Return(nil).Once()
+		mockedRepository.On("GetOne", &models.List{}, stores.Query{Filter: userListFilter(l, u, models.RoleViewer)}).Return(nil).Once().Run(returnStoredList)
+
+		item := models.Item{}
+		err := service.PatchUserListItem(l, i, u, models.ItemPatchDto{Done: &done}, &item)
+
+		assert.Nil(t, err)
+		assert.Equal(t, stored.Items[1], item)
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("ToggleUserListItem() should retry when the item changed meanwhile", func(t *testing.T) {
+		notDone := models.List{Items: []models.Item{{ID: i}}}
+		doneMods := itemModifications(i, map[string]interface{}{"done": true}, currentTime)
+		notDoneMods := itemModifications(i, map[string]interface{}{"done": false}, currentTime)
+
+		mockedRepository.On("IsValidID", l).Return(true)
+		mockedRepository.On("IsValidID", i).Return(true)
+		mockedRepository.On("GetOne", &models.List{}, stores.Query{Filter: userListFilter(l, u, models.RoleViewer)}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*models.List) = notDone
+		})
+		mockedRepository.On("Modify", stores.And(userListFilter(l, u, models.RoleEditor), stores.ElemMatch("items", stores.And(stores.Eq("id", i), stores.Ne("done", true)))), doneMods).Return(&appErrors.NotFoundError{Model: "lists"}).Once()
+		mockedRepository.On("GetOne", &models.List{}, stores.Query{Filter: userListFilter(l, u, models.RoleViewer)}).Return(nil).Twice().Run(returnStoredList)
+		mockedRepository.On("Modify", stores.And(userListFilter(l, u, models.RoleEditor), stores.ElemMatch("items", stores.And(stores.Eq("id", i), stores.Eq("done", true)))), notDoneMods).Return(nil).Once()
+
+		item := models.Item{}
+		err := service.ToggleUserListItem(l, i, u, &item)
+
+		assert.Nil(t, err)
+		assert.Equal(t, stored.Items[1], item)
+
+		mockedRepository.AssertExpectations(t)
+		mockedRepository.ExpectedCalls = nil
+		mockedRepository.Calls = nil
+	})
+
+	t.Run("ClearCompletedUserListItems() should remove the done items", func(t *testing.T) {
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("Modify", userListFilter(l, u, models.RoleEditor), []stores.Modification{
+			stores.Pull("items", stores.Eq("done", true)),
+			stores.Set("updatedAt", currentTime),
+			stores.Inc("version", 1),
+		}).Return(nil).Once()
+
+		err := service.ClearCompletedUserListItems(l, u, false)
+
+		assert.Nil(t, err)
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("ClearCompletedUserListItems() should archive the done items", func(t *testing.T) {
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("Modify", userListFilter(l, u, models.RoleEditor), []stores.Modification{
+			stores.SetElement("items", stores.And(stores.Eq("done", true), stores.Ne("archived", true)), map[string]interface{}{
+				"archived":  true,
+				"updatedAt": currentTime,
+			}),
+			stores.Set("updatedAt", currentTime),
+			stores.Inc("version", 1),
+		}).Return(nil).Once()
+
+		err := service.ClearCompletedUserListItems(l, u, true)
+
+		assert.Nil(t, err)
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("GetUserListItem() should return a not found error when the list does not contain the item", func(t *testing.T) {
+		mockedRepository.On("IsValidID", "missing").Return(true).Once()
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("GetOne", &models.List{}, stores.Query{Filter: userListFilter(l, u, models.RoleViewer)}).Return(nil).Once().Run(returnStoredList)
+
+		err := service.GetUserListItem(l, "missing", u, &models.Item{})
+
+		assert.IsType(t, &appErrors.NotFoundError{}, err)
+		assert.Equal(t, "item not found", err.Error())
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("RemoveUserListItem() should pull the item", func(t *testing.T) {
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("IsValidID", i).Return(true).Once()
+		mockedRepository.On("Modify", userListItemFilter(l, i, u), []stores.Modification{
+			stores.Pull("items", stores.Eq("id", i)),
+			stores.Set("updatedAt", currentTime),
+			stores.Inc("version", 1),
+		}).Return(nil).Once()
+
+		err := service.RemoveUserListItem(l, i, u)
+
+		assert.Nil(t, err)
+
+		mockedRepository.AssertExpectations(t)
+	})
+}
+
+func TestPrepareItems(t *testing.T) {
+	created := time.Date(2020, 4, 1, 10, 0, 0, 0, time.UTC)
+	currentTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	existingID := stores.NewID()
+
+	existing := []models.Item{
+		{ID: existingID, Title: "stored", CreatedAt: created, UpdatedAt: created, Archived: true},
+	}
+
+	t.Run("should keep the id and the times of the existing items and ignore the ones sent", func(t *testing.T) {
+		items := []models.Item{
+			{ID: existingID, Title: "stored", CreatedAt: currentTime, UpdatedAt: currentTime, Archived: false},
+		}
+
+		prepareItems(items, existing, currentTime)
+
+		assert.Equal(t, models.Item{ID: existingID, Title: "stored", CreatedAt: created, UpdatedAt: created, Archived: true}, items[0])
+	})
+
+	t.Run("should set the update time of the changed existing items", func(t *testing.T) {
+		items := []models.Item{{ID: existingID, Title: "changed", Done: true}}
+
+		prepareItems(items, existing, currentTime)
+
+		assert.Equal(t, models.Item{ID: existingID, Title: "changed", Done: true, CreatedAt: created, UpdatedAt: currentTime, DoneAt: currentTime, Archived: true}, items[0])
+	})
+
+	t.Run("should set a new id to the unknown, invalid and repeated ids", func(t *testing.T) {
+		items := []models.Item{
+			{ID: existingID, Title: "first"},
+			{ID: existingID, Title: "repeated", CreatedAt: created, Archived: true},
+			{ID: "not valid", Title: "invalid"},
+			{ID: stores.NewID(), Title: "unknown"},
+			{Title: "new"},
+		}
+
+		prepareItems(items, existing, currentTime)
+
+		ids := map[string]bool{}
+		for i, item := range items {
+			ids[item.ID] = true
+			if i == 0 {
+				continue
+			}
+
+			assert.NotEqual(t, existingID, item.ID)
+			assert.Equal(t, currentTime, item.CreatedAt)
+			assert.Equal(t, currentTime, item.UpdatedAt)
+			assert.False(t, item.Archived)
+		}
+
+		assert.Equal(t, existingID, items[0].ID)
+		assert.Equal(t, len(items), len(ids))
+		assert.NotEqual(t, "not valid", items[2].ID)
+	})
+}
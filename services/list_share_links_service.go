@@ -0,0 +1,108 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+)
+
+// shareTokenBytes is the number of random bytes of a share link token
+const shareTokenBytes = 24
+
+// GetListShareLinks returns the share links of a list owned by the user
+func (s *MyListsService) GetListShareLinks(listID string, userID string, links *[]models.ShareLink) error {
+	if !s.listsRepository().IsValidID(listID) {
+		return s.getInvalidIDError(listID)
+	}
+
+	l := models.List{}
+	err := s.listsRepository().GetOne(&l, stores.Query{Filter: userListFilter(listID, userID, models.RoleOwner), Projection: stores.Fields("shareLinks")})
+	if err != nil {
+		return s.listAccessError(listID, userID, models.RoleOwner, err)
+	}
+
+	*links = l.ShareLinks
+	if *links == nil {
+		*links = []models.ShareLink{}
+	}
+
+	return nil
+}
+
+// AddListShareLink creates a share link with a new token for a list owned by the user
+func (s *MyListsService) AddListShareLink(listID string, userID string, link *models.ShareLink) error {
+	if !s.listsRepository().IsValidID(listID) {
+		return s.getInvalidIDError(listID)
+	}
+
+	t := now()
+	if link.IsExpired(t) {
+		return &appErrors.BadRequestError{Msg: "The expiry time must be in the future", InternalError: nil}
+	}
+
+	token, err := newRandomToken(shareTokenBytes)
+	if err != nil {
+		return &appErrors.UnexpectedError{Msg: "Error creating the share link", InternalError: err}
+	}
+
+	link.Token = token
+	link.CreatedAt = t
+	link.ExpiresAt = link.ExpiresAt.UTC()
+
+	err = s.listsRepository().Modify(userListFilter(listID, userID, models.RoleOwner), stores.Push("shareLinks", *link), stores.Inc("version", 1))
+
+	return s.listAccessError(listID, userID, models.RoleOwner, err)
+}
+
+// RemoveListShareLink revokes a share link of a list owned by the user
+func (s *MyListsService) RemoveListShareLink(listID string, token string, userID string) error {
+	if !s.listsRepository().IsValidID(listID) {
+		return s.getInvalidIDError(listID)
+	}
+
+	filter := stores.And(userListFilter(listID, userID, models.RoleOwner), stores.ElemMatch("shareLinks", stores.Eq("token", token)))
+	err := s.listsRepository().Modify(filter, stores.Pull("shareLinks", stores.Eq("token", token)), stores.Inc("version", 1))
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		err = &appErrors.NotFoundError{Model: "share link"}
+	}
+
+	return s.listAccessError(listID, userID, models.RoleOwner, err)
+}
+
+// GetSharedList returns the list of a share link which hasn't expired
+func (s *MyListsService) GetSharedList(token string, r *models.SharedListDto) error {
+	l := models.List{}
+	query := stores.Query{
+		Filter:     stores.ElemMatch("shareLinks", stores.Eq("token", token)),
+		Projection: stores.Fields("name", "items", "updatedAt", "shareLinks"),
+	}
+	err := s.listsRepository().GetOne(&l, query)
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return &appErrors.NotFoundError{Model: "shared list"}
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, link := range l.ShareLinks {
+		if link.Token == token && !link.IsExpired(now()) {
+			*r = l.ToSharedListDto()
+			return nil
+		}
+	}
+
+	return &appErrors.NotFoundError{Model: "shared list"}
+}
+
+// newRandomToken returns a token with n random bytes which can be used in urls
+func newRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
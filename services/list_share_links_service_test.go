@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListShareLinksService(t *testing.T) {
+	mockedSession := new(mockedMongoSession)
+	service := NewMyListsService(mockedSession)
+
+	mockedRepository := new(mockedRepository)
+
+	mockedSession.On("GetRepository", "lists").Return(mockedRepository)
+
+	currentTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	defer setNow(currentTime)()
+
+	l := "listId"
+	u := "userId"
+
+	sharedQuery := stores.Query{
+		Filter:     stores.ElemMatch("shareLinks", stores.Eq("token", "token")),
+		Projection: stores.Fields("name", "items", "updatedAt", "shareLinks"),
+	}
+
+	t.Run("AddListShareLink() should push a link with a new token", func(t *testing.T) {
+		expires := currentTime.Add(time.Hour)
+
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("Modify", userListFilter(l, u, models.RoleOwner), mock.Anything).Return(nil).Once().Run(func(args mock.Arguments) {
+			mods := args.Get(1).([]stores.Modification)
+			link := mods[0].Value.(models.ShareLink)
+			assert.Len(t, link.Token, 32)
+			assert.Equal(t, currentTime, link.CreatedAt)
+			assert.Equal(t, expires, link.ExpiresAt)
+			assert.Equal(t, stores.Inc("version", 1), mods[1])
+		})
+
+		link := models.ShareLink{ExpiresAt: expires}
+		err := service.AddListShareLink(l, u, &link)
+
+		assert.Nil(t, err)
+		assert.NotEmpty(t, link.Token)
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("AddListShareLink() should return a badRequestError when the link has already expired", func(t *testing.T) {
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+
+		err := service.AddListShareLink(l, u, &models.ShareLink{ExpiresAt: currentTime})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("RemoveListShareLink() should return a notFoundError when the list does not have the link", func(t *testing.T) {
+		filter := stores.And(userListFilter(l, u, models.RoleOwner), stores.ElemMatch("shareLinks", stores.Eq("token", "wadus")))
+
+		mockedRepository.On("IsValidID", l).Return(true).Once()
+		mockedRepository.On("Modify", filter, []stores.Modification{
+			stores.Pull("shareLinks", stores.Eq("token", "wadus")),
+			stores.Inc("version", 1),
+		}).Return(&appErrors.NotFoundError{Model: "lists"}).Once()
+		mockedRepository.On("GetOne", &models.List{}, stores.Query{Filter: userListFilter(l, u, models.RoleViewer), Projection: stores.Fields("userId", "collaborators")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.List).UserID = u
+		})
+
+		err := service.RemoveListShareLink(l, "wadus", u)
+
+		assert.IsType(t, &appErrors.NotFoundError{}, err)
+		assert.Equal(t, "share link not found", err.Error())
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("GetSharedList() should return the list without the archived items", func(t *testing.T) {
+		mockedRepository.On("GetOne", &models.List{}, sharedQuery).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*models.List) = models.List{
+				Name:       "list",
+				Items:      []models.Item{{ID: "i1"}, {ID: "i2", Archived: true}},
+				ShareLinks: []models.ShareLink{{Token: "other"}, {Token: "token", ExpiresAt: currentTime.Add(time.Second)}},
+			}
+		})
+
+		r := models.SharedListDto{}
+		err := service.GetSharedList("token", &r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.SharedListDto{Name: "list", Items: []models.Item{{ID: "i1"}}}, r)
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("GetSharedList() should return a notFoundError when the link has expired", func(t *testing.T) {
+		mockedRepository.On("GetOne", &models.List{}, sharedQuery).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.List).ShareLinks = []models.ShareLink{{Token: "token", ExpiresAt: currentTime}}
+		})
+
+		err := service.GetSharedList("token", &models.SharedListDto{})
+
+		assert.IsType(t, &appErrors.NotFoundError{}, err)
+		assert.Equal(t, "shared list not found", err.Error())
+
+		mockedRepository.AssertExpectations(t)
+	})
+}
@@ -1,21 +1,61 @@
 package services
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
 	"github.com/AngelVlc/lists-backend/models"
 	"github.com/AngelVlc/lists-backend/stores"
-	"gopkg.in/mgo.v2/bson"
 )
 
 // ListsService is the interface a lists service must implement
 type ListsService interface {
 	AddUserList(userID string, l *models.List) (string, error)
-	RemoveUserList(id string, userID string) error
-	UpdateUserList(id string, userID string, l *models.List) error
+	RemoveUserList(id string, userID string, version *int) error
+	UpdateUserList(id string, userID string, l *models.List, version *int) error
 	GetSingleUserList(id string, userID string, l *models.List) error
-	GetUserLists(userID string, r *[]models.GetListsResultDto) error
+	GetUserLists(userID string, opts models.GetListsOptions, r *models.GetListsPageDto) error
+	GetUserListItems(listID string, userID string, items *[]models.Item) error
+	GetUserListItem(listID string, itemID string, userID string, item *models.Item) error
+	AddUserListItem(listID string, userID string, item *models.Item) (string, error)
+	UpdateUserListItem(listID string, itemID string, userID string, item *models.Item) error
+	PatchUserListItem(listID string, itemID string, userID string, patch models.ItemPatchDto, item *models.Item) error
+	ToggleUserListItem(listID string, itemID string, userID string, item *models.Item) error
+	ClearCompletedUserListItems(listID string, userID string, archive bool) error
+	RemoveUserListItem(listID string, itemID string, userID string) error
+	GetListCollaborators(listID string, userID string, collaborators *[]models.Collaborator) error
+	AddListCollaborator(listID string, userID string, c *models.Collaborator) error
+	UpdateListCollaborator(listID string, collaboratorID string, userID string, role string) error
+	RemoveListCollaborator(listID string, collaboratorID string, userID string) error
+	GetListShareLinks(listID string, userID string, links *[]models.ShareLink) error
+	AddListShareLink(listID string, userID string, link *models.ShareLink) error
+	RemoveListShareLink(listID string, token string, userID string) error
+	GetSharedList(token string, r *models.SharedListDto) error
+}
+
+const (
+	defaultListsPageSize = 20
+	maxListsPageSize     = 100
+)
+
+// listsSortFields contains the fields the lists can be sorted by
+var listsSortFields = map[string]bool{
+	"name":      true,
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+// listsCursor is the position after the last list of a page. Only the value of
+// the field used to sort is set.
+type listsCursor struct {
+	SortBy     string    `json:"s"`
+	Descending bool      `json:"d,omitempty"`
+	Name       string    `json:"n,omitempty"`
+	Time       time.Time `json:"t,omitempty"`
+	ID         string    `json:"id"`
 }
 
 // MyListsService is the service for the list entity
@@ -33,42 +73,157 @@ func NewMyListsService(session stores.MongoSession) *MyListsService {
 // AddUserList  adds a user
 func (s *MyListsService) AddUserList(userID string, l *models.List) (string, error) {
 	l.UserID = userID
+	l.CreatedAt = now()
+	prepareItems(l.Items, nil, l.CreatedAt)
+	l.UpdatedAt = l.CreatedAt
+	l.Version = 1
 	return s.listsRepository().Add(l)
 }
 
-// RemoveUserList removes a list
-func (s *MyListsService) RemoveUserList(id string, userID string) error {
+// RemoveUserList removes a list owned by the user. When the version is given the list
+// is only removed if it hasn't changed.
+func (s *MyListsService) RemoveUserList(id string, userID string, version *int) error {
 	if !s.listsRepository().IsValidID(id) {
 		return s.getInvalidIDError(id)
 	}
 
-	return s.listsRepository().Remove(bson.D{{"_id", id}, {"userId", userID}})
+	filter := userListFilter(id, userID, models.RoleOwner)
+	if version != nil {
+		filter = stores.And(filter, versionFilter(*version))
+	}
+
+	err := s.listsRepository().Remove(filter)
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		if roleErr := s.checkListRole(id, userID, models.RoleOwner); roleErr != nil {
+			return roleErr
+		}
+		if version != nil {
+			return s.getConflictError()
+		}
+	}
+
+	return err
 }
 
-// UpdateUserList updates an existing list
-func (s *MyListsService) UpdateUserList(id string, userID string, l *models.List) error {
+// UpdateUserList updates an existing list the user can edit, keeping its owner, its
+// collaborators and its share links. When the version is given the list is only updated if it hasn't changed.
+func (s *MyListsService) UpdateUserList(id string, userID string, l *models.List, version *int) error {
 	if !s.listsRepository().IsValidID(id) {
 		return s.getInvalidIDError(id)
 	}
 
+	existing := models.List{}
+	query := stores.Query{
+		Filter:     userListFilter(id, userID, models.RoleViewer),
+		Projection: stores.Fields("userId", "collaborators", "shareLinks", "createdAt", "version", "items"),
+	}
+	if err := s.listsRepository().GetOne(&existing, query); err != nil {
+		return err
+	}
+
+	if !models.HasRole(existing.Role(userID), models.RoleEditor) {
+		return s.getForbiddenError(models.RoleEditor)
+	}
+
+	if version != nil && *version != existing.Version {
+		return s.getConflictError()
+	}
+
 	l.ID = id
-	l.UserID = userID
+	l.UserID = existing.UserID
+	l.Collaborators = existing.Collaborators
+	l.ShareLinks = existing.ShareLinks
+	l.CreatedAt = existing.CreatedAt
+	l.UpdatedAt = now()
+	l.Version = existing.Version + 1
+	prepareItems(l.Items, existing.Items, l.UpdatedAt)
+
+	// the list could have been changed after reading it, so the version is checked again
+	err := s.listsRepository().Update(stores.And(userListFilter(id, userID, models.RoleEditor), versionFilter(existing.Version)), l)
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return s.getConflictError()
+	}
 
-	return s.listsRepository().Update(bson.D{{"_id", id}, {"userId", userID}}, l)
+	return err
 }
 
-// GetSingleUserList returns a single list from its id
+// GetSingleUserList returns a single list the user can access from its id
 func (s *MyListsService) GetSingleUserList(id string, userID string, l *models.List) error {
 	if !s.listsRepository().IsValidID(id) {
 		return s.getInvalidIDError(id)
 	}
 
-	return s.listsRepository().GetOne(l, bson.D{{"_id", id}, {"userId", userID}}, nil)
+	return s.listsRepository().GetOne(l, stores.Query{Filter: userListFilter(id, userID, models.RoleViewer)})
 }
 
-// GetUserLists returns the lists for the given user
-func (s *MyListsService) GetUserLists(userID string, r *[]models.GetListsResultDto) error {
-	return s.listsRepository().Get(r, bson.D{{"userId", userID}}, bson.M{"name": 1})
+// GetUserLists returns a page of the lists the given user owns or collaborates in
+func (s *MyListsService) GetUserLists(userID string, opts models.GetListsOptions, r *models.GetListsPageDto) error {
+	if opts.SortBy == "" {
+		opts.SortBy = "name"
+	}
+
+	if !listsSortFields[opts.SortBy] {
+		return &appErrors.BadRequestError{Msg: fmt.Sprintf("Lists can't be sorted by %q", opts.SortBy), InternalError: nil}
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultListsPageSize
+	}
+	if limit > maxListsPageSize {
+		limit = maxListsPageSize
+	}
+
+	filter := listRoleFilter(userID, models.RoleViewer)
+	if opts.Name != "" {
+		filter = stores.And(filter, stores.Contains("name", opts.Name))
+	}
+
+	total, err := s.listsRepository().Count(filter)
+	if err != nil {
+		return err
+	}
+
+	pageFilter := filter
+	if opts.Cursor != "" {
+		c, err := decodeListsCursor(opts.Cursor)
+		if err != nil || c.SortBy != opts.SortBy || c.Descending != opts.Descending {
+			return &appErrors.BadRequestError{Msg: "Invalid cursor", InternalError: err}
+		}
+		pageFilter = stores.And(filter, c.filter())
+	}
+
+	sort := stores.Asc(opts.SortBy)
+	if opts.Descending {
+		sort = stores.Desc(opts.SortBy)
+	}
+
+	found := []models.List{}
+	query := stores.Query{
+		Filter:     pageFilter,
+		Projection: stores.Fields("name", "createdAt", "updatedAt", "items"),
+		Sort:       []stores.Sort{sort, stores.Asc(stores.IDField)},
+		Limit:      limit + 1,
+	}
+	if err := s.listsRepository().Get(&found, query); err != nil {
+		return err
+	}
+
+	lists := []models.GetListsResultDto{}
+	for _, l := range found {
+		lists = append(lists, l.ToResultDto())
+	}
+
+	r.NextCursor = ""
+	if len(lists) > limit {
+		lists = lists[:limit]
+		r.NextCursor = newListsCursor(opts, lists[limit-1]).encode()
+	}
+
+	r.Lists = lists
+	r.Total = total
+
+	return nil
 }
 
 func (s *MyListsService) listsRepository() stores.Repository {
@@ -78,3 +233,118 @@ func (s *MyListsService) listsRepository() stores.Repository {
 func (s *MyListsService) getInvalidIDError(id string) error {
 	return &appErrors.BadRequestError{Msg: fmt.Sprintf("%q is not a valid id", id), InternalError: nil}
 }
+
+func (s *MyListsService) getForbiddenError(role string) error {
+	return &appErrors.ForbiddenError{Msg: fmt.Sprintf("The %v role is required", role), InternalError: nil}
+}
+
+// checkListRole returns a not found error when the user can't access the list and a forbidden
+// error when the user doesn't have the required role
+func (s *MyListsService) checkListRole(id string, userID string, role string) error {
+	l := models.List{}
+	query := stores.Query{Filter: userListFilter(id, userID, models.RoleViewer), Projection: stores.Fields("userId", "collaborators")}
+	if err := s.listsRepository().GetOne(&l, query); err != nil {
+		return err
+	}
+
+	if !models.HasRole(l.Role(userID), role) {
+		return s.getForbiddenError(role)
+	}
+
+	return nil
+}
+
+// listAccessError returns the error of a change which didn't find the list. It tells apart
+// the lists the user can't change from the ones which don't match for other reasons.
+func (s *MyListsService) listAccessError(id string, userID string, role string, err error) error {
+	if _, isNotFound := err.(*appErrors.NotFoundError); !isNotFound {
+		return err
+	}
+
+	if roleErr := s.checkListRole(id, userID, role); roleErr != nil {
+		return roleErr
+	}
+
+	return err
+}
+
+func (s *MyListsService) getConflictError() error {
+	return &appErrors.ConflictError{Msg: "The list has been modified", InternalError: nil}
+}
+
+// versionFilter returns the filter which matches the lists with the given version. The
+// lists saved before the version field existed don't have it.
+func versionFilter(version int) stores.Filter {
+	if version == 0 {
+		return stores.In("version", 0, nil)
+	}
+
+	return stores.Eq("version", version)
+}
+
+// userListFilter returns the filter which matches the list with the given id when the user has the role
+func userListFilter(id string, userID string, role string) stores.Filter {
+	return stores.And(stores.Eq(stores.IDField, id), listRoleFilter(userID, role))
+}
+
+// listRoleFilter returns the filter which matches the lists where the user has at least the given role
+func listRoleFilter(userID string, role string) stores.Filter {
+	owner := stores.Eq("userId", userID)
+
+	switch role {
+	case models.RoleOwner:
+		return owner
+	case models.RoleEditor:
+		return stores.Or(owner, stores.ElemMatch("collaborators", stores.And(stores.Eq("userId", userID), stores.Eq("role", models.RoleEditor))))
+	default:
+		return stores.Or(owner, stores.ElemMatch("collaborators", stores.Eq("userId", userID)))
+	}
+}
+
+func newListsCursor(opts models.GetListsOptions, last models.GetListsResultDto) listsCursor {
+	c := listsCursor{SortBy: opts.SortBy, Descending: opts.Descending, ID: last.ID}
+
+	switch opts.SortBy {
+	case "createdAt":
+		c.Time = last.CreatedAt
+	case "updatedAt":
+		c.Time = last.UpdatedAt
+	default:
+		c.Name = last.Name
+	}
+
+	return c
+}
+
+func decodeListsCursor(cursor string) (listsCursor, error) {
+	c := listsCursor{}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(data, &c)
+
+	return c, err
+}
+
+func (c listsCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// filter returns the filter which matches the lists after the cursor
+func (c listsCursor) filter() stores.Filter {
+	var value interface{} = c.Name
+	if c.SortBy != "name" {
+		value = c.Time.UTC()
+	}
+
+	after := stores.Gt(c.SortBy, value)
+	if c.Descending {
+		after = stores.Lt(c.SortBy, value)
+	}
+
+	return stores.Or(after, stores.And(stores.Eq(c.SortBy, value), stores.Gt(stores.IDField, c.ID)))
+}
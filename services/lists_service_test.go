@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
 	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
 	"github.com/stretchr/testify/assert"
-	"gopkg.in/mgo.v2/bson"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestListsService(t *testing.T) {
@@ -19,12 +21,18 @@ func TestListsService(t *testing.T) {
 
 	mockedSession.On("GetRepository", "lists").Return(mockedRepository)
 
+	currentTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	defer setNow(currentTime)()
+
 	t.Run("AddUserList() should call repository.AddList", func(t *testing.T) {
 		u := "userId"
 		l := models.List{
-			ID:     "1",
-			Name:   "list",
-			UserID: u,
+			ID:        "1",
+			Name:      "list",
+			UserID:    u,
+			CreatedAt: currentTime,
+			UpdatedAt: currentTime,
+			Version:   1,
 		}
 
 		mockedRepository.On("Add", &l).Return("", errors.New("error")).Once()
@@ -39,10 +47,10 @@ func TestListsService(t *testing.T) {
 	})
 
 	t.Run("RemoveUserList() should call repository.Remove", func(t *testing.T) {
-		mockedRepository.On("Remove", bson.D{{"_id", "id"}, {"userId", "uid"}}).Return(errors.New("error")).Once()
+		mockedRepository.On("Remove", userListFilter("id", "uid", models.RoleOwner)).Return(errors.New("error")).Once()
 		mockedRepository.On("IsValidID", "id").Return(true).Once()
 
-		err := service.RemoveUserList("id", "uid")
+		err := service.RemoveUserList("id", "uid", nil)
 
 		assert.NotNil(t, err)
 
@@ -53,7 +61,7 @@ func TestListsService(t *testing.T) {
 	t.Run("RemoveUserList() should return a badRequestError when the id is not valid", func(t *testing.T) {
 		mockedRepository.On("IsValidID", "id").Return(false).Once()
 
-		err := service.RemoveUserList("id", "uid")
+		err := service.RemoveUserList("id", "uid", nil)
 
 		assert.NotNil(t, err)
 
@@ -65,6 +73,24 @@ func TestListsService(t *testing.T) {
 		mockedRepository.AssertExpectations(t)
 	})
 
+	t.Run("RemoveUserList() should return a conflictError when the version does not match", func(t *testing.T) {
+		version := 2
+
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("Remove", stores.And(userListFilter("id", "uid", models.RoleOwner), stores.Eq("version", version))).Return(&appErrors.NotFoundError{Model: "lists"}).Once()
+		mockedRepository.On("GetOne", &models.List{}, stores.Query{Filter: userListFilter("id", "uid", models.RoleViewer), Projection: stores.Fields("userId", "collaborators")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.List).UserID = "uid"
+		})
+
+		err := service.RemoveUserList("id", "uid", &version)
+
+		assert.IsType(t, &appErrors.ConflictError{}, err)
+		assert.Equal(t, "The list has been modified", err.Error())
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+	})
+
 	t.Run("UpdateUserList() should call repository.Update when the id is valid", func(t *testing.T) {
 		l := models.List{
 			ID:   "1",
@@ -72,11 +98,17 @@ func TestListsService(t *testing.T) {
 		}
 
 		u := "userId"
+		created := currentTime.Add(-time.Hour)
 
 		mockedRepository.On("IsValidID", l.ID).Return(true).Once()
-		mockedRepository.On("Update", bson.D{{"_id", l.ID}, {"userId", u}}, &l).Return(errors.New("error")).Once()
+		collaborators := []models.Collaborator{{UserID: u, Role: models.RoleEditor}}
+		mockedRepository.On("GetOne", &models.List{}, updateQuery(l.ID, u)).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*models.List) = models.List{UserID: "owner", Collaborators: collaborators, CreatedAt: created, Version: 3}
+		})
+		want := models.List{ID: l.ID, Name: l.Name, UserID: "owner", Collaborators: collaborators, CreatedAt: created, UpdatedAt: currentTime, Version: 4}
+		mockedRepository.On("Update", stores.And(userListFilter(l.ID, u, models.RoleEditor), stores.Eq("version", 3)), &want).Return(errors.New("error")).Once()
 
-		err := service.UpdateUserList(l.ID, u, &l)
+		err := service.UpdateUserList(l.ID, u, &l, nil)
 
 		assert.NotNil(t, err)
 
@@ -84,6 +116,52 @@ func TestListsService(t *testing.T) {
 		mockedRepository.AssertExpectations(t)
 	})
 
+	t.Run("UpdateUserList() should return a conflictError when the version does not match", func(t *testing.T) {
+		version := 1
+
+		mockedRepository.On("IsValidID", "1").Return(true).Once()
+		mockedRepository.On("GetOne", &models.List{}, updateQuery("1", "userId")).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*models.List) = models.List{UserID: "userId", Version: 2}
+		})
+
+		err := service.UpdateUserList("1", "userId", &models.List{}, &version)
+
+		assert.IsType(t, &appErrors.ConflictError{}, err)
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateUserList() should return a forbiddenError when the user is a viewer", func(t *testing.T) {
+		mockedRepository.On("IsValidID", "1").Return(true).Once()
+		mockedRepository.On("GetOne", &models.List{}, updateQuery("1", "userId")).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.List).Collaborators = []models.Collaborator{{UserID: "userId", Role: models.RoleViewer}}
+		})
+
+		err := service.UpdateUserList("1", "userId", &models.List{}, nil)
+
+		assert.IsType(t, &appErrors.ForbiddenError{}, err)
+		assert.Equal(t, "The editor role is required", err.Error())
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateUserList() should return a conflictError when the list changes while updating it", func(t *testing.T) {
+		mockedRepository.On("IsValidID", "1").Return(true).Once()
+		mockedRepository.On("GetOne", &models.List{}, updateQuery("1", "userId")).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.List).UserID = "userId"
+		})
+		mockedRepository.On("Update", stores.And(userListFilter("1", "userId", models.RoleEditor), stores.In("version", 0, nil)), mock.Anything).Return(&appErrors.NotFoundError{Model: "lists"}).Once()
+
+		err := service.UpdateUserList("1", "userId", &models.List{}, nil)
+
+		assert.IsType(t, &appErrors.ConflictError{}, err)
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+	})
+
 	t.Run("UpdateUserList() should return a badRequestError when the id is not valid", func(t *testing.T) {
 		id := "wadus"
 
@@ -96,7 +174,7 @@ func TestListsService(t *testing.T) {
 
 		mockedRepository.On("IsValidID", id).Return(false).Once()
 
-		err := service.UpdateUserList(id, u, &l)
+		err := service.UpdateUserList(id, u, &l, nil)
 
 		assert.NotNil(t, err)
 
@@ -113,7 +191,7 @@ func TestListsService(t *testing.T) {
 		i := "listId"
 		u := "userId"
 
-		mockedRepository.On("GetOne", &l, bson.D{{"_id", i}, {"userId", u}}, nil).Return(errors.New("error")).Once()
+		mockedRepository.On("GetOne", &l, stores.Query{Filter: userListFilter(i, u, models.RoleViewer)}).Return(errors.New("error")).Once()
 		mockedRepository.On("IsValidID", i).Return(true).Once()
 
 		err := service.GetSingleUserList(i, u, &l)
@@ -141,17 +219,141 @@ func TestListsService(t *testing.T) {
 		mockedRepository.AssertExpectations(t)
 	})
 
-	t.Run("GetUserLists() should call repository.Get", func(t *testing.T) {
-		r := []models.GetListsResultDto{}
+	t.Run("GetUserLists() should return the first page sorted by name", func(t *testing.T) {
 		u := "userId"
+		filter := listRoleFilter(u, models.RoleViewer)
+		done := models.Item{Done: true}
+		found := []models.List{
+			{ID: "1", Name: "a", Items: []models.Item{done, {}, {Done: true, Archived: true}}},
+			{ID: "2", Name: "b"},
+			{ID: "3", Name: "c"},
+		}
+		want := []models.GetListsResultDto{
+			{ID: "1", Name: "a", ItemsCount: 2, DoneItemsCount: 1},
+			{ID: "2", Name: "b"},
+			{ID: "3", Name: "c"},
+		}
 
-		mockedRepository.On("Get", &r, bson.D{{"userId", u}}, bson.M{"name": 1}).Return(errors.New("error")).Once()
+		mockedRepository.On("Count", filter).Return(5, nil).Once()
+		mockedRepository.On("Get", &[]models.List{}, stores.Query{
+			Filter:     filter,
+			Projection: stores.Fields("name", "createdAt", "updatedAt", "items"),
+			Sort:       []stores.Sort{stores.Asc("name"), stores.Asc(stores.IDField)},
+			Limit:      3,
+		}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]models.List) = found
+		})
 
-		err := service.GetUserLists(u, &r)
+		r := models.GetListsPageDto{}
+		err := service.GetUserLists(u, models.GetListsOptions{Limit: 2}, &r)
 
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
+		assert.Equal(t, want[:2], r.Lists)
+		assert.Equal(t, 5, r.Total)
+		assert.NotEmpty(t, r.NextCursor)
 
 		mockedSession.AssertExpectations(t)
 		mockedRepository.AssertExpectations(t)
+
+		t.Run("and the next page from the cursor", func(t *testing.T) {
+			cursorFilter := stores.Or(stores.Gt("name", "b"), stores.And(stores.Eq("name", "b"), stores.Gt(stores.IDField, "2")))
+
+			mockedRepository.On("Count", filter).Return(5, nil).Once()
+			mockedRepository.On("Get", &[]models.List{}, stores.Query{
+				Filter:     stores.And(filter, cursorFilter),
+				Projection: stores.Fields("name", "createdAt", "updatedAt", "items"),
+				Sort:       []stores.Sort{stores.Asc("name"), stores.Asc(stores.IDField)},
+				Limit:      3,
+			}).Return(nil).Once().Run(func(args mock.Arguments) {
+				*args.Get(0).(*[]models.List) = found[2:]
+			})
+
+			next := models.GetListsPageDto{}
+			err := service.GetUserLists(u, models.GetListsOptions{Limit: 2, Cursor: r.NextCursor}, &next)
+
+			assert.Nil(t, err)
+			assert.Equal(t, want[2:], next.Lists)
+			assert.Empty(t, next.NextCursor)
+
+			mockedRepository.AssertExpectations(t)
+		})
+	})
+
+	t.Run("GetUserLists() should filter by name and sort by the given field", func(t *testing.T) {
+		u := "userId"
+		filter := stores.And(listRoleFilter(u, models.RoleViewer), stores.Contains("name", "shop"))
+
+		mockedRepository.On("Count", filter).Return(0, nil).Once()
+		mockedRepository.On("Get", &[]models.List{}, stores.Query{
+			Filter:     filter,
+			Projection: stores.Fields("name", "createdAt", "updatedAt", "items"),
+			Sort:       []stores.Sort{stores.Desc("updatedAt"), stores.Asc(stores.IDField)},
+			Limit:      defaultListsPageSize + 1,
+		}).Return(nil).Once()
+
+		r := models.GetListsPageDto{}
+		err := service.GetUserLists(u, models.GetListsOptions{Name: "shop", SortBy: "updatedAt", Descending: true}, &r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []models.GetListsResultDto{}, r.Lists)
+
+		mockedRepository.AssertExpectations(t)
 	})
+
+	t.Run("GetUserLists() should return a badRequestError when the sort field is not valid", func(t *testing.T) {
+		err := service.GetUserLists("userId", models.GetListsOptions{SortBy: "wadus"}, &models.GetListsPageDto{})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, `Lists can't be sorted by "wadus"`, err.Error())
+	})
+
+	t.Run("GetUserLists() should return a badRequestError when the cursor is not valid", func(t *testing.T) {
+		cursor := newListsCursor(models.GetListsOptions{SortBy: "createdAt"}, models.GetListsResultDto{ID: "1"}).encode()
+
+		for _, c := range []string{"wadus", cursor} {
+			mockedRepository.On("Count", listRoleFilter("userId", models.RoleViewer)).Return(1, nil).Once()
+
+			err := service.GetUserLists("userId", models.GetListsOptions{Cursor: c}, &models.GetListsPageDto{})
+
+			assert.IsType(t, &appErrors.BadRequestError{}, err)
+			assert.Equal(t, "Invalid cursor", err.Error())
+		}
+
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("GetUserLists() should return the error when the count fails", func(t *testing.T) {
+		mockedRepository.On("Count", listRoleFilter("userId", models.RoleViewer)).Return(0, errors.New("error")).Once()
+
+		err := service.GetUserLists("userId", models.GetListsOptions{}, &models.GetListsPageDto{})
+
+		assert.NotNil(t, err)
+
+		mockedRepository.AssertExpectations(t)
+	})
+}
+
+func TestListsCursorFilter(t *testing.T) {
+	created := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	c := newListsCursor(models.GetListsOptions{SortBy: "createdAt", Descending: true}, models.GetListsResultDto{ID: "1", CreatedAt: created})
+
+	decoded, err := decodeListsCursor(c.encode())
+
+	assert.Nil(t, err)
+	assert.Equal(t, stores.Or(stores.Lt("createdAt", created), stores.And(stores.Eq("createdAt", created), stores.Gt(stores.IDField, "1"))), decoded.filter())
+}
+
+// updateQuery returns the query used by UpdateUserList to read the list
+func updateQuery(id string, userID string) stores.Query {
+	return stores.Query{
+		Filter:     userListFilter(id, userID, models.RoleViewer),
+		Projection: stores.Fields("userId", "collaborators", "shareLinks", "createdAt", "version", "items"),
+	}
+}
+
+// setNow makes the services use the given time and returns the function which restores the clock
+func setNow(t time.Time) func() {
+	previous := now
+	now = func() time.Time { return t }
+	return func() { now = previous }
 }
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+)
+
+// LoginAttemptsService is the interface a login attempts service must implement
+type LoginAttemptsService interface {
+	CheckLoginAllowed(userName string, ip string) error
+	AddLoginFailure(userName string, ip string) error
+	ResetLoginFailures(userName string) error
+}
+
+// loginThrottle contains how the failed logins of a kind of key are limited. The first
+// failures are free and each one after them doubles the lockout time.
+type loginThrottle struct {
+	prefix       string
+	freeFailures int
+}
+
+// the ip addresses have more free failures because several users can share them
+var (
+	userLoginThrottle = loginThrottle{prefix: "user:", freeFailures: 5}
+	ipLoginThrottle   = loginThrottle{prefix: "ip:", freeFailures: 20}
+)
+
+const (
+	loginLockoutBase = time.Second
+	loginLockoutMax  = time.Minute * 15
+	// loginFailuresReset is the time without failures after which they are forgotten
+	loginFailuresReset = time.Hour
+)
+
+// MyLoginAttemptsService is the service which tracks the failed logins
+type MyLoginAttemptsService struct {
+	session stores.MongoSession
+}
+
+// NewMyLoginAttemptsService returns a new login attempts service
+func NewMyLoginAttemptsService(session stores.MongoSession) *MyLoginAttemptsService {
+	return &MyLoginAttemptsService{session}
+}
+
+// CheckLoginAllowed returns a TooManyRequestsError while the user name or the ip address
+// are locked out
+func (s *MyLoginAttemptsService) CheckLoginAllowed(userName string, ip string) error {
+	var retryAfter time.Duration
+	for _, key := range loginAttemptKeys(userName, ip) {
+		a := models.LoginAttempt{}
+		err := s.loginAttemptsRepository().GetOne(&a, stores.Query{Filter: stores.Eq("key", key.value)})
+		if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if wait := a.LockedUntil.Sub(now()); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return &appErrors.TooManyRequestsError{Msg: "Too many failed login attempts", RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// AddLoginFailure counts a failed login of the user name from the ip address and locks
+// them out when they have too many failures
+func (s *MyLoginAttemptsService) AddLoginFailure(userName string, ip string) error {
+	for _, key := range loginAttemptKeys(userName, ip) {
+		if err := s.addFailure(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResetLoginFailures forgets the failed logins of the user name after a successful login.
+// The failures of the ip address are kept, so a valid account can't be used to reset them.
+func (s *MyLoginAttemptsService) ResetLoginFailures(userName string) error {
+	return ignoreNotFound(s.loginAttemptsRepository().Remove(stores.Eq("key", userLoginThrottle.prefix+userName)))
+}
+
+// addFailure counts a failure of the key. The failures are incremented atomically, so the
+// concurrent failures are all counted.
+func (s *MyLoginAttemptsService) addFailure(key loginAttemptKey) error {
+	n := now()
+	repo := s.loginAttemptsRepository()
+
+	// the failures are forgotten after some time without them
+	expired := stores.And(stores.Eq("key", key.value), stores.Lt("lastFailureAt", n.Add(-loginFailuresReset)))
+	if err := ignoreNotFound(repo.Modify(expired, stores.Set("failures", 0))); err != nil {
+		return err
+	}
+
+	a := models.LoginAttempt{Key: key.value}
+	if err := repo.Upsert(stores.Eq("key", key.value), &a, stores.Inc("failures", 1), stores.Set("lastFailureAt", n)); err != nil {
+		return err
+	}
+
+	if a.Failures <= key.throttle.freeFailures {
+		return nil
+	}
+
+	// the lockout is only extended, so a concurrent failure can't shorten it
+	lockedUntil := n.Add(loginLockout(a.Failures - key.throttle.freeFailures))
+	shorter := stores.And(stores.Eq("key", key.value), stores.Lt("lockedUntil", lockedUntil))
+
+	return ignoreNotFound(repo.Modify(shorter, stores.Set("lockedUntil", lockedUntil)))
+}
+
+func (s *MyLoginAttemptsService) loginAttemptsRepository() stores.Repository {
+	return s.session.GetRepository("loginAttempts")
+}
+
+type loginAttemptKey struct {
+	value    string
+	throttle loginThrottle
+}
+
+// loginAttemptKeys returns the keys whose failures are tracked for a login. The ip address
+// is unknown in some cases, like in the tests.
+func loginAttemptKeys(userName string, ip string) []loginAttemptKey {
+	keys := []loginAttemptKey{{userLoginThrottle.prefix + userName, userLoginThrottle}}
+	if ip != "" {
+		keys = append(keys, loginAttemptKey{ipLoginThrottle.prefix + ip, ipLoginThrottle})
+	}
+
+	return keys
+}
+
+// loginLockout returns the lockout time after a number of failures over the free ones
+func loginLockout(failures int) time.Duration {
+	d := loginLockoutBase
+	for i := 1; i < failures && d < loginLockoutMax; i++ {
+		d *= 2
+	}
+
+	if d > loginLockoutMax {
+		return loginLockoutMax
+	}
+
+	return d
+}
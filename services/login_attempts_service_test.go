@@ -0,0 +1,105 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginAttemptsService(t *testing.T) {
+	currentTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	defer setNow(currentTime)()
+
+	t.Run("locks the user name out after the free failures with an exponential backoff", func(t *testing.T) {
+		service := NewMyLoginAttemptsService(stores.NewMyMemorySession())
+
+		for i := 0; i < userLoginThrottle.freeFailures; i++ {
+			assert.Nil(t, service.CheckLoginAllowed("wadus", "1.1.1.1"))
+			assert.Nil(t, service.AddLoginFailure("wadus", "1.1.1.1"))
+		}
+		assert.Nil(t, service.CheckLoginAllowed("wadus", "1.1.1.1"))
+
+		assert.Nil(t, service.AddLoginFailure("wadus", "1.1.1.1"))
+		err := service.CheckLoginAllowed("wadus", "2.2.2.2")
+		assert.Equal(t, &appErrors.TooManyRequestsError{Msg: "Too many failed login attempts", RetryAfter: time.Second}, err)
+
+		assert.Nil(t, service.AddLoginFailure("wadus", "1.1.1.1"))
+		err = service.CheckLoginAllowed("wadus", "2.2.2.2")
+		assert.Equal(t, time.Second*2, err.(*appErrors.TooManyRequestsError).RetryAfter)
+
+		assert.Nil(t, service.CheckLoginAllowed("other", "2.2.2.2"), "the other users shouldn't be locked out")
+
+		defer setNow(currentTime.Add(time.Second * 2))()
+		assert.Nil(t, service.CheckLoginAllowed("wadus", "2.2.2.2"), "the lockout should expire")
+	})
+
+	t.Run("locks the ip address out after its free failures", func(t *testing.T) {
+		service := NewMyLoginAttemptsService(stores.NewMyMemorySession())
+
+		for i := 0; i <= ipLoginThrottle.freeFailures; i++ {
+			assert.Nil(t, service.AddLoginFailure(string(rune('a'+i)), "1.1.1.1"))
+		}
+
+		assert.IsType(t, &appErrors.TooManyRequestsError{}, service.CheckLoginAllowed("wadus", "1.1.1.1"))
+		assert.Nil(t, service.CheckLoginAllowed("wadus", "2.2.2.2"))
+	})
+
+	t.Run("forgets the failures of the user name after a successful login", func(t *testing.T) {
+		service := NewMyLoginAttemptsService(stores.NewMyMemorySession())
+
+		for i := 0; i <= userLoginThrottle.freeFailures; i++ {
+			assert.Nil(t, service.AddLoginFailure("wadus", ""))
+		}
+		assert.IsType(t, &appErrors.TooManyRequestsError{}, service.CheckLoginAllowed("wadus", ""))
+
+		assert.Nil(t, service.ResetLoginFailures("wadus"))
+		assert.Nil(t, service.CheckLoginAllowed("wadus", ""))
+		assert.Nil(t, service.ResetLoginFailures("wadus"), "resetting without failures shouldn't fail")
+	})
+
+	t.Run("forgets the old failures", func(t *testing.T) {
+		service := NewMyLoginAttemptsService(stores.NewMyMemorySession())
+
+		for i := 0; i < userLoginThrottle.freeFailures; i++ {
+			assert.Nil(t, service.AddLoginFailure("wadus", ""))
+		}
+
+		defer setNow(currentTime.Add(loginFailuresReset + time.Second))()
+
+		assert.Nil(t, service.AddLoginFailure("wadus", ""))
+		assert.Nil(t, service.CheckLoginAllowed("wadus", ""))
+	})
+
+	t.Run("counts all the concurrent failures", func(t *testing.T) {
+		session := stores.NewMyMemorySession()
+		service := NewMyLoginAttemptsService(session)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.Nil(t, service.AddLoginFailure("wadus", "1.1.1.1"))
+			}()
+		}
+		wg.Wait()
+
+		got := []models.LoginAttempt{}
+		assert.Nil(t, session.GetRepository("loginAttempts").Get(&got, stores.Query{Filter: stores.Eq("key", "user:wadus")}))
+		assert.Len(t, got, 1)
+		assert.Equal(t, 10, got[0].Failures)
+		assert.IsType(t, &appErrors.TooManyRequestsError{}, service.CheckLoginAllowed("wadus", ""))
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	assert.Equal(t, time.Second, loginLockout(1))
+	assert.Equal(t, time.Second*8, loginLockout(4))
+	assert.Equal(t, loginLockoutMax, loginLockout(11))
+	assert.Equal(t, loginLockoutMax, loginLockout(1000))
+}
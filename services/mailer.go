@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+)
+
+// Mailer is the interface a mailer must implement
+type Mailer interface {
+	SendMail(to string, subject string, body string) error
+}
+
+// MySMTPMailer is the mailer which sends the mails through a smtp server
+type MySMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewMySMTPMailer returns a mailer which sends the mails from the given address through the
+// smtp server in addr, a host:port pair. The server is only authenticated against when
+// there is a user name.
+func NewMySMTPMailer(addr string, from string, userName string, password string) *MySMTPMailer {
+	m := &MySMTPMailer{addr: addr, from: from}
+	if userName != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		m.auth = smtp.PlainAuth("", userName, password, host)
+	}
+
+	return m
+}
+
+// SendMail sends a plain text mail
+func (m *MySMTPMailer) SendMail(to string, subject string, body string) error {
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, newMailMessage(m.from, to, subject, body))
+}
+
+// MyLogMailer is the mailer which writes the mails instead of sending them, to a file or
+// the log. It is meant for development and tests.
+type MyLogMailer struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// NewMyLogMailer returns a mailer which writes the mails to w
+func NewMyLogMailer(w io.Writer) *MyLogMailer {
+	return &MyLogMailer{w: w}
+}
+
+// SendMail writes the mail
+func (m *MyLogMailer) SendMail(to string, subject string, body string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, err := m.w.Write(append(newMailMessage("", to, subject, body), '\n'))
+	return err
+}
+
+// newMailMessage returns the message of a plain text mail. The line breaks of the headers
+// are removed, so they can't be used to add other headers.
+func newMailMessage(from string, to string, subject string, body string) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+
+	var b strings.Builder
+	if from != "" {
+		fmt.Fprintf(&b, "From: %v\r\n", header.Replace(from))
+	}
+	fmt.Fprintf(&b, "To: %v\r\n", header.Replace(to))
+	fmt.Fprintf(&b, "Subject: %v\r\n", header.Replace(subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body)
+
+	return []byte(b.String())
+}
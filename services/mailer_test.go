@@ -0,0 +1,21 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMyLogMailer(t *testing.T) {
+	var b bytes.Buffer
+	m := NewMyLogMailer(&b)
+
+	err := m.SendMail("wadus@example.com", "The subject\r\nBcc: other@example.com", "The body")
+
+	assert.Nil(t, err)
+	assert.Contains(t, b.String(), "To: wadus@example.com\r\n")
+	assert.Contains(t, b.String(), "Subject: The subjectBcc: other@example.com\r\n", "the line breaks of the headers should be removed")
+	assert.True(t, strings.HasSuffix(b.String(), "\r\n\r\nThe body\n"))
+}
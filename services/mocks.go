@@ -18,23 +18,38 @@ type mockedRepository struct {
 	mock.Mock
 }
 
-func (m *mockedRepository) Get(doc interface{}, query interface{}, selector interface{}) error {
-	args := m.Called(doc, query, selector)
+func (m *mockedRepository) Get(doc interface{}, query stores.Query) error {
+	args := m.Called(doc, query)
 	return args.Error(0)
 }
 
-func (m *mockedRepository) GetOne(doc interface{}, query interface{}, selector interface{}) error {
-	args := m.Called(doc, query, selector)
+func (m *mockedRepository) GetOne(doc interface{}, query stores.Query) error {
+	args := m.Called(doc, query)
 	return args.Error(0)
 }
 
-func (m *mockedRepository) Remove(query interface{}) error {
-	args := m.Called(query)
+func (m *mockedRepository) Count(filter stores.Filter) (int, error) {
+	args := m.Called(filter)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockedRepository) Remove(filter stores.Filter) error {
+	args := m.Called(filter)
+	return args.Error(0)
+}
+
+func (m *mockedRepository) Update(filter stores.Filter, doc interface{}) error {
+	args := m.Called(filter, doc)
+	return args.Error(0)
+}
+
+func (m *mockedRepository) Modify(filter stores.Filter, modifications ...stores.Modification) error {
+	args := m.Called(filter, modifications)
 	return args.Error(0)
 }
 
-func (m *mockedRepository) Update(query interface{}, doc interface{}) error {
-	args := m.Called(query, doc)
+func (m *mockedRepository) Upsert(filter stores.Filter, doc interface{}, modifications ...stores.Modification) error {
+	args := m.Called(filter, doc, modifications)
 	return args.Error(0)
 }
 
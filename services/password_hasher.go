@@ -0,0 +1,177 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher is the interface which contains the methods used to hash the passwords.
+// It replaces the BcryptProvider, so the hashes don't depend on a single algorithm.
+type PasswordHasher interface {
+	GenerateFromPassword(password []byte) ([]byte, error)
+	CompareHashAndPassword(hashedPassword, password []byte) error
+	NeedsRehash(hashedPassword []byte) bool
+}
+
+// The algorithms used to hash the passwords
+const (
+	HashAlgorithmBcrypt   = "bcrypt"
+	HashAlgorithmArgon2id = "argon2id"
+)
+
+// Argon2Params contains the cost of the argon2id hashes. The memory is in KiB.
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// PasswordHasherConfig contains how the new hashes are generated
+type PasswordHasherConfig struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// DefaultPasswordHasherConfig returns the settings used when they aren't configured
+func DefaultPasswordHasherConfig() PasswordHasherConfig {
+	return PasswordHasherConfig{
+		Algorithm:  HashAlgorithmBcrypt,
+		BcryptCost: 12,
+		Argon2:     Argon2Params{Time: 3, Memory: 64 * 1024, Threads: 4},
+	}
+}
+
+const (
+	argon2Prefix    = "$argon2id$"
+	argon2SaltBytes = 16
+	argon2KeyBytes  = 32
+)
+
+var errPasswordMismatch = errors.New("the password doesn't match the hash")
+
+// MyPasswordHasher is the implementation for PasswordHasher. It checks both the bcrypt and
+// the argon2id hashes and generates the new ones with the configured algorithm.
+type MyPasswordHasher struct {
+	config PasswordHasherConfig
+}
+
+// NewMyPasswordHasher returns a new MyPasswordHasher or an error when the config isn't valid
+func NewMyPasswordHasher(config PasswordHasherConfig) (*MyPasswordHasher, error) {
+	switch config.Algorithm {
+	case HashAlgorithmBcrypt:
+		if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("the bcrypt cost must be between %v and %v", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case HashAlgorithmArgon2id:
+		if config.Argon2.Time == 0 || config.Argon2.Memory == 0 || config.Argon2.Threads == 0 {
+			return nil, errors.New("the argon2id parameters must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", config.Algorithm)
+	}
+
+	return &MyPasswordHasher{config}, nil
+}
+
+// GenerateFromPassword generates a hashed password with the configured algorithm
+func (h *MyPasswordHasher) GenerateFromPassword(password []byte) ([]byte, error) {
+	if h.config.Algorithm == HashAlgorithmArgon2id {
+		return generateArgon2Hash(password, h.config.Argon2)
+	}
+
+	return bcrypt.GenerateFromPassword(password, h.config.BcryptCost)
+}
+
+// CompareHashAndPassword checks if the given hashed password and the password matches
+func (h *MyPasswordHasher) CompareHashAndPassword(hashedPassword, password []byte) error {
+	if !isArgon2Hash(hashedPassword) {
+		return bcrypt.CompareHashAndPassword(hashedPassword, password)
+	}
+
+	params, salt, key, err := parseArgon2Hash(hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return errPasswordMismatch
+	}
+
+	return nil
+}
+
+// NeedsRehash returns true when the hash wasn't generated with the configured algorithm
+// and cost, so it should be replaced the next time the password is known
+func (h *MyPasswordHasher) NeedsRehash(hashedPassword []byte) bool {
+	if h.config.Algorithm == HashAlgorithmArgon2id {
+		if !isArgon2Hash(hashedPassword) {
+			return true
+		}
+
+		params, _, _, err := parseArgon2Hash(hashedPassword)
+		return err != nil || params != h.config.Argon2
+	}
+
+	cost, err := bcrypt.Cost(hashedPassword)
+	return err != nil || cost != h.config.BcryptCost
+}
+
+// generateArgon2Hash returns the hash in the format used by the reference implementation,
+// $argon2id$v=19$m=65536,t=3,p=4$salt$key, so it contains everything needed to check it
+func generateArgon2Hash(password []byte, params Argon2Params) ([]byte, error) {
+	salt := make([]byte, argon2SaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, argon2KeyBytes)
+
+	hash := fmt.Sprintf("%vv=%d$m=%d,t=%d,p=%d$%v$%v", argon2Prefix, argon2.Version, params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+
+	return []byte(hash), nil
+}
+
+func parseArgon2Hash(hashedPassword []byte) (Argon2Params, []byte, []byte, error) {
+	params := Argon2Params{}
+	invalidErr := errors.New("invalid argon2id hash")
+
+	parts := strings.Split(string(hashedPassword), "$")
+	if len(parts) != 6 {
+		return params, nil, nil, invalidErr
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, invalidErr
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, invalidErr
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, invalidErr
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, invalidErr
+	}
+
+	return params, salt, key, nil
+}
+
+func isArgon2Hash(hashedPassword []byte) bool {
+	return strings.HasPrefix(string(hashedPassword), argon2Prefix)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestPasswordHasher returns a hasher with the minimum cost, so the tests are fast
+func newTestPasswordHasher() *MyPasswordHasher {
+	config := DefaultPasswordHasherConfig()
+	config.BcryptCost = bcrypt.MinCost
+
+	h, _ := NewMyPasswordHasher(config)
+	return h
+}
+
+func TestPasswordHasher(t *testing.T) {
+	bcryptHasher := newTestPasswordHasher()
+
+	argon2Config := DefaultPasswordHasherConfig()
+	argon2Config.Algorithm = HashAlgorithmArgon2id
+	argon2Config.Argon2 = Argon2Params{Time: 1, Memory: 64, Threads: 1}
+	argon2Hasher, err := NewMyPasswordHasher(argon2Config)
+	assert.Nil(t, err)
+
+	password := []byte("the_password")
+
+	t.Run("generates and checks bcrypt hashes", func(t *testing.T) {
+		hash, err := bcryptHasher.GenerateFromPassword(password)
+
+		assert.Nil(t, err)
+		assert.Nil(t, bcryptHasher.CompareHashAndPassword(hash, password))
+		assert.NotNil(t, bcryptHasher.CompareHashAndPassword(hash, []byte("wadus")))
+		assert.False(t, bcryptHasher.NeedsRehash(hash))
+	})
+
+	t.Run("generates and checks argon2id hashes", func(t *testing.T) {
+		hash, err := argon2Hasher.GenerateFromPassword(password)
+
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(string(hash), "$argon2id$v=19$m=64,t=1,p=1$"))
+		assert.Nil(t, argon2Hasher.CompareHashAndPassword(hash, password))
+		assert.NotNil(t, argon2Hasher.CompareHashAndPassword(hash, []byte("wadus")))
+		assert.False(t, argon2Hasher.NeedsRehash(hash))
+	})
+
+	t.Run("checks the hashes of both algorithms and needs to rehash the other one", func(t *testing.T) {
+		bcryptHash, _ := bcryptHasher.GenerateFromPassword(password)
+		argon2Hash, _ := argon2Hasher.GenerateFromPassword(password)
+
+		assert.Nil(t, argon2Hasher.CompareHashAndPassword(bcryptHash, password))
+		assert.Nil(t, bcryptHasher.CompareHashAndPassword(argon2Hash, password))
+		assert.True(t, argon2Hasher.NeedsRehash(bcryptHash))
+		assert.True(t, bcryptHasher.NeedsRehash(argon2Hash))
+	})
+
+	t.Run("needs to rehash the hashes with another cost", func(t *testing.T) {
+		oldHash, _ := bcrypt.GenerateFromPassword(password, bcrypt.MinCost+1)
+		assert.True(t, bcryptHasher.NeedsRehash(oldHash))
+
+		other := argon2Config
+		other.Argon2.Time = 2
+		otherHasher, _ := NewMyPasswordHasher(other)
+		otherHash, _ := otherHasher.GenerateFromPassword(password)
+		assert.True(t, argon2Hasher.NeedsRehash(otherHash))
+	})
+
+	t.Run("returns an error with an invalid hash", func(t *testing.T) {
+		assert.NotNil(t, argon2Hasher.CompareHashAndPassword([]byte("$argon2id$v=19$wadus"), password))
+		assert.True(t, argon2Hasher.NeedsRehash([]byte("$argon2id$v=19$wadus")))
+	})
+}
+
+func TestNewMyPasswordHasher(t *testing.T) {
+	config := DefaultPasswordHasherConfig()
+	config.BcryptCost = 3
+	_, err := NewMyPasswordHasher(config)
+	assert.NotNil(t, err)
+
+	config = DefaultPasswordHasherConfig()
+	config.Algorithm = "md5"
+	_, err = NewMyPasswordHasher(config)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+)
+
+// PasswordPolicy contains the rules the new passwords must follow. Its zero value accepts
+// any password.
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength is in bytes, because bcrypt only uses the first 72 bytes of the passwords
+	MaxLength int
+	// MinCharacterClasses is the number of classes, among lowercase letters, uppercase letters,
+	// digits and symbols, the passwords must contain
+	MinCharacterClasses int
+	// DenyList contains the lowercase common passwords which can't be used
+	DenyList         map[string]bool
+	DisallowUserName bool
+}
+
+// commonPasswords are the most used passwords, which are denied by default
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "qwerty", "password", "password1", "password123",
+	"111111", "123123", "abc123", "1q2w3e4r", "qwerty123", "qwertyuiop", "iloveyou", "admin", "admin123",
+	"welcome", "welcome1", "letmein", "monkey", "dragon", "football", "baseball", "sunshine", "princess",
+	"trustno1", "superman", "starwars", "passw0rd", "p@ssw0rd", "changeme", "secret", "zaq12wsx",
+	"1qaz2wsx", "asdfghjkl", "qazwsx", "master", "whatever", "000000", "654321", "987654321",
+}
+
+// DefaultPasswordPolicy returns the policy used when it isn't configured
+func DefaultPasswordPolicy() PasswordPolicy {
+	p := PasswordPolicy{
+		MinLength:           8,
+		MaxLength:           72,
+		MinCharacterClasses: 2,
+		DisallowUserName:    true,
+	}
+	p.Deny(commonPasswords...)
+
+	return p
+}
+
+// Deny adds the passwords to the deny list
+func (p *PasswordPolicy) Deny(passwords ...string) {
+	if p.DenyList == nil {
+		p.DenyList = map[string]bool{}
+	}
+
+	for _, password := range passwords {
+		p.DenyList[strings.ToLower(password)] = true
+	}
+}
+
+// passwordField is the field of the requests which contains the new passwords
+const passwordField = "newPassword"
+
+// Check returns a ValidationError with the rules the password of the user breaks
+func (p PasswordPolicy) Check(password string, userName string) error {
+	fields := []appErrors.FieldError{}
+	add := func(format string, a ...interface{}) {
+		fields = append(fields, appErrors.FieldError{Field: passwordField, Msg: fmt.Sprintf(format, a...)})
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		add("The password must have at least %v characters", p.MinLength)
+	}
+
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		add("The password can't be longer than %v bytes", p.MaxLength)
+	}
+
+	if passwordCharacterClasses(password) < p.MinCharacterClasses {
+		add("The password must contain at least %v of lowercase letters, uppercase letters, digits and symbols", p.MinCharacterClasses)
+	}
+
+	if p.DenyList[strings.ToLower(password)] {
+		add("The password is too common")
+	}
+
+	if p.DisallowUserName && userName != "" && strings.EqualFold(password, userName) {
+		add("The password can't be the user name")
+	}
+
+	if len(fields) > 0 {
+		return &appErrors.ValidationError{Msg: "The password doesn't meet the password policy", Fields: fields}
+	}
+
+	return nil
+}
+
+func passwordCharacterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
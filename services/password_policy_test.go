@@ -0,0 +1,40 @@
+package services
+
+import (
+	"testing"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	p := DefaultPasswordPolicy()
+
+	fieldErrors := func(err error) []string {
+		msgs := []string{}
+		if validationErr, ok := err.(*appErrors.ValidationError); ok {
+			for _, f := range validationErr.Fields {
+				assert.Equal(t, "newPassword", f.Field)
+				msgs = append(msgs, f.Msg)
+			}
+		}
+		return msgs
+	}
+
+	assert.Nil(t, p.Check("correct horse 7", "wadus"))
+	assert.Nil(t, p.Check("Ñandú-Ñandú", "wadus"), "the length is counted in characters")
+
+	assert.Equal(t, []string{
+		"The password must have at least 8 characters",
+		"The password must contain at least 2 of lowercase letters, uppercase letters, digits and symbols",
+	}, fieldErrors(p.Check("abc", "wadus")))
+
+	assert.Equal(t, []string{"The password is too common"}, fieldErrors(p.Check("Password1", "wadus")))
+	assert.Equal(t, []string{"The password can't be the user name"}, fieldErrors(p.Check("Wadus2020", "wadus2020")))
+	assert.Equal(t, []string{"The password can't be longer than 72 bytes"}, fieldErrors(p.Check("a1"+string(make([]byte, 71)), "wadus")))
+
+	p.Deny("Custom-Secret-1")
+	assert.Equal(t, []string{"The password is too common"}, fieldErrors(p.Check("custom-secret-1", "wadus")))
+
+	assert.Nil(t, PasswordPolicy{}.Check("", "wadus"), "the zero policy accepts any password")
+}
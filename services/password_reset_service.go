@@ -0,0 +1,138 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+)
+
+// PasswordResetService is the interface a password reset service must implement
+type PasswordResetService interface {
+	RequestPasswordReset(email string) error
+	ResetPassword(dto models.ResetPasswordDto) (string, error)
+}
+
+// PasswordResetConfig contains the settings of the password reset
+type PasswordResetConfig struct {
+	// URL is the page the password reset mails link to. The token is added to it as the
+	// token query parameter. The password reset is disabled without it.
+	URL           string
+	TokenDuration time.Duration
+}
+
+// DefaultPasswordResetConfig returns the settings used when they aren't configured, with
+// the password reset disabled
+func DefaultPasswordResetConfig() PasswordResetConfig {
+	return PasswordResetConfig{
+		TokenDuration: time.Hour,
+	}
+}
+
+// MyPasswordResetService is the service which lets the users who forgot their password set
+// a new one
+type MyPasswordResetService struct {
+	session  stores.MongoSession
+	usersSrv *MyUsersService
+	mailer   Mailer
+	config   PasswordResetConfig
+}
+
+// NewMyPasswordResetService returns a new password reset service, which sets the passwords
+// with the users service
+func NewMyPasswordResetService(session stores.MongoSession, usersSrv *MyUsersService, mailer Mailer, config PasswordResetConfig) *MyPasswordResetService {
+	return &MyPasswordResetService{
+		session:  session,
+		usersSrv: usersSrv,
+		mailer:   mailer,
+		config:   config,
+	}
+}
+
+// RequestPasswordReset sends a mail with a password reset link to the user with the email
+// address. It doesn't return an error when there isn't such a user, so the response doesn't
+// tell which email addresses are used. Only the last link sent to a user is valid.
+func (s *MyPasswordResetService) RequestPasswordReset(email string) error {
+	if s.config.URL == "" {
+		return &appErrors.ForbiddenError{Msg: "The password reset is disabled", InternalError: nil}
+	}
+
+	u := models.User{}
+	err := s.usersRepository().GetOne(&u, stores.Query{Filter: stores.Eq("email", email), Projection: stores.Fields("userName", "email")})
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound || email == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := removeUserTokens(s.userTokensRepository(), u.ID, models.UserTokenPasswordReset); err != nil {
+		return err
+	}
+
+	token, err := addUserToken(s.userTokensRepository(), u.ID, models.UserTokenPasswordReset, s.config.TokenDuration)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %v,\n\nOpen this link to set a new password:\n\n%v\n\nThe link expires in %v. If you didn't ask for it you can ignore this mail.\n",
+		u.UserName, linkWithToken(s.config.URL, token), s.config.TokenDuration)
+	// the response must be the same as for the unused email addresses
+	if err := s.mailer.SendMail(u.Email, "Reset your password", body); err != nil {
+		log.Printf("Error sending the password reset mail to the user %v. Error: %v", u.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets the new password of the user of a password reset token and returns the
+// user id. Each token can only be used once.
+func (s *MyPasswordResetService) ResetPassword(dto models.ResetPasswordDto) (string, error) {
+	// the token isn't used when the new password isn't valid, so it can be tried again
+	if err := checkPasswordsMatch(dto.NewPassword, dto.ConfirmNewPassword); err != nil {
+		return "", err
+	}
+
+	t, err := findUserToken(s.userTokensRepository(), dto.Token, models.UserTokenPasswordReset)
+	if err != nil {
+		return "", err
+	}
+
+	u := models.User{}
+	err = s.usersSrv.GetUserByID(t.UserID, &u)
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return "", getInvalidUserTokenError()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.usersSrv.policy.Check(dto.NewPassword, u.UserName); err != nil {
+		return "", err
+	}
+
+	if _, err := useUserToken(s.userTokensRepository(), dto.Token, models.UserTokenPasswordReset); err != nil {
+		return "", err
+	}
+
+	err = s.usersSrv.setPassword(u.ID, u.UserName, dto.NewPassword, dto.ConfirmNewPassword)
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return "", getInvalidUserTokenError()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return u.ID, nil
+}
+
+func (s *MyPasswordResetService) usersRepository() stores.Repository {
+	return s.session.GetRepository("users")
+}
+
+func (s *MyPasswordResetService) userTokensRepository() stores.Repository {
+	return s.session.GetRepository("userTokens")
+}
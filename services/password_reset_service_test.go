@@ -0,0 +1,114 @@
+package services
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordResetService(t *testing.T) {
+	currentTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	defer setNow(currentTime)()
+
+	config := DefaultPasswordResetConfig()
+	config.URL = "https://lists.example.com/reset"
+
+	tokenRegexp := regexp.MustCompile(`https://lists\.example\.com/reset\?token=([A-Za-z0-9_-]+)`)
+
+	newService := func(mails *bytes.Buffer) (*MyPasswordResetService, stores.MongoSession) {
+		session := stores.NewMyMemorySession()
+		u := models.User{UserName: "wadus", Email: "wadus@example.com", PasswordHash: "hash", Role: models.UserRoleUser, IsActive: true, MustChangePassword: true}
+		_, err := session.GetRepository("users").Add(&u)
+		assert.Nil(t, err)
+
+		return NewMyPasswordResetService(session, NewMyUsersService(session, newTestPasswordHasher(), PasswordPolicy{}), NewMyLogMailer(mails), config), session
+	}
+
+	t.Run("RequestPasswordReset() should return a forbiddenError when the password reset is disabled", func(t *testing.T) {
+		session := stores.NewMyMemorySession()
+		service := NewMyPasswordResetService(session, NewMyUsersService(session, newTestPasswordHasher(), PasswordPolicy{}), NewMyLogMailer(&bytes.Buffer{}), DefaultPasswordResetConfig())
+
+		assert.IsType(t, &appErrors.ForbiddenError{}, service.RequestPasswordReset("wadus@example.com"))
+	})
+
+	t.Run("RequestPasswordReset() shouldn't send a mail when the email address isn't used", func(t *testing.T) {
+		var mails bytes.Buffer
+		service, _ := newService(&mails)
+
+		assert.Nil(t, service.RequestPasswordReset("other@example.com"))
+		assert.Empty(t, mails.String())
+	})
+
+	t.Run("RequestPasswordReset() should answer as for the unused email addresses when the mail can't be sent", func(t *testing.T) {
+		session := stores.NewMyMemorySession()
+		_, err := session.GetRepository("users").Add(&models.User{UserName: "wadus", Email: "wadus@example.com"})
+		assert.Nil(t, err)
+		service := NewMyPasswordResetService(session, NewMyUsersService(session, newTestPasswordHasher(), PasswordPolicy{}), failingMailer{}, config)
+
+		assert.Nil(t, service.RequestPasswordReset("wadus@example.com"))
+	})
+
+	t.Run("ResetPassword() should check the password with the user name before using the token", func(t *testing.T) {
+		var mails bytes.Buffer
+		service, _ := newService(&mails)
+		service.usersSrv.policy = PasswordPolicy{DisallowUserName: true}
+
+		assert.Nil(t, service.RequestPasswordReset("wadus@example.com"))
+		token := tokenRegexp.FindStringSubmatch(mails.String())[1]
+
+		_, err := service.ResetPassword(models.ResetPasswordDto{Token: token, NewPassword: "Wadus", ConfirmNewPassword: "Wadus"})
+		assert.IsType(t, &appErrors.ValidationError{}, err)
+
+		_, err = service.ResetPassword(models.ResetPasswordDto{Token: token, NewPassword: "newPass", ConfirmNewPassword: "newPass"})
+		assert.Nil(t, err, "the token shouldn't be used when the password isn't valid")
+	})
+
+	t.Run("ResetPassword() should set the new password once with the token of the last mail", func(t *testing.T) {
+		var mails bytes.Buffer
+		service, session := newService(&mails)
+
+		assert.Nil(t, service.RequestPasswordReset("wadus@example.com"))
+		first := tokenRegexp.FindStringSubmatch(mails.String())[1]
+		mails.Reset()
+
+		assert.Nil(t, service.RequestPasswordReset("wadus@example.com"))
+		assert.Contains(t, mails.String(), "To: wadus@example.com\r\n")
+		last := tokenRegexp.FindStringSubmatch(mails.String())[1]
+
+		_, err := service.ResetPassword(models.ResetPasswordDto{Token: first, NewPassword: "newPass", ConfirmNewPassword: "newPass"})
+		assert.IsType(t, &appErrors.BadRequestError{}, err, "the previous tokens should be removed")
+
+		_, err = service.ResetPassword(models.ResetPasswordDto{Token: last, NewPassword: "newPass", ConfirmNewPassword: "other"})
+		assert.Equal(t, "Passwords don't match", err.Error())
+
+		userID, err := service.ResetPassword(models.ResetPasswordDto{Token: last, NewPassword: "newPass", ConfirmNewPassword: "newPass"})
+		assert.Nil(t, err, "the token shouldn't be used when the passwords don't match")
+
+		u, err := NewMyUsersService(session, newTestPasswordHasher(), PasswordPolicy{}).CheckIfUserPasswordIsOk("wadus", "newPass")
+		assert.Nil(t, err)
+		assert.Equal(t, u.ID, userID)
+		assert.False(t, u.MustChangePassword)
+
+		_, err = service.ResetPassword(models.ResetPasswordDto{Token: last, NewPassword: "newPass", ConfirmNewPassword: "newPass"})
+		assert.IsType(t, &appErrors.BadRequestError{}, err, "the token can only be used once")
+	})
+
+	t.Run("ResetPassword() should return a badRequestError when the token has expired", func(t *testing.T) {
+		var mails bytes.Buffer
+		service, _ := newService(&mails)
+
+		assert.Nil(t, service.RequestPasswordReset("wadus@example.com"))
+		token := tokenRegexp.FindStringSubmatch(mails.String())[1]
+
+		defer setNow(currentTime.Add(config.TokenDuration))()
+
+		_, err := service.ResetPassword(models.ResetPasswordDto{Token: token, NewPassword: "newPass", ConfirmNewPassword: "newPass"})
+		assert.Equal(t, "Invalid or expired token", err.Error())
+	})
+}
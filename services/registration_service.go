@@ -0,0 +1,132 @@
+package services
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+)
+
+// RegistrationService is the interface a registration service must implement
+type RegistrationService interface {
+	Register(dto models.RegistrationDto) error
+	VerifyEmail(token string) error
+}
+
+// RegistrationConfig contains the settings of the self-registration
+type RegistrationConfig struct {
+	Enabled bool
+	// VerificationURL is the page the verification mails link to. The token is added to
+	// it as the token query parameter.
+	VerificationURL           string
+	VerificationTokenDuration time.Duration
+}
+
+// DefaultRegistrationConfig returns the settings used when they aren't configured, with
+// the registration disabled
+func DefaultRegistrationConfig() RegistrationConfig {
+	return RegistrationConfig{
+		VerificationTokenDuration: time.Hour * 48,
+	}
+}
+
+// MyRegistrationService is the service which lets the users sign up themselves
+type MyRegistrationService struct {
+	session  stores.MongoSession
+	usersSrv *MyUsersService
+	mailer   Mailer
+	config   RegistrationConfig
+}
+
+// NewMyRegistrationService returns a new registration service, which adds the users with
+// the users service
+func NewMyRegistrationService(session stores.MongoSession, usersSrv *MyUsersService, mailer Mailer, config RegistrationConfig) *MyRegistrationService {
+	return &MyRegistrationService{
+		session:  session,
+		usersSrv: usersSrv,
+		mailer:   mailer,
+		config:   config,
+	}
+}
+
+// Register adds an inactive user with the user role and sends it a mail with the link
+// which verifies its email address and activates it
+func (s *MyRegistrationService) Register(dto models.RegistrationDto) error {
+	if !s.config.Enabled {
+		return &appErrors.ForbiddenError{Msg: "The registration is disabled", InternalError: nil}
+	}
+
+	if strings.TrimSpace(dto.UserName) == "" {
+		return &appErrors.BadRequestError{Msg: "User name is mandatory", InternalError: nil}
+	}
+
+	if addr, err := mail.ParseAddress(dto.Email); err != nil || addr.Address != dto.Email {
+		return &appErrors.BadRequestError{Msg: "Invalid email address", InternalError: err}
+	}
+
+	count, err := s.usersRepository().Count(stores.Eq("email", dto.Email))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &appErrors.BadRequestError{Msg: "A user with the same email address already exists", InternalError: nil}
+	}
+
+	u := models.User{
+		UserName: dto.UserName,
+		Email:    dto.Email,
+		Role:     models.UserRoleUser,
+		IsActive: false,
+	}
+	id, err := s.usersSrv.addUser(u, dto.NewPassword, dto.ConfirmNewPassword)
+	if err != nil {
+		return err
+	}
+
+	token, err := addUserToken(s.userTokensRepository(), id, models.UserTokenEmailVerification, s.config.VerificationTokenDuration)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %v,\n\nOpen this link to verify your email address and activate your account:\n\n%v\n\nThe link expires in %v.\n",
+		dto.UserName, linkWithToken(s.config.VerificationURL, token), s.config.VerificationTokenDuration)
+	if err := s.mailer.SendMail(dto.Email, "Verify your email address", body); err != nil {
+		// the user is removed, so it can sign up again
+		s.usersRepository().Remove(stores.Eq(stores.IDField, id))
+		s.userTokensRepository().Remove(stores.Eq("userId", id))
+		return &appErrors.UnexpectedError{Msg: "Error sending the verification mail", InternalError: err}
+	}
+
+	return nil
+}
+
+// VerifyEmail verifies the email address of the user of a verification token and activates
+// it. Each token can only be used once.
+func (s *MyRegistrationService) VerifyEmail(token string) error {
+	t, err := useUserToken(s.userTokensRepository(), token, models.UserTokenEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	// only a pending registration is activated
+	err = s.usersRepository().Modify(stores.And(stores.Eq(stores.IDField, t.UserID), stores.Eq("emailVerified", false)),
+		stores.Set("isActive", true),
+		stores.Set("emailVerified", true))
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return getInvalidUserTokenError()
+	}
+
+	return err
+}
+
+func (s *MyRegistrationService) usersRepository() stores.Repository {
+	return s.session.GetRepository("users")
+}
+
+func (s *MyRegistrationService) userTokensRepository() stores.Repository {
+	return s.session.GetRepository("userTokens")
+}
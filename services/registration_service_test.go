@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingMailer struct{}
+
+func (m failingMailer) SendMail(to string, subject string, body string) error {
+	return errors.New("wadus")
+}
+
+func TestRegistrationService(t *testing.T) {
+	currentTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	defer setNow(currentTime)()
+
+	config := DefaultRegistrationConfig()
+	config.Enabled = true
+	config.VerificationURL = "https://lists.example.com/verify?lang=en"
+
+	dto := models.RegistrationDto{UserName: "wadus", Email: "wadus@example.com", NewPassword: "pass", ConfirmNewPassword: "pass"}
+
+	tokenRegexp := regexp.MustCompile(`https://lists\.example\.com/verify\?lang=en&token=([A-Za-z0-9_-]+)`)
+
+	newService := func(session stores.MongoSession, mailer Mailer, config RegistrationConfig) *MyRegistrationService {
+		return NewMyRegistrationService(session, NewMyUsersService(session, newTestPasswordHasher(), PasswordPolicy{}), mailer, config)
+	}
+
+	getUser := func(session stores.MongoSession) models.User {
+		u := models.User{}
+		assert.Nil(t, session.GetRepository("users").GetOne(&u, stores.Query{Filter: stores.Eq("userName", "wadus")}))
+		return u
+	}
+
+	t.Run("Register() should return a forbiddenError when the registration is disabled", func(t *testing.T) {
+		service := newService(stores.NewMyMemorySession(), NewMyLogMailer(&bytes.Buffer{}), DefaultRegistrationConfig())
+
+		assert.IsType(t, &appErrors.ForbiddenError{}, service.Register(dto))
+	})
+
+	t.Run("Register() should return a badRequestError when the email address isn't valid", func(t *testing.T) {
+		service := newService(stores.NewMyMemorySession(), NewMyLogMailer(&bytes.Buffer{}), config)
+
+		invalid := dto
+		invalid.Email = "Wadus <wadus@example.com>"
+
+		err := service.Register(invalid)
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, "Invalid email address", err.Error())
+	})
+
+	t.Run("Register() should add an inactive user and VerifyEmail() should activate it once", func(t *testing.T) {
+		session := stores.NewMyMemorySession()
+		var mails bytes.Buffer
+		service := newService(session, NewMyLogMailer(&mails), config)
+
+		assert.Nil(t, service.Register(dto))
+
+		u := getUser(session)
+		assert.False(t, u.IsActive)
+		assert.False(t, u.EmailVerified)
+		assert.Equal(t, models.UserRoleUser, u.Role)
+
+		assert.Contains(t, mails.String(), "To: wadus@example.com\r\n")
+		match := tokenRegexp.FindStringSubmatch(mails.String())
+		assert.Len(t, match, 2)
+
+		err := service.Register(models.RegistrationDto{UserName: "other", Email: dto.Email, NewPassword: "pass", ConfirmNewPassword: "pass"})
+		assert.IsType(t, &appErrors.BadRequestError{}, err, "the email address can't be used twice")
+
+		assert.Nil(t, service.VerifyEmail(match[1]))
+
+		u = getUser(session)
+		assert.True(t, u.IsActive)
+		assert.True(t, u.EmailVerified)
+
+		assert.IsType(t, &appErrors.BadRequestError{}, service.VerifyEmail(match[1]), "the token can only be used once")
+	})
+
+	t.Run("VerifyEmail() shouldn't activate a user disabled by an admin", func(t *testing.T) {
+		session := stores.NewMyMemorySession()
+		var mails bytes.Buffer
+		service := newService(session, NewMyLogMailer(&mails), config)
+
+		assert.Nil(t, service.Register(dto))
+		match := tokenRegexp.FindStringSubmatch(mails.String())
+
+		isActive := false
+		err := service.usersSrv.UpdateUser(getUser(session).ID, "admin", models.UserPatchDto{IsActive: &isActive}, &models.GetUsersResultDto{})
+		assert.Nil(t, err)
+
+		assert.IsType(t, &appErrors.BadRequestError{}, service.VerifyEmail(match[1]))
+
+		u := getUser(session)
+		assert.False(t, u.IsActive)
+		assert.False(t, u.EmailVerified)
+	})
+
+	t.Run("VerifyEmail() should return a badRequestError when the token has expired", func(t *testing.T) {
+		session := stores.NewMyMemorySession()
+		var mails bytes.Buffer
+		service := newService(session, NewMyLogMailer(&mails), config)
+
+		assert.Nil(t, service.Register(dto))
+		match := tokenRegexp.FindStringSubmatch(mails.String())
+
+		defer setNow(currentTime.Add(config.VerificationTokenDuration))()
+
+		err := service.VerifyEmail(match[1])
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, "Invalid or expired token", err.Error())
+		assert.False(t, getUser(session).IsActive)
+	})
+
+	t.Run("Register() should remove the user when the mail can't be sent", func(t *testing.T) {
+		session := stores.NewMyMemorySession()
+		service := newService(session, failingMailer{}, config)
+
+		assert.IsType(t, &appErrors.UnexpectedError{}, service.Register(dto))
+
+		count, err := session.GetRepository("users").Count(stores.Eq("userName", "wadus"))
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
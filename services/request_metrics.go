@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+)
+
+// RequestsCounterName is the name of the counter with the number of requests
+const RequestsCounterName = "requests"
+
+// RequestMetrics is the interface the request metrics must implement. Counting a request
+// can't fail or block, so it can be done in every request.
+type RequestMetrics interface {
+	CountRequest()
+}
+
+// MyRequestMetrics counts the requests in memory and adds them to the requests counter in
+// batches, so the requests don't wait for the database and its errors don't affect them
+type MyRequestMetrics struct {
+	countersSrv CountersService
+	mu          sync.Mutex
+	pending     int
+}
+
+// NewMyRequestMetrics returns new request metrics which save the requests with the
+// counters service
+func NewMyRequestMetrics(countersSrv CountersService) *MyRequestMetrics {
+	return &MyRequestMetrics{countersSrv: countersSrv}
+}
+
+// CountRequest counts a request, which is saved in the next flush
+func (m *MyRequestMetrics) CountRequest() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending++
+}
+
+// Run flushes the pending requests every interval until the done channel is closed, when
+// they are flushed for the last time
+func (m *MyRequestMetrics) Run(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Flush()
+		case <-done:
+			m.Flush()
+			return
+		}
+	}
+}
+
+// Flush adds the pending requests to the requests counter. When it fails they are kept
+// to be added in the next flush.
+func (m *MyRequestMetrics) Flush() {
+	m.mu.Lock()
+	n := m.pending
+	m.pending = 0
+	m.mu.Unlock()
+
+	if n == 0 {
+		return
+	}
+
+	if err := m.incrementCounter(n); err != nil {
+		log.Printf("Error adding %v requests to the requests counter. Error: %v", n, err)
+
+		m.mu.Lock()
+		m.pending += n
+		m.mu.Unlock()
+	}
+}
+
+// incrementCounter adds the requests to the counter, converting a panic of the store into
+// an error so it doesn't stop the server
+func (m *MyRequestMetrics) incrementCounter(n int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	err = m.countersSrv.IncrementCounterBy(RequestsCounterName, n)
+	if _, isNotFound := err.(*appErrors.NotFoundError); !isNotFound {
+		return err
+	}
+
+	// the new counters start with one
+	if err := m.countersSrv.AddCounter(RequestsCounterName); err != nil {
+		return err
+	}
+
+	return m.countersSrv.IncrementCounterBy(RequestsCounterName, n-1)
+}
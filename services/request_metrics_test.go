@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AngelVlc/lists-backend/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingCountersService is a counters service whose counters can't be incremented
+type failingCountersService struct {
+	CountersService
+	panics bool
+}
+
+func (s failingCountersService) IncrementCounterBy(name string, value int) error {
+	if s.panics {
+		panic("wadus")
+	}
+
+	return errors.New("wadus")
+}
+
+func TestRequestMetrics(t *testing.T) {
+	getRequests := func(countersSrv CountersService) int {
+		v, err := countersSrv.GetCounterValue(RequestsCounterName)
+		assert.Nil(t, err)
+		return v
+	}
+
+	t.Run("Flush() should add the pending requests to the counter, which is added when it doesn't exist", func(t *testing.T) {
+		countersSrv := NewMyCountersService(stores.NewMyMemorySession())
+		metrics := NewMyRequestMetrics(countersSrv)
+
+		metrics.CountRequest()
+		metrics.CountRequest()
+		metrics.CountRequest()
+		metrics.Flush()
+
+		assert.Equal(t, 3, getRequests(countersSrv))
+
+		metrics.CountRequest()
+		metrics.Flush()
+		metrics.Flush()
+
+		assert.Equal(t, 4, getRequests(countersSrv))
+	})
+
+	t.Run("Flush() should keep the pending requests when the counter can't be incremented", func(t *testing.T) {
+		countersSrv := NewMyCountersService(stores.NewMyMemorySession())
+		metrics := NewMyRequestMetrics(failingCountersService{CountersService: countersSrv})
+
+		metrics.CountRequest()
+		metrics.CountRequest()
+		metrics.Flush()
+
+		metrics.countersSrv = failingCountersService{CountersService: countersSrv, panics: true}
+		metrics.CountRequest()
+		assert.NotPanics(t, metrics.Flush)
+
+		metrics.countersSrv = countersSrv
+		metrics.Flush()
+
+		assert.Equal(t, 3, getRequests(countersSrv))
+	})
+
+	t.Run("Run() should flush the pending requests when it is done", func(t *testing.T) {
+		countersSrv := NewMyCountersService(stores.NewMyMemorySession())
+		metrics := NewMyRequestMetrics(countersSrv)
+		done := make(chan struct{})
+		finished := make(chan struct{})
+
+		go func() {
+			metrics.Run(time.Hour, done)
+			close(finished)
+		}()
+
+		metrics.CountRequest()
+		close(done)
+		<-finished
+
+		assert.Equal(t, 1, getRequests(countersSrv))
+	})
+}
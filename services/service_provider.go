@@ -9,24 +9,37 @@ type ServiceProvider interface {
 	GetListsService() ListsService
 	GetAuthService() AuthService
 	GetCountersService() CountersService
+	GetLoginAttemptsService() LoginAttemptsService
+	GetRegistrationService() RegistrationService
+	GetPasswordResetService() PasswordResetService
 }
 
 type MyServiceProvider struct {
-	session   stores.MongoSession
-	bcryptPrv BcryptProvider
-	jwtPrv    JwtProvider
+	session  stores.MongoSession
+	hasher   PasswordHasher
+	policy   PasswordPolicy
+	jwtPrv   JwtProvider
+	authCfg  AuthConfig
+	mailer   Mailer
+	regCfg   RegistrationConfig
+	resetCfg PasswordResetConfig
 }
 
-func NewMyServiceProvider(s stores.MongoSession, bp BcryptProvider, jwtp JwtProvider) *MyServiceProvider {
+func NewMyServiceProvider(s stores.MongoSession, ph PasswordHasher, pp PasswordPolicy, jwtp JwtProvider, ac AuthConfig, m Mailer, rc RegistrationConfig, prc PasswordResetConfig) *MyServiceProvider {
 	return &MyServiceProvider{
-		session:   s,
-		bcryptPrv: bp,
-		jwtPrv:    jwtp,
+		session:  s,
+		hasher:   ph,
+		policy:   pp,
+		jwtPrv:   jwtp,
+		authCfg:  ac,
+		mailer:   m,
+		regCfg:   rc,
+		resetCfg: prc,
 	}
 }
 
 func (sp *MyServiceProvider) GetUsersService() UsersService {
-	return NewMyUsersService(sp.session, sp.bcryptPrv)
+	return sp.getUsersService()
 }
 
 func (sp *MyServiceProvider) GetListsService() ListsService {
@@ -34,9 +47,25 @@ func (sp *MyServiceProvider) GetListsService() ListsService {
 }
 
 func (sp *MyServiceProvider) GetAuthService() AuthService {
-	return NewMyAuthService(sp.jwtPrv)
+	return NewMyAuthService(sp.jwtPrv, sp.session, sp.authCfg)
 }
 
 func (sp *MyServiceProvider) GetCountersService() CountersService {
 	return NewMyCountersService(sp.session)
 }
+
+func (sp *MyServiceProvider) GetLoginAttemptsService() LoginAttemptsService {
+	return NewMyLoginAttemptsService(sp.session)
+}
+
+func (sp *MyServiceProvider) GetRegistrationService() RegistrationService {
+	return NewMyRegistrationService(sp.session, sp.getUsersService(), sp.mailer, sp.regCfg)
+}
+
+func (sp *MyServiceProvider) GetPasswordResetService() PasswordResetService {
+	return NewMyPasswordResetService(sp.session, sp.getUsersService(), sp.mailer, sp.resetCfg)
+}
+
+func (sp *MyServiceProvider) getUsersService() *MyUsersService {
+	return NewMyUsersService(sp.session, sp.hasher, sp.policy)
+}
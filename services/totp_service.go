@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto/rand"
+	"strings"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+)
+
+// defaultTotpIssuer is the name shown by the authenticator apps when there isn't a token issuer
+const defaultTotpIssuer = "lists"
+
+const (
+	recoveryCodesCount = 10
+	// recoveryCodeBytes is the number of random bytes of a recovery code, which is shown
+	// as two groups of 8 characters
+	recoveryCodeBytes = 10
+)
+
+// EnrollTotp starts the two-factor authentication enrollment of the user with a new secret.
+// The secret isn't required to log in until the enrollment is confirmed with ConfirmTotp.
+func (s *MyAuthService) EnrollTotp(userID string, r *models.TotpEnrollmentDto) error {
+	u := models.User{}
+	if err := s.getTotpUser(userID, &u); err != nil {
+		return err
+	}
+
+	if u.TotpEnabled {
+		return &appErrors.BadRequestError{Msg: "The two-factor authentication is already enabled", InternalError: nil}
+	}
+
+	secret, err := newTotpSecret()
+	if err != nil {
+		return &appErrors.UnexpectedError{Msg: "Error creating the two-factor authentication secret", InternalError: err}
+	}
+
+	err = s.usersRepository().Modify(stores.Eq(stores.IDField, userID), stores.Set("totpSecret", secret), stores.Set("totpLastStep", int64(0)))
+	if err != nil {
+		return err
+	}
+
+	*r = models.TotpEnrollmentDto{Secret: secret, URI: totpURI(s.totpIssuer(), u.UserName, secret)}
+
+	return nil
+}
+
+// ConfirmTotp enables the two-factor authentication when the code is valid for the secret
+// of the enrollment. The result contains the recovery codes, which can only be read there.
+func (s *MyAuthService) ConfirmTotp(userID string, code string, r *models.RecoveryCodesDto) error {
+	u := models.User{}
+	if err := s.getTotpUser(userID, &u); err != nil {
+		return err
+	}
+
+	if u.TotpEnabled {
+		return &appErrors.BadRequestError{Msg: "The two-factor authentication is already enabled", InternalError: nil}
+	}
+
+	if u.TotpSecret == "" {
+		return &appErrors.BadRequestError{Msg: "The two-factor authentication enrollment hasn't started", InternalError: nil}
+	}
+
+	step, ok := checkTotpCode(u.TotpSecret, code, now())
+	if !ok {
+		return &appErrors.BadRequestError{Msg: "Invalid two-factor authentication code", InternalError: nil}
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return &appErrors.UnexpectedError{Msg: "Error creating the recovery codes", InternalError: err}
+	}
+
+	filter := stores.And(stores.Eq(stores.IDField, userID), stores.Eq("totpSecret", u.TotpSecret))
+	err = s.usersRepository().Modify(filter,
+		stores.Set("totpEnabled", true),
+		stores.Set("totpLastStep", step),
+		stores.Set("recoveryCodes", strings.Join(hashes, " ")))
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return &appErrors.BadRequestError{Msg: "The two-factor authentication enrollment has changed", InternalError: nil}
+	}
+	if err != nil {
+		return err
+	}
+
+	r.RecoveryCodes = codes
+
+	return nil
+}
+
+// DisableTotp disables the two-factor authentication when the code, or a recovery code,
+// is valid
+func (s *MyAuthService) DisableTotp(userID string, code string) error {
+	u := models.User{}
+	if err := s.getTotpUser(userID, &u); err != nil {
+		return err
+	}
+
+	if !u.TotpEnabled {
+		return &appErrors.BadRequestError{Msg: "The two-factor authentication is not enabled", InternalError: nil}
+	}
+
+	valid, err := s.useTotpCode(&u, code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return &appErrors.BadRequestError{Msg: "Invalid two-factor authentication code", InternalError: nil}
+	}
+
+	return s.usersRepository().Modify(stores.Eq(stores.IDField, userID),
+		stores.Set("totpSecret", ""),
+		stores.Set("totpEnabled", false),
+		stores.Set("totpLastStep", int64(0)),
+		stores.Set("recoveryCodes", ""))
+}
+
+// CheckTotpCode returns an error unless the code, or a recovery code, is valid for the user.
+// Each code can only be used once.
+func (s *MyAuthService) CheckTotpCode(userID string, code string) error {
+	u := models.User{}
+	if err := s.getTotpUser(userID, &u); err != nil {
+		return err
+	}
+
+	if !u.TotpEnabled {
+		return getInvalidTotpCodeError()
+	}
+
+	valid, err := s.useTotpCode(&u, code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return getInvalidTotpCodeError()
+	}
+
+	return nil
+}
+
+// CreateChallengeToken returns the short lived token which is exchanged, with a two-factor
+// authentication code, for the tokens of the user
+func (s *MyAuthService) CreateChallengeToken(u *models.User) (map[string]string, error) {
+	t := s.jwtPrv.NewToken()
+
+	tc := s.jwtPrv.GetTokenClaims(t)
+	tc["userId"] = u.ID
+	s.addStandardClaims(tc, challengeTokenType, now().Add(s.config.ChallengeTokenDuration))
+
+	st, err := s.jwtPrv.SignToken(t)
+	if err != nil {
+		return nil, &appErrors.UnexpectedError{Msg: "Error creating jwt challenge token", InternalError: err}
+	}
+
+	return map[string]string{"challengeToken": st}, nil
+}
+
+// ParseChallengeToken returns the id of the user of a valid challenge token
+func (s *MyAuthService) ParseChallengeToken(challengeToken string) (string, error) {
+	token, err := s.jwtPrv.ParseToken(challengeToken)
+	if err != nil {
+		return "", &appErrors.UnauthorizedError{Msg: "Invalid challenge token", InternalError: err}
+	}
+
+	if !s.jwtPrv.IsTokenValid(token) {
+		return "", &appErrors.UnauthorizedError{Msg: "Invalid challenge token"}
+	}
+
+	claims := s.jwtPrv.GetTokenClaims(token)
+	if err := s.validateClaims(claims, challengeTokenType); err != nil {
+		return "", &appErrors.UnauthorizedError{Msg: "Invalid challenge token", InternalError: err}
+	}
+
+	return parseStringClaim(claims["userId"]), nil
+}
+
+// useTotpCode returns true when the code is valid and marks it as used. The codes of a time
+// step can't be used after a code of the same or a later step and each recovery code is
+// removed when used.
+func (s *MyAuthService) useTotpCode(u *models.User, code string) (bool, error) {
+	if step, ok := checkTotpCode(u.TotpSecret, code, now()); ok {
+		if step <= u.TotpLastStep {
+			return false, nil
+		}
+
+		filter := stores.And(stores.Eq(stores.IDField, u.ID), stores.Lt("totpLastStep", step))
+		return isModified(s.usersRepository().Modify(filter, stores.Set("totpLastStep", step)))
+	}
+
+	hash := hashToken(normalizeRecoveryCode(code))
+	remaining := []string{}
+	found := false
+	for _, h := range strings.Fields(u.RecoveryCodes) {
+		if h == hash {
+			found = true
+		} else {
+			remaining = append(remaining, h)
+		}
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	filter := stores.And(stores.Eq(stores.IDField, u.ID), stores.Eq("recoveryCodes", u.RecoveryCodes))
+	return isModified(s.usersRepository().Modify(filter, stores.Set("recoveryCodes", strings.Join(remaining, " "))))
+}
+
+func (s *MyAuthService) getTotpUser(userID string, u *models.User) error {
+	if !s.usersRepository().IsValidID(userID) {
+		return &appErrors.BadRequestError{Msg: "Invalid id", InternalError: nil}
+	}
+
+	return s.usersRepository().GetOne(u, stores.Query{Filter: stores.Eq(stores.IDField, userID)})
+}
+
+func (s *MyAuthService) totpIssuer() string {
+	if s.config.Issuer != "" {
+		return s.config.Issuer
+	}
+
+	return defaultTotpIssuer
+}
+
+// newRecoveryCodes returns new recovery codes and their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := []string{}
+	hashes := []string{}
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		c := strings.ToLower(totpEncoding.EncodeToString(b))
+		codes = append(codes, c[:len(c)/2]+"-"+c[len(c)/2:])
+		hashes = append(hashes, hashToken(c))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode removes the separator and the case of a recovery code, so it can
+// be typed in any way
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+}
+
+// isModified returns false when the document to modify wasn't found, because another
+// request has used the same code at the same time
+func isModified(err error) (bool, error) {
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func getInvalidTotpCodeError() error {
+	return &appErrors.UnauthorizedError{Msg: "Invalid two-factor authentication code"}
+}
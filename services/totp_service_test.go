@@ -0,0 +1,128 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthServiceTotp(t *testing.T) {
+	session := stores.NewMyMemorySession()
+	service := NewMyAuthService(NewMyJwtProvider("theSecret"), session, DefaultAuthConfig())
+
+	currentTime := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	defer setNow(currentTime)()
+
+	u := models.User{UserName: "wadus", Role: models.UserRoleUser, IsActive: true}
+	_, err := session.GetRepository("users").Add(&u)
+	assert.Nil(t, err)
+
+	codeAt := func(secret string, t time.Time) string {
+		key, _ := totpEncoding.DecodeString(secret)
+		return totpCode(key, totpStep(t), totpDigits)
+	}
+
+	enrollment := models.TotpEnrollmentDto{}
+	recoveryCodes := models.RecoveryCodesDto{}
+
+	t.Run("ConfirmTotp() should return a badRequestError when the enrollment hasn't started", func(t *testing.T) {
+		err := service.ConfirmTotp(u.ID, "123456", &recoveryCodes)
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+	})
+
+	t.Run("EnrollTotp() should return a new secret and its uri", func(t *testing.T) {
+		err := service.EnrollTotp(u.ID, &enrollment)
+
+		assert.Nil(t, err)
+		assert.Len(t, enrollment.Secret, 32)
+		assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/lists:wadus?"))
+		assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+	})
+
+	t.Run("CheckTotpCode() should return an unauthorizedError until the enrollment is confirmed", func(t *testing.T) {
+		err := service.CheckTotpCode(u.ID, codeAt(enrollment.Secret, currentTime))
+
+		assert.IsType(t, &appErrors.UnauthorizedError{}, err)
+	})
+
+	t.Run("ConfirmTotp() should return a badRequestError when the code isn't valid", func(t *testing.T) {
+		err := service.ConfirmTotp(u.ID, "wadus", &recoveryCodes)
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+	})
+
+	t.Run("ConfirmTotp() should enable the two-factor authentication and return the recovery codes", func(t *testing.T) {
+		err := service.ConfirmTotp(u.ID, codeAt(enrollment.Secret, currentTime), &recoveryCodes)
+
+		assert.Nil(t, err)
+		assert.Len(t, recoveryCodes.RecoveryCodes, recoveryCodesCount)
+
+		got := models.User{}
+		assert.Nil(t, session.GetRepository("users").GetOne(&got, stores.Query{Filter: stores.Eq(stores.IDField, u.ID)}))
+		assert.True(t, got.TotpEnabled)
+		assert.NotContains(t, got.RecoveryCodes, normalizeRecoveryCode(recoveryCodes.RecoveryCodes[0]), "the recovery codes shouldn't be stored")
+	})
+
+	t.Run("CheckTotpCode() shouldn't accept the same code twice", func(t *testing.T) {
+		err := service.CheckTotpCode(u.ID, codeAt(enrollment.Secret, currentTime))
+
+		assert.IsType(t, &appErrors.UnauthorizedError{}, err)
+	})
+
+	t.Run("CheckTotpCode() should accept the code of a later time step", func(t *testing.T) {
+		later := currentTime.Add(totpPeriod * time.Second)
+		defer setNow(later)()
+
+		assert.Nil(t, service.CheckTotpCode(u.ID, codeAt(enrollment.Secret, later)))
+	})
+
+	t.Run("CheckTotpCode() should accept each recovery code once", func(t *testing.T) {
+		code := strings.ToUpper(recoveryCodes.RecoveryCodes[0])
+
+		assert.Nil(t, service.CheckTotpCode(u.ID, code))
+		assert.IsType(t, &appErrors.UnauthorizedError{}, service.CheckTotpCode(u.ID, code))
+	})
+
+	t.Run("the challenge tokens contain the user id and can't be used as jwt tokens", func(t *testing.T) {
+		challenge, err := service.CreateChallengeToken(&u)
+		assert.Nil(t, err)
+
+		userID, err := service.ParseChallengeToken(challenge["challengeToken"])
+		assert.Nil(t, err)
+		assert.Equal(t, u.ID, userID)
+
+		_, err = service.ParseToken(challenge["challengeToken"])
+		assert.IsType(t, &appErrors.UnauthorizedError{}, err)
+
+		tokens, _ := service.CreateTokens(&u)
+		_, err = service.ParseChallengeToken(tokens["token"])
+		assert.IsType(t, &appErrors.UnauthorizedError{}, err, "a jwt token can't be used as a challenge token")
+	})
+
+	t.Run("ParseChallengeToken() should return an unauthorizedError when the token has expired", func(t *testing.T) {
+		challenge, _ := service.CreateChallengeToken(&u)
+
+		defer setNow(currentTime.Add(DefaultAuthConfig().ChallengeTokenDuration + time.Minute))()
+
+		_, err := service.ParseChallengeToken(challenge["challengeToken"])
+		assert.IsType(t, &appErrors.UnauthorizedError{}, err)
+	})
+
+	t.Run("DisableTotp() should disable the two-factor authentication with a recovery code", func(t *testing.T) {
+		assert.IsType(t, &appErrors.BadRequestError{}, service.DisableTotp(u.ID, "wadus"))
+
+		assert.Nil(t, service.DisableTotp(u.ID, recoveryCodes.RecoveryCodes[1]))
+
+		got := models.User{}
+		assert.Nil(t, session.GetRepository("users").GetOne(&got, stores.Query{Filter: stores.Eq(stores.IDField, u.ID)}))
+		assert.False(t, got.TotpEnabled)
+		assert.Empty(t, got.TotpSecret)
+		assert.Empty(t, got.RecoveryCodes)
+	})
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTotpCode(t *testing.T) {
+	// the SHA1 test vectors of the RFC 6238
+	secret := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for unix, want := range vectors {
+		assert.Equal(t, want, totpCode(secret, totpStep(time.Unix(unix, 0)), 8), "time %v", unix)
+	}
+}
+
+func TestCheckTotpCode(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	at := time.Unix(59, 0)
+
+	step, ok := checkTotpCode(secret, "287082", at)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), step)
+
+	_, ok = checkTotpCode(secret, "287082", at.Add(totpPeriod*time.Second))
+	assert.True(t, ok, "the code of the previous step should be accepted")
+
+	_, ok = checkTotpCode(secret, "287082", at.Add(2*totpPeriod*time.Second))
+	assert.False(t, ok, "the older codes should be rejected")
+
+	_, ok = checkTotpCode(secret, "000000", at)
+	assert.False(t, ok)
+
+	_, ok = checkTotpCode("not base32!", "287082", at)
+	assert.False(t, ok)
+}
+
+func TestTotpURI(t *testing.T) {
+	uri := totpURI("lists", "the user", "SECRET")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/lists:the%20user?"))
+	assert.Contains(t, uri, "secret=SECRET")
+	assert.Contains(t, uri, "issuer=lists")
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"net/url"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
+)
+
+// userTokenBytes is the number of random bytes of a user token
+const userTokenBytes = 32
+
+// addUserToken stores a new token of the user in the repository and returns it
+func addUserToken(repo stores.Repository, userID string, kind string, duration time.Duration) (string, error) {
+	token, err := newRandomToken(userTokenBytes)
+	if err != nil {
+		return "", &appErrors.UnexpectedError{Msg: "Error creating the token", InternalError: err}
+	}
+
+	t := models.UserToken{
+		UserID:    userID,
+		Kind:      kind,
+		TokenHash: hashToken(token),
+		ExpiresAt: now().Add(duration),
+	}
+	if _, err := repo.Add(&t); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// findUserToken returns a valid token of the kind without using it, so it can be checked
+// before the change it allows is made
+func findUserToken(repo stores.Repository, token string, kind string) (*models.UserToken, error) {
+	t := models.UserToken{}
+	err := repo.GetOne(&t, stores.Query{Filter: stores.And(stores.Eq("tokenHash", hashToken(token)), stores.Eq("kind", kind))})
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return nil, getInvalidUserTokenError()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.IsExpired(now()) {
+		return nil, getInvalidUserTokenError()
+	}
+
+	return &t, nil
+}
+
+// useUserToken removes a token of the kind and returns it when it is valid. The expired
+// tokens are removed too.
+func useUserToken(repo stores.Repository, token string, kind string) (*models.UserToken, error) {
+	t := models.UserToken{}
+	err := repo.GetOne(&t, stores.Query{Filter: stores.And(stores.Eq("tokenHash", hashToken(token)), stores.Eq("kind", kind))})
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return nil, getInvalidUserTokenError()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// when the token is used twice at the same time only one of them removes it
+	err = repo.Remove(stores.Eq(stores.IDField, t.ID))
+	if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+		return nil, getInvalidUserTokenError()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.IsExpired(now()) {
+		return nil, getInvalidUserTokenError()
+	}
+
+	return &t, nil
+}
+
+// removeUserTokens removes all the tokens of the kind of the user
+func removeUserTokens(repo stores.Repository, userID string, kind string) error {
+	return removeAll(repo, stores.And(stores.Eq("userId", userID), stores.Eq("kind", kind)))
+}
+
+// removeAll removes all the documents which match the filter, one by one
+func removeAll(repo stores.Repository, filter stores.Filter) error {
+	for {
+		err := repo.Remove(filter)
+		if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// linkWithToken returns the link sent in a mail, which is the page in baseURL with the token
+// as the token query parameter
+func linkWithToken(baseURL string, token string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		u = &url.URL{}
+	}
+
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+func getInvalidUserTokenError() error {
+	return &appErrors.BadRequestError{Msg: "Invalid or expired token", InternalError: nil}
+}
@@ -1,12 +1,12 @@
 package services
 
 import (
+	"encoding/base64"
 	"fmt"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
 	"github.com/AngelVlc/lists-backend/models"
 	"github.com/AngelVlc/lists-backend/stores"
-	"gopkg.in/mgo.v2/bson"
 )
 
 // UsersService is the interface a users service must implement
@@ -15,31 +15,51 @@ type UsersService interface {
 	CheckIfUserPasswordIsOk(userName string, password string) (*models.User, error)
 	GetUserByID(id string, u *models.User) error
 	GetUserByUserName(userName string, u *models.User) error
+	GetUsers(opts models.GetUsersOptions, r *models.GetUsersPageDto) error
+	UpdateUser(id string, currentUserID string, patch models.UserPatchDto, r *models.GetUsersResultDto) error
+	RemoveUser(id string, currentUserID string, transferTo string) error
+	ChangePassword(id string, dto models.ChangePasswordDto) error
+	SetPassword(id string, newPassword string, confirmNewPassword string) error
+	RemoveAccount(id string) error
 }
 
+const (
+	defaultUsersPageSize = 20
+	maxUsersPageSize     = 100
+)
+
 // MyUsersService is the service for the users entity
 type MyUsersService struct {
-	session   stores.MongoSession
-	bcryptPrv BcryptProvider
+	session stores.MongoSession
+	hasher  PasswordHasher
+	policy  PasswordPolicy
 }
 
-var bcryptCost int = 3
-
-// NewMyUsersService returns a new users service
-func NewMyUsersService(session stores.MongoSession, bcryptPrv BcryptProvider) *MyUsersService {
+// NewMyUsersService returns a new users service which checks the new passwords with the policy
+func NewMyUsersService(session stores.MongoSession, hasher PasswordHasher, policy PasswordPolicy) *MyUsersService {
 	return &MyUsersService{
-		session:   session,
-		bcryptPrv: bcryptPrv,
+		session: session,
+		hasher:  hasher,
+		policy:  policy,
 	}
 }
 
 // AddUser  adds a user
 func (s *MyUsersService) AddUser(dto *models.UserDto) (string, error) {
-	if dto.NewPassword != dto.ConfirmNewPassword {
-		return "", &appErrors.BadRequestError{Msg: "Passwords don't match", InternalError: nil}
+	return s.addUser(dto.ToUser(), dto.NewPassword, dto.ConfirmNewPassword)
+}
+
+// addUser adds the user with the password when its user name isn't used yet
+func (s *MyUsersService) addUser(user models.User, password string, confirmPassword string) (string, error) {
+	if err := checkPasswordsMatch(password, confirmPassword); err != nil {
+		return "", err
+	}
+
+	if err := s.policy.Check(password, user.UserName); err != nil {
+		return "", err
 	}
 
-	userExists, err := s.existsUser(dto.UserName)
+	userExists, err := s.existsUser(user.UserName)
 	if err != nil {
 		return "", err
 	}
@@ -48,9 +68,11 @@ func (s *MyUsersService) AddUser(dto *models.UserDto) (string, error) {
 		return "", &appErrors.BadRequestError{Msg: "A user with the same user name already exists", InternalError: nil}
 	}
 
-	user := dto.ToUser()
+	if !models.IsUserRole(user.Role) {
+		return "", getInvalidUserRoleError(user.Role)
+	}
 
-	hasshedPass, err := s.bcryptPrv.GenerateFromPassword([]byte(dto.NewPassword), bcryptCost)
+	hasshedPass, err := s.hasher.GenerateFromPassword([]byte(password))
 	if err != nil {
 		return "", &appErrors.UnexpectedError{Msg: "Error encrypting password", InternalError: err}
 	}
@@ -60,7 +82,8 @@ func (s *MyUsersService) AddUser(dto *models.UserDto) (string, error) {
 	return s.usersRepository().Add(&user)
 }
 
-// CheckIfUserPasswordIsOk returns nil if the password is correct or an error if it isn't
+// CheckIfUserPasswordIsOk returns the user if the password is correct and the user is
+// active or an error if it isn't. The hash is upgraded when it is outdated.
 func (s *MyUsersService) CheckIfUserPasswordIsOk(userName string, password string) (*models.User, error) {
 	foundUser, err := s.getUserByUserName(userName)
 	if err != nil {
@@ -68,29 +91,375 @@ func (s *MyUsersService) CheckIfUserPasswordIsOk(userName string, password strin
 	}
 
 	if foundUser == nil {
-		return nil, &appErrors.BadRequestError{Msg: "The user does not exist", InternalError: nil}
+		// the password is hashed anyway, which costs the same as checking it, so the response
+		// time doesn't tell the user doesn't exist
+		s.hasher.GenerateFromPassword([]byte(password))
+		return nil, getInvalidCredentialsError()
 	}
 
-	err = s.bcryptPrv.CompareHashAndPassword([]byte(foundUser.PasswordHash), []byte(password))
+	err = s.hasher.CompareHashAndPassword([]byte(foundUser.PasswordHash), []byte(password))
 	if err != nil {
-		return nil, &appErrors.BadRequestError{Msg: "Invalid password", InternalError: nil}
+		return nil, getInvalidCredentialsError()
+	}
+
+	if !foundUser.IsActive && foundUser.Email != "" && !foundUser.EmailVerified {
+		return nil, &appErrors.BadRequestError{Msg: "The email address hasn't been verified", InternalError: nil}
+	}
+
+	if !foundUser.IsActive {
+		return nil, &appErrors.BadRequestError{Msg: "The user is disabled", InternalError: nil}
+	}
+
+	if s.hasher.NeedsRehash([]byte(foundUser.PasswordHash)) {
+		s.rehashPassword(foundUser, password)
 	}
 
 	return foundUser, nil
 }
 
+// rehashPassword replaces the outdated hash of the user. It is only done when the hash
+// hasn't been changed meanwhile and the login doesn't fail when it can't be done.
+func (s *MyUsersService) rehashPassword(u *models.User, password string) {
+	newHash, err := s.hasher.GenerateFromPassword([]byte(password))
+	if err != nil {
+		return
+	}
+
+	err = s.usersRepository().Modify(stores.And(stores.Eq(stores.IDField, u.ID), stores.Eq("passwordHash", u.PasswordHash)),
+		stores.Set("passwordHash", string(newHash)))
+	if err == nil {
+		u.PasswordHash = string(newHash)
+	}
+}
+
 // GetUserByID returns a single user from its id
 func (s *MyUsersService) GetUserByID(id string, u *models.User) error {
 	if !s.usersRepository().IsValidID(id) {
 		return s.getInvalidIDError(id)
 	}
 
-	return s.usersRepository().GetOne(u, bson.D{{"_id", id}}, nil)
+	return s.usersRepository().GetOne(u, stores.Query{Filter: stores.Eq(stores.IDField, id)})
 }
 
 // GetUserByUserName returns a single user from its id
 func (s *MyUsersService) GetUserByUserName(userName string, u *models.User) error {
-	return s.usersRepository().GetOne(u, bson.D{{"userName", userName}}, nil)
+	return s.usersRepository().GetOne(u, stores.Query{Filter: stores.Eq("userName", userName)})
+}
+
+// GetUsers returns a page of users sorted by their user name
+func (s *MyUsersService) GetUsers(opts models.GetUsersOptions, r *models.GetUsersPageDto) error {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultUsersPageSize
+	}
+	if limit > maxUsersPageSize {
+		limit = maxUsersPageSize
+	}
+
+	total, err := s.usersRepository().Count(stores.All())
+	if err != nil {
+		return err
+	}
+
+	filter := stores.All()
+	if opts.Cursor != "" {
+		last, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+		if err != nil {
+			return &appErrors.BadRequestError{Msg: "Invalid cursor", InternalError: err}
+		}
+		filter = stores.Gt("userName", string(last))
+	}
+
+	users := []models.GetUsersResultDto{}
+	query := stores.Query{
+		Filter:     filter,
+		Projection: stores.Fields("userName", "role", "isActive"),
+		Sort:       []stores.Sort{stores.Asc("userName")},
+		Limit:      limit + 1,
+	}
+	if err := s.usersRepository().Get(&users, query); err != nil {
+		return err
+	}
+
+	r.NextCursor = ""
+	if len(users) > limit {
+		users = users[:limit]
+		r.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(users[limit-1].UserName))
+	}
+
+	r.Users = users
+	r.Total = total
+
+	return nil
+}
+
+// UpdateUser changes the given fields of a user and returns the updated user. The admins
+// can't change their own role or disable themselves.
+func (s *MyUsersService) UpdateUser(id string, currentUserID string, patch models.UserPatchDto, r *models.GetUsersResultDto) error {
+	u := models.User{}
+	if err := s.GetUserByID(id, &u); err != nil {
+		return err
+	}
+
+	mods := []stores.Modification{}
+
+	renamed := patch.UserName != nil && *patch.UserName != u.UserName
+	if renamed {
+		if *patch.UserName == "" {
+			return &appErrors.BadRequestError{Msg: "The user name can't be empty", InternalError: nil}
+		}
+
+		userExists, err := s.existsUser(*patch.UserName)
+		if err != nil {
+			return err
+		}
+		if userExists {
+			return &appErrors.BadRequestError{Msg: "A user with the same user name already exists", InternalError: nil}
+		}
+
+		u.UserName = *patch.UserName
+		mods = append(mods, stores.Set("userName", u.UserName))
+	}
+
+	if patch.Role != nil && *patch.Role != u.Role {
+		if !models.IsUserRole(*patch.Role) {
+			return getInvalidUserRoleError(*patch.Role)
+		}
+
+		if id == currentUserID {
+			return &appErrors.BadRequestError{Msg: "Admins can't change their own role", InternalError: nil}
+		}
+
+		u.Role = *patch.Role
+		mods = append(mods, stores.Set("role", u.Role))
+	}
+
+	// the unverified users are already inactive, so their verification links are removed to
+	// keep them disabled
+	if patch.IsActive != nil && !*patch.IsActive && u.Email != "" && !u.EmailVerified {
+		if err := removeUserTokens(s.session.GetRepository("userTokens"), id, models.UserTokenEmailVerification); err != nil {
+			return err
+		}
+	}
+
+	if patch.IsActive != nil && *patch.IsActive != u.IsActive {
+		if id == currentUserID {
+			return &appErrors.BadRequestError{Msg: "Admins can't disable themselves", InternalError: nil}
+		}
+
+		u.IsActive = *patch.IsActive
+		mods = append(mods, stores.Set("isActive", u.IsActive))
+	}
+
+	if patch.NewPassword != nil {
+		if patch.ConfirmNewPassword == nil || *patch.NewPassword != *patch.ConfirmNewPassword {
+			return &appErrors.BadRequestError{Msg: "Passwords don't match", InternalError: nil}
+		}
+
+		if err := s.policy.Check(*patch.NewPassword, u.UserName); err != nil {
+			return err
+		}
+
+		hasshedPass, err := s.hasher.GenerateFromPassword([]byte(*patch.NewPassword))
+		if err != nil {
+			return &appErrors.UnexpectedError{Msg: "Error encrypting password", InternalError: err}
+		}
+
+		mods = append(mods, stores.Set("passwordHash", string(hasshedPass)))
+	}
+
+	if len(mods) > 0 {
+		if err := s.usersRepository().Modify(stores.Eq(stores.IDField, id), mods...); err != nil {
+			return err
+		}
+	}
+
+	if renamed {
+		if err := s.renameCollaborator(id, u.UserName); err != nil {
+			return err
+		}
+	}
+
+	*r = u.ToResultDto()
+
+	return nil
+}
+
+// RemoveUser removes a user and the lists it owns. When transferTo is given its lists
+// are given to that user instead. The user is also removed from the lists shared with it.
+func (s *MyUsersService) RemoveUser(id string, currentUserID string, transferTo string) error {
+	if id == currentUserID {
+		return &appErrors.BadRequestError{Msg: "Users can't remove themselves", InternalError: nil}
+	}
+
+	return s.removeUser(id, transferTo)
+}
+
+// ChangePassword changes the password of a user when the old one is correct
+func (s *MyUsersService) ChangePassword(id string, dto models.ChangePasswordDto) error {
+	u := models.User{}
+	if err := s.GetUserByID(id, &u); err != nil {
+		return err
+	}
+
+	err := s.hasher.CompareHashAndPassword([]byte(u.PasswordHash), []byte(dto.OldPassword))
+	if err != nil {
+		return &appErrors.BadRequestError{Msg: "Invalid password", InternalError: nil}
+	}
+
+	return s.setPassword(id, u.UserName, dto.NewPassword, dto.ConfirmNewPassword)
+}
+
+// SetPassword sets a new password to the user without checking the old one, like when it
+// is reset. The user doesn't have to change it anymore after it.
+func (s *MyUsersService) SetPassword(id string, newPassword string, confirmNewPassword string) error {
+	u := models.User{}
+	if err := s.GetUserByID(id, &u); err != nil {
+		return err
+	}
+
+	return s.setPassword(id, u.UserName, newPassword, confirmNewPassword)
+}
+
+func (s *MyUsersService) setPassword(id string, userName string, newPassword string, confirmNewPassword string) error {
+	if err := checkPasswordsMatch(newPassword, confirmNewPassword); err != nil {
+		return err
+	}
+
+	if err := s.policy.Check(newPassword, userName); err != nil {
+		return err
+	}
+
+	hasshedPass, err := s.hasher.GenerateFromPassword([]byte(newPassword))
+	if err != nil {
+		return &appErrors.UnexpectedError{Msg: "Error encrypting password", InternalError: err}
+	}
+
+	return s.usersRepository().Modify(stores.Eq(stores.IDField, id),
+		stores.Set("passwordHash", string(hasshedPass)),
+		stores.Set("mustChangePassword", false))
+}
+
+// RemoveAccount removes the user and the lists it owns
+func (s *MyUsersService) RemoveAccount(id string) error {
+	return s.removeUser(id, "")
+}
+
+func (s *MyUsersService) removeUser(id string, transferTo string) error {
+	u := models.User{}
+	if err := s.GetUserByID(id, &u); err != nil {
+		return err
+	}
+
+	if transferTo != "" {
+		if transferTo == id {
+			return &appErrors.BadRequestError{Msg: "The lists can't be transferred to the removed user", InternalError: nil}
+		}
+
+		err := s.GetUserByID(transferTo, &models.User{})
+		if _, isNotFound := err.(*appErrors.NotFoundError); isNotFound {
+			return &appErrors.BadRequestError{Msg: fmt.Sprintf("The user %q does not exist", transferTo), InternalError: nil}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// the lists and the credentials are changed first, so a failed removal can be repeated
+	if err := s.removeUserFromLists(id, transferTo); err != nil {
+		return err
+	}
+
+	if err := s.removeUserCredentials(u); err != nil {
+		return err
+	}
+
+	return s.usersRepository().Remove(stores.Eq(stores.IDField, id))
+}
+
+// removeUserCredentials removes the refresh tokens, the api keys, the tokens of the mails
+// and the failed logins of the user, so they don't outlive it
+func (s *MyUsersService) removeUserCredentials(u models.User) error {
+	for _, name := range []string{"refreshTokens", "apiKeys", "userTokens"} {
+		if err := removeAll(s.session.GetRepository(name), stores.Eq("userId", u.ID)); err != nil {
+			return err
+		}
+	}
+
+	return ignoreNotFound(s.session.GetRepository("loginAttempts").Remove(stores.Eq("key", userLoginThrottle.prefix+u.UserName)))
+}
+
+// removeUserFromLists removes or transfers the lists owned by the user and removes it from
+// the collaborators of the other lists
+func (s *MyUsersService) removeUserFromLists(id string, transferTo string) error {
+	owned, err := s.getListIDs(stores.Eq("userId", id))
+	if err != nil {
+		return err
+	}
+
+	for _, listID := range owned {
+		if transferTo == "" {
+			err = s.listsRepository().Remove(stores.Eq(stores.IDField, listID))
+		} else {
+			err = s.listsRepository().Modify(stores.Eq(stores.IDField, listID),
+				stores.Set("userId", transferTo),
+				stores.Pull("collaborators", stores.Eq("userId", transferTo)),
+				stores.Inc("version", 1))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	shared, err := s.getListIDs(stores.ElemMatch("collaborators", stores.Eq("userId", id)))
+	if err != nil {
+		return err
+	}
+
+	for _, listID := range shared {
+		err := s.listsRepository().Modify(stores.Eq(stores.IDField, listID), stores.Pull("collaborators", stores.Eq("userId", id)), stores.Inc("version", 1))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renameCollaborator changes the user name of the user in the lists shared with it
+func (s *MyUsersService) renameCollaborator(id string, userName string) error {
+	shared, err := s.getListIDs(stores.ElemMatch("collaborators", stores.Eq("userId", id)))
+	if err != nil {
+		return err
+	}
+
+	for _, listID := range shared {
+		err := s.listsRepository().Modify(stores.Eq(stores.IDField, listID),
+			stores.SetElement("collaborators", stores.Eq("userId", id), map[string]interface{}{"userName": userName}))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *MyUsersService) getListIDs(filter stores.Filter) ([]string, error) {
+	lists := []models.List{}
+	if err := s.listsRepository().Get(&lists, stores.Query{Filter: filter, Projection: stores.Fields(stores.IDField)}); err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, l := range lists {
+		ids = append(ids, l.ID)
+	}
+
+	return ids, nil
+}
+
+func (s *MyUsersService) listsRepository() stores.Repository {
+	return s.session.GetRepository("lists")
 }
 
 func (s *MyUsersService) usersRepository() stores.Repository {
@@ -99,7 +468,7 @@ func (s *MyUsersService) usersRepository() stores.Repository {
 
 func (s *MyUsersService) existsUser(userName string) (bool, error) {
 	existingUsers := []models.GetUsersResultDto{}
-	err := s.usersRepository().Get(&existingUsers, bson.M{"userName": userName}, bson.M{"_id": 1})
+	err := s.usersRepository().Get(&existingUsers, stores.Query{Filter: stores.Eq("userName", userName), Projection: stores.Fields(stores.IDField)})
 	if err != nil {
 		return false, &appErrors.UnexpectedError{Msg: "Error checking if user name exists", InternalError: err}
 	}
@@ -109,7 +478,7 @@ func (s *MyUsersService) existsUser(userName string) (bool, error) {
 
 func (s *MyUsersService) getUserByUserName(userName string) (*models.User, error) {
 	foundUsers := []models.User{}
-	err := s.usersRepository().Get(&foundUsers, bson.M{"userName": userName}, nil)
+	err := s.usersRepository().Get(&foundUsers, stores.Query{Filter: stores.Eq("userName", userName)})
 	if err != nil {
 		return nil, &appErrors.UnexpectedError{Msg: "Error checking if user name exists", InternalError: err}
 	}
@@ -124,3 +493,22 @@ func (s *MyUsersService) getUserByUserName(userName string) (*models.User, error
 func (s *MyUsersService) getInvalidIDError(id string) error {
 	return &appErrors.BadRequestError{Msg: fmt.Sprintf("%q is not a valid id", id), InternalError: nil}
 }
+
+func getInvalidUserRoleError(role string) error {
+	return &appErrors.BadRequestError{Msg: fmt.Sprintf("%q is not a valid role", role), InternalError: nil}
+}
+
+// checkPasswordsMatch returns an error when the password and its confirmation are different
+func checkPasswordsMatch(password string, confirmPassword string) error {
+	if password != confirmPassword {
+		return &appErrors.BadRequestError{Msg: "Passwords don't match", InternalError: nil}
+	}
+
+	return nil
+}
+
+// getInvalidCredentialsError returns the same error when the user doesn't exist and when
+// the password is wrong, so the response doesn't tell which user names exist
+func getInvalidCredentialsError() error {
+	return &appErrors.InvalidCredentialsError{}
+}
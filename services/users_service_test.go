@@ -7,32 +7,39 @@ import (
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
 	"github.com/AngelVlc/lists-backend/models"
+	"github.com/AngelVlc/lists-backend/stores"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"gopkg.in/mgo.v2/bson"
 )
 
-type mockedBcryptProvider struct {
+type mockedPasswordHasher struct {
 	mock.Mock
 }
 
-func (m *mockedBcryptProvider) GenerateFromPassword(password []byte, cost int) ([]byte, error) {
-	args := m.Called(password, cost)
+func (m *mockedPasswordHasher) GenerateFromPassword(password []byte) ([]byte, error) {
+	args := m.Called(password)
 	return args.Get(0).([]byte), args.Error(1)
 }
 
-func (m *mockedBcryptProvider) CompareHashAndPassword(hashedPassword, password []byte) error {
+func (m *mockedPasswordHasher) CompareHashAndPassword(hashedPassword, password []byte) error {
 	args := m.Called(hashedPassword, password)
 	return args.Error(0)
 }
 
+func (m *mockedPasswordHasher) NeedsRehash(hashedPassword []byte) bool {
+	args := m.Called(hashedPassword)
+	return args.Bool(0)
+}
+
 func TestUserService(t *testing.T) {
 	mockedSession := new(mockedMongoSession)
 
-	mockedBcryptProvider := new(mockedBcryptProvider)
+	mockedHasher := new(mockedPasswordHasher)
 
-	service := NewMyUsersService(mockedSession, mockedBcryptProvider)
+	service := NewMyUsersService(mockedSession, mockedHasher, PasswordPolicy{})
 
+	mockedListsRepository := new(mockedRepository)
+	mockedCredentialsRepository := new(mockedRepository)
 	mockedRepository := new(mockedRepository)
 
 	mockedSession.On("GetRepository", "users").Return(mockedRepository)
@@ -45,10 +52,10 @@ func TestUserService(t *testing.T) {
 		}
 
 		r := []models.GetUsersResultDto{}
-		mockedRepository.On("Get", &r, bson.M{"userName": dto.UserName}, bson.M{"_id": 1}).Return(nil).Once()
+		mockedRepository.On("Get", &r, stores.Query{Filter: stores.Eq("userName", dto.UserName), Projection: stores.Fields(stores.IDField)}).Return(nil).Once()
 
 		hasshedPass := "hashedPass"
-		mockedBcryptProvider.On("GenerateFromPassword", []byte(dto.NewPassword), bcryptCost).Return([]byte(hasshedPass), nil).Once()
+		mockedHasher.On("GenerateFromPassword", []byte(dto.NewPassword)).Return([]byte(hasshedPass), nil).Once()
 		u := dto.ToUser()
 		u.PasswordHash = string(hasshedPass)
 		mockedRepository.On("Add", &u).Return("", errors.New("error"))
@@ -60,7 +67,7 @@ func TestUserService(t *testing.T) {
 
 		mockedSession.AssertExpectations(t)
 		mockedRepository.AssertExpectations(t)
-		mockedBcryptProvider.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
 	})
 
 	t.Run("AddUser() should return a BadRequestError when the passwords does not match", func(t *testing.T) {
@@ -88,9 +95,9 @@ func TestUserService(t *testing.T) {
 		}
 
 		r := []models.GetUsersResultDto{}
-		mockedRepository.On("Get", &r, bson.M{"userName": dto.UserName}, bson.M{"_id": 1}).Return(nil).Once()
+		mockedRepository.On("Get", &r, stores.Query{Filter: stores.Eq("userName", dto.UserName), Projection: stores.Fields(stores.IDField)}).Return(nil).Once()
 
-		mockedBcryptProvider.On("GenerateFromPassword", []byte(dto.NewPassword), bcryptCost).Return([]byte(""), errors.New("wadus")).Once()
+		mockedHasher.On("GenerateFromPassword", []byte(dto.NewPassword)).Return([]byte(""), errors.New("wadus")).Once()
 
 		id, err := service.AddUser(&dto)
 
@@ -103,7 +110,7 @@ func TestUserService(t *testing.T) {
 
 		mockedSession.AssertExpectations(t)
 		mockedRepository.AssertExpectations(t)
-		mockedBcryptProvider.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
 	})
 
 	t.Run("AddUser() should return a BadRequestError if a user with the same name exists", func(t *testing.T) {
@@ -117,7 +124,7 @@ func TestUserService(t *testing.T) {
 			ID: "id",
 		}
 		r := []models.GetUsersResultDto{item}
-		mockedRepository.On("Get", &[]models.GetUsersResultDto{}, bson.M{"userName": dto.UserName}, bson.M{"_id": 1}).Return(nil).Once().Run(func(args mock.Arguments) {
+		mockedRepository.On("Get", &[]models.GetUsersResultDto{}, stores.Query{Filter: stores.Eq("userName", dto.UserName), Projection: stores.Fields(stores.IDField)}).Return(nil).Once().Run(func(args mock.Arguments) {
 			arg := args.Get(0).(*[]models.GetUsersResultDto)
 			*arg = r
 		})
@@ -135,18 +142,69 @@ func TestUserService(t *testing.T) {
 		mockedRepository.AssertExpectations(t)
 	})
 
+	t.Run("CheckIfUserPasswordIsOk() should return a bad request error if the user is disabled", func(t *testing.T) {
+		user := models.User{
+			UserName:     "wadus",
+			PasswordHash: "hash",
+		}
+
+		mockedRepository.On("Get", &[]models.User{}, stores.Query{Filter: stores.Eq("userName", user.UserName)}).Return(nil).Once().Run(func(args mock.Arguments) {
+			arg := args.Get(0).(*[]models.User)
+			*arg = []models.User{user}
+		})
+
+		mockedHasher.On("CompareHashAndPassword", []byte(user.PasswordHash), []byte("pass")).Return(nil).Once()
+
+		gotUser, err := service.CheckIfUserPasswordIsOk(user.UserName, "pass")
+
+		assert.Nil(t, gotUser)
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, "The user is disabled", err.Error())
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
+	})
+
+	t.Run("CheckIfUserPasswordIsOk() should return a bad request error if the email address isn't verified", func(t *testing.T) {
+		user := models.User{
+			UserName:     "wadus",
+			PasswordHash: "hash",
+			Email:        "wadus@example.com",
+		}
+
+		mockedRepository.On("Get", &[]models.User{}, stores.Query{Filter: stores.Eq("userName", user.UserName)}).Return(nil).Once().Run(func(args mock.Arguments) {
+			arg := args.Get(0).(*[]models.User)
+			*arg = []models.User{user}
+		})
+
+		mockedHasher.On("CompareHashAndPassword", []byte(user.PasswordHash), []byte("pass")).Return(nil).Once()
+
+		gotUser, err := service.CheckIfUserPasswordIsOk(user.UserName, "pass")
+
+		assert.Nil(t, gotUser)
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, "The email address hasn't been verified", err.Error())
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
+	})
+
 	t.Run("CheckIfUserPasswordIsOk() should return the user if the password is correct", func(t *testing.T) {
 		user := models.User{
 			UserName:     "wadus",
 			PasswordHash: "hash",
+			IsActive:     true,
 		}
 
-		mockedRepository.On("Get", &[]models.User{}, bson.M{"userName": user.UserName}, nil).Return(nil).Once().Run(func(args mock.Arguments) {
+		mockedRepository.On("Get", &[]models.User{}, stores.Query{Filter: stores.Eq("userName", user.UserName)}).Return(nil).Once().Run(func(args mock.Arguments) {
 			arg := args.Get(0).(*[]models.User)
 			*arg = []models.User{user}
 		})
 
-		mockedBcryptProvider.On("CompareHashAndPassword", []byte(user.PasswordHash), []byte("pass")).Return(nil).Once()
+		mockedHasher.On("CompareHashAndPassword", []byte(user.PasswordHash), []byte("pass")).Return(nil).Once()
+		mockedHasher.On("NeedsRehash", []byte(user.PasswordHash)).Return(false).Once()
 
 		gotUser, err := service.CheckIfUserPasswordIsOk(user.UserName, "pass")
 
@@ -156,37 +214,67 @@ func TestUserService(t *testing.T) {
 
 		mockedSession.AssertExpectations(t)
 		mockedRepository.AssertExpectations(t)
-		mockedBcryptProvider.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
 	})
 
-	t.Run("CheckIfUserPasswordIsOk() should return a badRequestError if the user doesn't exist", func(t *testing.T) {
+	t.Run("CheckIfUserPasswordIsOk() should replace an outdated hash", func(t *testing.T) {
+		user := models.User{
+			ID:           "id",
+			UserName:     "wadus",
+			PasswordHash: "oldHash",
+			IsActive:     true,
+		}
+
+		mockedRepository.On("Get", &[]models.User{}, stores.Query{Filter: stores.Eq("userName", user.UserName)}).Return(nil).Once().Run(func(args mock.Arguments) {
+			arg := args.Get(0).(*[]models.User)
+			*arg = []models.User{user}
+		})
+
+		mockedHasher.On("CompareHashAndPassword", []byte("oldHash"), []byte("pass")).Return(nil).Once()
+		mockedHasher.On("NeedsRehash", []byte("oldHash")).Return(true).Once()
+		mockedHasher.On("GenerateFromPassword", []byte("pass")).Return([]byte("newHash"), nil).Once()
+		mockedRepository.On("Modify", stores.And(stores.Eq(stores.IDField, "id"), stores.Eq("passwordHash", "oldHash")), []stores.Modification{stores.Set("passwordHash", "newHash")}).Return(nil).Once()
+
+		gotUser, err := service.CheckIfUserPasswordIsOk(user.UserName, "pass")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "newHash", gotUser.PasswordHash)
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
+	})
+
+	t.Run("CheckIfUserPasswordIsOk() should return an invalidCredentialsError if the user doesn't exist", func(t *testing.T) {
 		userName := "wadus"
 
-		mockedRepository.On("Get", &[]models.User{}, bson.M{"userName": userName}, nil).Return(nil).Once().Run(func(args mock.Arguments) {
+		mockedRepository.On("Get", &[]models.User{}, stores.Query{Filter: stores.Eq("userName", userName)}).Return(nil).Once().Run(func(args mock.Arguments) {
 			arg := args.Get(0).(*[]models.User)
 			*arg = []models.User{}
 		})
 
+		mockedHasher.On("GenerateFromPassword", []byte("pass")).Return([]byte("hash"), nil).Once()
+
 		gotUser, err := service.CheckIfUserPasswordIsOk(userName, "pass")
 
 		assert.Nil(t, gotUser)
 		assert.NotNil(t, err)
 
-		badReqErr, isBadReqErr := err.(*appErrors.BadRequestError)
-		assert.Equal(t, true, isBadReqErr, "should be a bad request error")
-		assert.Equal(t, "The user does not exist", badReqErr.Error())
+		assert.IsType(t, &appErrors.InvalidCredentialsError{}, err)
+		assert.Equal(t, "Invalid user name or password", err.Error())
 
 		mockedSession.AssertExpectations(t)
 		mockedRepository.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
 	})
 
-	t.Run("CheckIfUserPasswordIsOk() should return a badRequestError if the password is not correct", func(t *testing.T) {
+	t.Run("CheckIfUserPasswordIsOk() should return an invalidCredentialsError if the password is not correct", func(t *testing.T) {
 		user := models.User{
 			UserName:     "wadus",
 			PasswordHash: "hash",
 		}
 
-		mockedRepository.On("Get", &[]models.User{}, bson.M{"userName": user.UserName}, nil).Return(nil).Once().Run(func(args mock.Arguments) {
+		mockedRepository.On("Get", &[]models.User{}, stores.Query{Filter: stores.Eq("userName", user.UserName)}).Return(nil).Once().Run(func(args mock.Arguments) {
 			user := models.User{
 				PasswordHash: "hash",
 			}
@@ -194,26 +282,25 @@ func TestUserService(t *testing.T) {
 			*arg = []models.User{user}
 		})
 
-		mockedBcryptProvider.On("CompareHashAndPassword", []byte(user.PasswordHash), []byte("pass")).Return(errors.New("wadus")).Once()
+		mockedHasher.On("CompareHashAndPassword", []byte(user.PasswordHash), []byte("pass")).Return(errors.New("wadus")).Once()
 
 		gotUser, err := service.CheckIfUserPasswordIsOk(user.UserName, "pass")
 
 		assert.Nil(t, gotUser)
 		assert.NotNil(t, err)
 
-		badReqErr, isBadReqErr := err.(*appErrors.BadRequestError)
-		assert.Equal(t, true, isBadReqErr, "should be a bad request error")
-		assert.Equal(t, "Invalid password", badReqErr.Error())
+		assert.IsType(t, &appErrors.InvalidCredentialsError{}, err)
+		assert.Equal(t, "Invalid user name or password", err.Error())
 
 		mockedSession.AssertExpectations(t)
 		mockedRepository.AssertExpectations(t)
-		mockedBcryptProvider.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
 	})
 
 	t.Run("GetUserByID() should call repository.GetOne", func(t *testing.T) {
 		u := models.User{}
 
-		mockedRepository.On("GetOne", &u, bson.D{{"_id", "id"}}, nil).Return(errors.New("error")).Once()
+		mockedRepository.On("GetOne", &u, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(errors.New("error")).Once()
 		mockedRepository.On("IsValidID", "id").Return(true).Once()
 
 		err := service.GetUserByID("id", &u)
@@ -244,7 +331,7 @@ func TestUserService(t *testing.T) {
 	t.Run("GetUserByUserName() should call repository.GetOne", func(t *testing.T) {
 		u := models.User{}
 
-		mockedRepository.On("GetOne", &u, bson.D{{"userName", "name"}}, nil).Return(errors.New("error")).Once()
+		mockedRepository.On("GetOne", &u, stores.Query{Filter: stores.Eq("userName", "name")}).Return(errors.New("error")).Once()
 
 		err := service.GetUserByUserName("name", &u)
 
@@ -253,4 +340,248 @@ func TestUserService(t *testing.T) {
 		mockedSession.AssertExpectations(t)
 		mockedRepository.AssertExpectations(t)
 	})
+
+	t.Run("GetUsers() should return a page of users and the cursor of the next one", func(t *testing.T) {
+		mockedRepository.On("Count", stores.All()).Return(3, nil).Once()
+		mockedRepository.On("Get", &[]models.GetUsersResultDto{}, stores.Query{
+			Filter:     stores.Gt("userName", "a"),
+			Projection: stores.Fields("userName", "role", "isActive"),
+			Sort:       []stores.Sort{stores.Asc("userName")},
+			Limit:      2,
+		}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]models.GetUsersResultDto) = []models.GetUsersResultDto{{ID: "2", UserName: "b"}, {ID: "3", UserName: "c"}}
+		})
+
+		r := models.GetUsersPageDto{}
+		err := service.GetUsers(models.GetUsersOptions{Limit: 1, Cursor: "YQ"}, &r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.GetUsersPageDto{Users: []models.GetUsersResultDto{{ID: "2", UserName: "b"}}, NextCursor: "Yg", Total: 3}, r)
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateUser() should rename the user in the lists shared with it", func(t *testing.T) {
+		name := "new"
+		stored := models.User{ID: "id", UserName: "old", PasswordHash: "hash"}
+
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*models.User) = stored
+		})
+		mockedRepository.On("Get", &[]models.GetUsersResultDto{}, stores.Query{Filter: stores.Eq("userName", name), Projection: stores.Fields(stores.IDField)}).Return(nil).Once()
+		mockedRepository.On("Modify", stores.Eq(stores.IDField, "id"), []stores.Modification{stores.Set("userName", name)}).Return(nil).Once()
+		mockedSession.On("GetRepository", "lists").Return(mockedListsRepository).Twice()
+		mockedListsRepository.On("Get", &[]models.List{}, stores.Query{Filter: stores.ElemMatch("collaborators", stores.Eq("userId", "id")), Projection: stores.Fields(stores.IDField)}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]models.List) = []models.List{{ID: "l1"}}
+		})
+		mockedListsRepository.On("Modify", stores.Eq(stores.IDField, "l1"), []stores.Modification{
+			stores.SetElement("collaborators", stores.Eq("userId", "id"), map[string]interface{}{"userName": name}),
+		}).Return(nil).Once()
+
+		r := models.GetUsersResultDto{}
+		err := service.UpdateUser("id", "admin", models.UserPatchDto{UserName: &name}, &r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.GetUsersResultDto{ID: "id", UserName: name}, r)
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+		mockedListsRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateUser() should return a badRequestError when the role is not valid", func(t *testing.T) {
+		role := "wadus"
+
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once()
+
+		err := service.UpdateUser("id", "admin", models.UserPatchDto{Role: &role}, &models.GetUsersResultDto{})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, `"wadus" is not a valid role`, err.Error())
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateUser() should return a badRequestError when admins change their own role", func(t *testing.T) {
+		role := models.UserRoleUser
+
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.User).Role = models.UserRoleAdmin
+		})
+
+		err := service.UpdateUser("id", "id", models.UserPatchDto{Role: &role}, &models.GetUsersResultDto{})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, "Admins can't change their own role", err.Error())
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateUser() should disable the user", func(t *testing.T) {
+		isActive := false
+
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*models.User) = models.User{ID: "id", UserName: "user", IsActive: true}
+		})
+		mockedRepository.On("Modify", stores.Eq(stores.IDField, "id"), []stores.Modification{stores.Set("isActive", false)}).Return(nil).Once()
+
+		r := models.GetUsersResultDto{}
+		err := service.UpdateUser("id", "admin", models.UserPatchDto{IsActive: &isActive}, &r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, models.GetUsersResultDto{ID: "id", UserName: "user"}, r)
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("UpdateUser() should return a badRequestError when admins disable themselves", func(t *testing.T) {
+		isActive := false
+
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.User).IsActive = true
+		})
+
+		err := service.UpdateUser("id", "id", models.UserPatchDto{IsActive: &isActive}, &models.GetUsersResultDto{})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, "Admins can't disable themselves", err.Error())
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+	})
+
+	t.Run("RemoveUser() should transfer the lists of the user and remove its credentials", func(t *testing.T) {
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*models.User) = models.User{ID: "id", UserName: "user"}
+		})
+		mockedRepository.On("IsValidID", "other").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "other")}).Return(nil).Once()
+		mockedSession.On("GetRepository", "lists").Return(mockedListsRepository).Times(4)
+		mockedListsRepository.On("Get", &[]models.List{}, stores.Query{Filter: stores.Eq("userId", "id"), Projection: stores.Fields(stores.IDField)}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]models.List) = []models.List{{ID: "l1"}}
+		})
+		mockedListsRepository.On("Modify", stores.Eq(stores.IDField, "l1"), []stores.Modification{
+			stores.Set("userId", "other"),
+			stores.Pull("collaborators", stores.Eq("userId", "other")),
+			stores.Inc("version", 1),
+		}).Return(nil).Once()
+		mockedListsRepository.On("Get", &[]models.List{}, stores.Query{Filter: stores.ElemMatch("collaborators", stores.Eq("userId", "id")), Projection: stores.Fields(stores.IDField)}).Return(nil).Once().Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]models.List) = []models.List{{ID: "l2"}}
+		})
+		mockedListsRepository.On("Modify", stores.Eq(stores.IDField, "l2"), []stores.Modification{
+			stores.Pull("collaborators", stores.Eq("userId", "id")),
+			stores.Inc("version", 1),
+		}).Return(nil).Once()
+		for _, name := range []string{"refreshTokens", "apiKeys", "userTokens"} {
+			mockedSession.On("GetRepository", name).Return(mockedCredentialsRepository).Once()
+		}
+		mockedCredentialsRepository.On("Remove", stores.Eq("userId", "id")).Return(nil).Once()
+		mockedCredentialsRepository.On("Remove", stores.Eq("userId", "id")).Return(&appErrors.NotFoundError{}).Times(3)
+		mockedSession.On("GetRepository", "loginAttempts").Return(mockedCredentialsRepository).Once()
+		mockedCredentialsRepository.On("Remove", stores.Eq("key", "user:user")).Return(&appErrors.NotFoundError{}).Once()
+		mockedRepository.On("Remove", stores.Eq(stores.IDField, "id")).Return(nil).Once()
+
+		err := service.RemoveUser("id", "admin", "other")
+
+		assert.Nil(t, err)
+
+		mockedSession.AssertExpectations(t)
+		mockedRepository.AssertExpectations(t)
+		mockedListsRepository.AssertExpectations(t)
+		mockedCredentialsRepository.AssertExpectations(t)
+	})
+
+	t.Run("RemoveUser() should return a badRequestError when users remove themselves", func(t *testing.T) {
+		err := service.RemoveUser("id", "id", "")
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, "Users can't remove themselves", err.Error())
+	})
+
+	t.Run("ChangePassword() should return a badRequestError when the old password is not correct", func(t *testing.T) {
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.User).PasswordHash = "hash"
+		})
+		mockedHasher.On("CompareHashAndPassword", []byte("hash"), []byte("wrong")).Return(errors.New("error")).Once()
+
+		err := service.ChangePassword("id", models.ChangePasswordDto{OldPassword: "wrong", NewPassword: "new", ConfirmNewPassword: "new"})
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, "Invalid password", err.Error())
+
+		mockedRepository.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
+	})
+
+	t.Run("ChangePassword() should save the hash of the new password", func(t *testing.T) {
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.User).PasswordHash = "hash"
+		})
+		mockedHasher.On("CompareHashAndPassword", []byte("hash"), []byte("old")).Return(nil).Once()
+		mockedHasher.On("GenerateFromPassword", []byte("new")).Return([]byte("newHash"), nil).Once()
+		mockedRepository.On("Modify", stores.Eq(stores.IDField, "id"), []stores.Modification{stores.Set("passwordHash", "newHash"), stores.Set("mustChangePassword", false)}).Return(nil).Once()
+
+		err := service.ChangePassword("id", models.ChangePasswordDto{OldPassword: "old", NewPassword: "new", ConfirmNewPassword: "new"})
+
+		assert.Nil(t, err)
+
+		mockedRepository.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
+	})
+
+	t.Run("SetPassword() should return a badRequestError when the passwords don't match", func(t *testing.T) {
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once()
+
+		err := service.SetPassword("id", "new", "other")
+
+		assert.IsType(t, &appErrors.BadRequestError{}, err)
+		assert.Equal(t, "Passwords don't match", err.Error())
+
+		mockedRepository.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
+	})
+
+	t.Run("SetPassword() should save the hash of the new password without checking the old one", func(t *testing.T) {
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once()
+		mockedHasher.On("GenerateFromPassword", []byte("new")).Return([]byte("newHash"), nil).Once()
+		mockedRepository.On("Modify", stores.Eq(stores.IDField, "id"), []stores.Modification{stores.Set("passwordHash", "newHash"), stores.Set("mustChangePassword", false)}).Return(nil).Once()
+
+		err := service.SetPassword("id", "new", "new")
+
+		assert.Nil(t, err)
+
+		mockedRepository.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
+	})
+
+	t.Run("SetPassword() should return a validationError when the password breaks the policy", func(t *testing.T) {
+		policyService := NewMyUsersService(mockedSession, mockedHasher, DefaultPasswordPolicy())
+
+		mockedRepository.On("IsValidID", "id").Return(true).Once()
+		mockedRepository.On("GetOne", &models.User{}, stores.Query{Filter: stores.Eq(stores.IDField, "id")}).Return(nil).Once().Run(func(args mock.Arguments) {
+			args.Get(0).(*models.User).UserName = "wadus"
+		})
+
+		err := policyService.SetPassword("id", "wadus", "wadus")
+
+		assert.IsType(t, &appErrors.ValidationError{}, err)
+		assert.Len(t, err.(*appErrors.ValidationError).Fields, 3)
+
+		mockedRepository.AssertExpectations(t)
+		mockedHasher.AssertExpectations(t)
+	})
 }
@@ -0,0 +1,406 @@
+package stores
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// toDocument returns a copy of the given value as a bson document
+func toDocument(v interface{}) (bson.M, error) {
+	d := bson.M{}
+
+	if v == nil {
+		return d, nil
+	}
+
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bson.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// decodeDocument fills the out value with the content of the document
+func decodeDocument(doc bson.M, out interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(data, out)
+}
+
+// decodeDocuments fills the slice pointed by out with the content of the documents
+func decodeDocuments(docs []bson.M, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("expected a pointer to a slice but got %T", out)
+	}
+
+	sliceType := v.Elem().Type()
+	result := reflect.MakeSlice(sliceType, 0, len(docs))
+
+	for _, d := range docs {
+		e := reflect.New(sliceType.Elem())
+		if err := decodeDocument(d, e.Interface()); err != nil {
+			return err
+		}
+		result = reflect.Append(result, e.Elem())
+	}
+
+	v.Elem().Set(result)
+
+	return nil
+}
+
+// matchFilter returns true if the document satisfies the filter
+func matchFilter(doc bson.M, f Filter) (bool, error) {
+	switch f.Op {
+	case "":
+		return true, nil
+	case AndOp, OrOp:
+		for _, sf := range f.Filters {
+			ok, err := matchFilter(doc, sf)
+			if err != nil {
+				return false, err
+			}
+			if f.Op == OrOp && ok {
+				return true, nil
+			}
+			if f.Op == AndOp && !ok {
+				return false, nil
+			}
+		}
+		return f.Op == AndOp, nil
+	case ElemMatchOp:
+		value, _ := lookupField(doc, f.Field)
+		elements, _ := value.([]interface{})
+		for _, e := range elements {
+			ed, isDoc := e.(bson.M)
+			if !isDoc {
+				continue
+			}
+			ok, err := matchFilter(ed, f.Filters[0])
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+
+	value, _ := lookupField(doc, f.Field)
+
+	want, err := normalizeValue(f.Value)
+	if err != nil {
+		return false, err
+	}
+
+	switch f.Op {
+	case EqOp:
+		return matchEquality(value, want), nil
+	case NeOp:
+		return !matchEquality(value, want), nil
+	case ContainsOp:
+		text, isString := value.(string)
+		sub, _ := want.(string)
+		return isString && strings.Contains(strings.ToLower(text), strings.ToLower(sub)), nil
+	case InOp:
+		values, _ := want.([]interface{})
+		for _, v := range values {
+			if matchEquality(value, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case GtOp, GteOp, LtOp, LteOp:
+		c, comparable := compareValues(value, want)
+		if !comparable {
+			return false, nil
+		}
+		switch f.Op {
+		case GtOp:
+			return c > 0, nil
+		case GteOp:
+			return c >= 0, nil
+		case LtOp:
+			return c < 0, nil
+		default:
+			return c <= 0, nil
+		}
+	}
+
+	return false, fmt.Errorf("unsupported filter operation %q", f.Op)
+}
+
+// normalizeValue returns the value as it is stored in a document
+func normalizeValue(v interface{}) (interface{}, error) {
+	d, err := toDocument(bson.M{"v": v})
+	if err != nil {
+		return nil, err
+	}
+
+	return d["v"], nil
+}
+
+// matchEquality behaves like mongo, so an array field matches when any of its elements does
+func matchEquality(value interface{}, want interface{}) bool {
+	if values, isArray := value.([]interface{}); isArray {
+		if _, wantArray := want.([]interface{}); !wantArray {
+			for _, v := range values {
+				if equalValues(v, want) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	return equalValues(value, want)
+}
+
+func equalValues(a interface{}, b interface{}) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues returns -1, 0 or 1 and true when both values are comparable
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return compareFloats(fa, fb), true
+		}
+		return 0, false
+	}
+
+	switch va := a.(type) {
+	case string:
+		if vb, ok := b.(string); ok {
+			return strings.Compare(va, vb), true
+		}
+	case time.Time:
+		if vb, ok := b.(time.Time); ok {
+			if va.Before(vb) {
+				return -1, true
+			}
+			if va.After(vb) {
+				return 1, true
+			}
+			return 0, true
+		}
+	case bool:
+		if vb, ok := b.(bool); ok && va == vb {
+			return 0, true
+		}
+	}
+
+	return 0, false
+}
+
+func compareFloats(a float64, b float64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+
+	return 0, false
+}
+
+func lookupField(doc bson.M, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+
+	var current interface{} = doc
+	for _, p := range parts {
+		d, isDoc := current.(bson.M)
+		if !isDoc {
+			return nil, false
+		}
+
+		v, exists := d[p]
+		if !exists {
+			return nil, false
+		}
+		current = v
+	}
+
+	return current, true
+}
+
+// projectDocument returns a document which only contains the id and the fields of the projection
+func projectDocument(doc bson.M, p Projection) bson.M {
+	if p == nil {
+		return doc
+	}
+
+	result := bson.M{}
+	if id, exists := doc[IDField]; exists {
+		result[IDField] = id
+	}
+
+	for _, f := range p {
+		if value, exists := doc[f]; exists {
+			result[f] = value
+		}
+	}
+
+	return result
+}
+
+// sortDocuments sorts the documents by the given fields
+func sortDocuments(docs []bson.M, sorts []Sort) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, s := range sorts {
+			a, _ := lookupField(docs[i], s.Field)
+			b, _ := lookupField(docs[j], s.Field)
+
+			c, _ := compareValues(a, b)
+			if c == 0 {
+				continue
+			}
+
+			return (c < 0) != s.Descending
+		}
+		return false
+	})
+}
+
+// replaceDocument returns the new content of the document keeping its id
+func replaceDocument(doc bson.M, replacement interface{}) (bson.M, error) {
+	r, err := toDocument(replacement)
+	if err != nil {
+		return nil, err
+	}
+
+	r[IDField] = doc[IDField]
+
+	return r, nil
+}
+
+// modifyDocument returns a copy of the document with the modifications applied
+func modifyDocument(doc bson.M, modifications []Modification) (bson.M, error) {
+	result, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range modifications {
+		v, err := normalizeValue(m.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch m.Op {
+		case SetOp:
+			result[m.Field] = v
+		case IncOp:
+			sum, err := addNumbers(result[m.Field], v)
+			if err != nil {
+				return nil, err
+			}
+			result[m.Field] = sum
+		case PushOp:
+			elements, _ := result[m.Field].([]interface{})
+			result[m.Field] = append(elements, v)
+		case PullOp, SetElementOp:
+			elements, err := modifyElements(result[m.Field], m, v)
+			if err != nil {
+				return nil, err
+			}
+			result[m.Field] = elements
+		default:
+			return nil, fmt.Errorf("unsupported modification %q", m.Op)
+		}
+	}
+
+	return result, nil
+}
+
+// modifyElements returns the array elements after removing or changing the ones
+// which match the modification
+func modifyElements(field interface{}, m Modification, value interface{}) ([]interface{}, error) {
+	elements, _ := field.([]interface{})
+	values, _ := value.(bson.M)
+
+	result := []interface{}{}
+	for _, e := range elements {
+		ed, isDoc := e.(bson.M)
+		if !isDoc {
+			result = append(result, e)
+			continue
+		}
+
+		ok, err := matchFilter(ed, m.Match)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok && m.Op == PullOp {
+			continue
+		}
+
+		if ok {
+			for k, v := range values {
+				ed[k] = v
+			}
+		}
+		result = append(result, ed)
+	}
+
+	return result, nil
+}
+
+func addNumbers(current interface{}, inc interface{}) (interface{}, error) {
+	if current == nil {
+		current = 0
+	}
+
+	switch c := current.(type) {
+	case int:
+		if i, ok := inc.(int); ok {
+			return c + i, nil
+		}
+	case int64:
+		switch i := inc.(type) {
+		case int:
+			return c + int64(i), nil
+		case int64:
+			return c + i, nil
+		}
+	}
+
+	fc, okc := toFloat(current)
+	fi, oki := toFloat(inc)
+	if !okc || !oki {
+		return nil, fmt.Errorf("cannot increment %v by %v", current, inc)
+	}
+
+	return fc + fi, nil
+}
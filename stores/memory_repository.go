@@ -0,0 +1,241 @@
+package stores
+
+import (
+	"reflect"
+	"sync"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type memoryCollection struct {
+	name      string
+	mutex     sync.RWMutex
+	documents []bson.M
+}
+
+// MemoryRepository is the store which keeps the documents in memory
+type MemoryRepository struct {
+	collection *memoryCollection
+}
+
+// Get returns several items from a collection
+func (s *MemoryRepository) Get(doc interface{}, query Query) error {
+	s.collection.mutex.RLock()
+	defer s.collection.mutex.RUnlock()
+
+	found, err := s.find(query)
+	if err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
+	}
+
+	if err := decodeDocuments(found, doc); err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
+	}
+
+	return nil
+}
+
+// GetOne returns a single item
+func (s *MemoryRepository) GetOne(doc interface{}, query Query) error {
+	s.collection.mutex.RLock()
+	defer s.collection.mutex.RUnlock()
+
+	query.Limit = 1
+	found, err := s.find(query)
+	if err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
+	}
+
+	if len(found) == 0 {
+		return &appErrors.NotFoundError{Model: s.collection.name}
+	}
+
+	if err := decodeDocument(found[0], doc); err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
+	}
+
+	return nil
+}
+
+// Count returns the number of documents which match the filter
+func (s *MemoryRepository) Count(filter Filter) (int, error) {
+	s.collection.mutex.RLock()
+	defer s.collection.mutex.RUnlock()
+
+	found, err := s.find(Query{Filter: filter})
+	if err != nil {
+		return 0, s.unexpectedError("Error counting in the database", err)
+	}
+
+	return len(found), nil
+}
+
+// Add adds a new document to the collection
+func (s *MemoryRepository) Add(doc interface{}) (string, error) {
+	id := NewID()
+	reflect.ValueOf(doc).Elem().FieldByName("ID").SetString(id)
+
+	d, err := toDocument(doc)
+	if err != nil {
+		return "", s.unexpectedError("Error inserting in the database", err)
+	}
+
+	s.collection.mutex.Lock()
+	defer s.collection.mutex.Unlock()
+
+	s.collection.documents = append(s.collection.documents, d)
+
+	return id, nil
+}
+
+// Update replaces a document
+func (s *MemoryRepository) Update(filter Filter, doc interface{}) error {
+	return s.update(filter, func(d bson.M) (bson.M, error) {
+		return replaceDocument(d, doc)
+	})
+}
+
+// Modify applies the modifications to a document
+func (s *MemoryRepository) Modify(filter Filter, modifications ...Modification) error {
+	return s.update(filter, func(d bson.M) (bson.M, error) {
+		return modifyDocument(d, modifications)
+	})
+}
+
+// Upsert applies the modifications to the first document which matches the filter, or adds
+// the document with them when there isn't any, and reads the result into the document
+func (s *MemoryRepository) Upsert(filter Filter, doc interface{}, modifications ...Modification) error {
+	s.collection.mutex.Lock()
+	defer s.collection.mutex.Unlock()
+
+	i, err := s.indexOf(filter)
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	var d bson.M
+	if i < 0 {
+		reflect.ValueOf(doc).Elem().FieldByName("ID").SetString(NewID())
+		d, err = toDocument(doc)
+	} else {
+		d = s.collection.documents[i]
+	}
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	updated, err := modifyDocument(d, modifications)
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	if i < 0 {
+		s.collection.documents = append(s.collection.documents, updated)
+	} else {
+		s.collection.documents[i] = updated
+	}
+
+	if err := decodeDocument(updated, doc); err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	return nil
+}
+
+// Remove removes a document from the collection
+func (s *MemoryRepository) Remove(filter Filter) error {
+	s.collection.mutex.Lock()
+	defer s.collection.mutex.Unlock()
+
+	i, err := s.indexOf(filter)
+	if err != nil {
+		return s.unexpectedError("Error removing from the database", err)
+	}
+
+	if i < 0 {
+		return &appErrors.NotFoundError{Model: s.collection.name}
+	}
+
+	docs := s.collection.documents
+	s.collection.documents = append(docs[:i:i], docs[i+1:]...)
+
+	return nil
+}
+
+// IsValidID returns true if the id is valid
+func (s *MemoryRepository) IsValidID(id string) bool {
+	return bson.IsObjectIdHex(id)
+}
+
+func (s *MemoryRepository) update(filter Filter, change func(bson.M) (bson.M, error)) error {
+	s.collection.mutex.Lock()
+	defer s.collection.mutex.Unlock()
+
+	i, err := s.indexOf(filter)
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	if i < 0 {
+		return &appErrors.NotFoundError{Model: s.collection.name}
+	}
+
+	updated, err := change(s.collection.documents[i])
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	s.collection.documents[i] = updated
+
+	return nil
+}
+
+// find returns the documents which match the query. The caller must hold the collection lock.
+func (s *MemoryRepository) find(query Query) ([]bson.M, error) {
+	found := []bson.M{}
+	for _, d := range s.collection.documents {
+		ok, err := matchFilter(d, query.Filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = append(found, d)
+		}
+	}
+
+	sortDocuments(found, query.Sort)
+
+	if query.Limit > 0 && len(found) > query.Limit {
+		found = found[:query.Limit]
+	}
+
+	for i, d := range found {
+		found[i] = projectDocument(d, query.Projection)
+	}
+
+	return found, nil
+}
+
+// indexOf returns the position of the first document which matches the filter or -1
+// if there isn't any. The caller must hold the collection lock.
+func (s *MemoryRepository) indexOf(filter Filter) (int, error) {
+	for i, d := range s.collection.documents {
+		ok, err := matchFilter(d, filter)
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+func (s *MemoryRepository) unexpectedError(msg string, err error) error {
+	return &appErrors.UnexpectedError{
+		Msg:           msg,
+		InternalError: err,
+	}
+}
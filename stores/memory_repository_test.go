@@ -0,0 +1,56 @@
+package stores
+
+import (
+	"testing"
+
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Run("CRUD", func(t *testing.T) {
+		testRepositoryCRUD(t, NewMyMemorySession())
+	})
+
+	t.Run("queries", func(t *testing.T) {
+		testRepositoryQueries(t, NewMyMemorySession())
+	})
+
+	t.Run("times", func(t *testing.T) {
+		testRepositoryTimes(t, NewMyMemorySession())
+	})
+
+	t.Run("arrays", func(t *testing.T) {
+		testRepositoryArrays(t, NewMyMemorySession())
+	})
+
+	t.Run("counters", func(t *testing.T) {
+		testRepositoryCounters(t, NewMyMemorySession())
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		testRepositoryUpsert(t, NewMyMemorySession())
+	})
+
+	t.Run("GetRepository() returns the same collection for the same name", func(t *testing.T) {
+		session := NewMyMemorySession()
+
+		assert.Equal(t, session.GetRepository("lists"), session.GetRepository("lists"))
+	})
+
+	t.Run("equality filters match array elements", func(t *testing.T) {
+		repository := NewMyMemorySession().GetRepository("lists")
+
+		for _, l := range models.SampleListSlice() {
+			_, err := repository.Add(&l)
+			assert.Nil(t, err)
+		}
+
+		got := []models.List{}
+		err := repository.Get(&got, Query{Filter: Eq("items", models.Item{Title: "item21", Description: "this is the first item"})})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(got))
+		assert.Equal(t, "list2", got[0].Name)
+	})
+}
@@ -0,0 +1,36 @@
+package stores
+
+import (
+	"log"
+	"sync"
+)
+
+// MyMemorySession is a MongoSession which keeps all the collections in memory.
+// It is useful to run the app or the tests without a mongo database
+type MyMemorySession struct {
+	mutex       sync.Mutex
+	collections map[string]*memoryCollection
+}
+
+// NewMyMemorySession returns a new MyMemorySession
+func NewMyMemorySession() *MyMemorySession {
+	log.Println("Using in memory database.")
+
+	return &MyMemorySession{
+		collections: map[string]*memoryCollection{},
+	}
+}
+
+// GetRepository returns a memory repository for the given collection
+func (s *MyMemorySession) GetRepository(collectionName string) Repository {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c, ok := s.collections[collectionName]
+	if !ok {
+		c = &memoryCollection{name: collectionName}
+		s.collections[collectionName] = c
+	}
+
+	return &MemoryRepository{c}
+}
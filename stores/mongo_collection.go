@@ -1,17 +1,23 @@
 package stores
 
 import (
+	"errors"
+
 	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // MongoCollection is an interface which contains the methods used by the mongo collection
 // for testing purposes
 type MongoCollection interface {
-	Find(doc interface{}, query interface{}, selector interface{}) error
-	FindOne(doc interface{}, query interface{}, selector interface{}) error
+	Find(doc interface{}, query interface{}, selector interface{}, sort []string, limit int) error
+	FindOne(doc interface{}, query interface{}, selector interface{}, sort []string) error
+	Count(query interface{}) (int, error)
 	Insert(doc interface{}) error
 	Remove(query interface{}) error
 	Update(query interface{}, doc interface{}) error
+	UpdateWithArrayFilters(query interface{}, doc interface{}, arrayFilters []interface{}) error
+	Upsert(query interface{}, update interface{}, doc interface{}) error
 	Name() string
 }
 
@@ -25,14 +31,19 @@ func NewMyMongoCollection(c *mgo.Collection) *MyMongoCollection {
 	return &MyMongoCollection{c}
 }
 
-// Find returns all documents
-func (c *MyMongoCollection) Find(doc interface{}, query interface{}, selector interface{}) error {
-	return c.collection.Find(query).Select(selector).All(doc)
+// Find returns all documents. A zero limit returns all of them.
+func (c *MyMongoCollection) Find(doc interface{}, query interface{}, selector interface{}, sort []string, limit int) error {
+	return c.query(query, selector, sort).Limit(limit).All(doc)
 }
 
 // FindOne returns a single document
-func (c *MyMongoCollection) FindOne(doc interface{}, query interface{}, selector interface{}) error {
-	return c.collection.Find(query).Select(selector).One(doc)
+func (c *MyMongoCollection) FindOne(doc interface{}, query interface{}, selector interface{}, sort []string) error {
+	return c.query(query, selector, sort).One(doc)
+}
+
+// Count returns the number of documents which match the query
+func (c *MyMongoCollection) Count(query interface{}) (int, error) {
+	return c.collection.Find(query).Count()
 }
 
 // Insert adds a new document
@@ -50,6 +61,52 @@ func (c *MyMongoCollection) Update(query interface{}, doc interface{}) error {
 	return c.collection.Update(query, doc)
 }
 
+// UpdateWithArrayFilters updates a document using the array filters. mgo doesn't
+// support them, so the update command is run directly.
+func (c *MyMongoCollection) UpdateWithArrayFilters(query interface{}, doc interface{}, arrayFilters []interface{}) error {
+	cmd := bson.D{
+		{Name: "update", Value: c.collection.Name},
+		{Name: "updates", Value: []bson.M{{"q": query, "u": doc, "arrayFilters": arrayFilters}}},
+	}
+
+	result := struct {
+		N           int `bson:"n"`
+		WriteErrors []struct {
+			ErrMsg string `bson:"errmsg"`
+		} `bson:"writeErrors"`
+	}{}
+
+	if err := c.collection.Database.Run(cmd, &result); err != nil {
+		return err
+	}
+
+	if len(result.WriteErrors) > 0 {
+		return errors.New(result.WriteErrors[0].ErrMsg)
+	}
+
+	if result.N == 0 {
+		return mgo.ErrNotFound
+	}
+
+	return nil
+}
+
+// Upsert updates the document which matches the query or adds it when there isn't any, and
+// reads the result into doc
+func (c *MyMongoCollection) Upsert(query interface{}, update interface{}, doc interface{}) error {
+	_, err := c.collection.Find(query).Apply(mgo.Change{Update: update, Upsert: true, ReturnNew: true}, doc)
+	return err
+}
+
+func (c *MyMongoCollection) query(query interface{}, selector interface{}, sort []string) *mgo.Query {
+	q := c.collection.Find(query).Select(selector)
+	if len(sort) > 0 {
+		q = q.Sort(sort...)
+	}
+
+	return q
+}
+
 // Name returns the mongo collection name
 func (c *MyMongoCollection) Name() string {
 	return c.collection.Name
@@ -0,0 +1,144 @@
+package stores
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+var mongoOperators = map[FilterOp]string{
+	NeOp:  "$ne",
+	InOp:  "$in",
+	GtOp:  "$gt",
+	GteOp: "$gte",
+	LtOp:  "$lt",
+	LteOp: "$lte",
+}
+
+// toMongoQuery returns the mongo query equivalent to the filter
+func toMongoQuery(f Filter) (bson.M, error) {
+	return toPrefixedMongoQuery(f, "")
+}
+
+// toPrefixedMongoQuery returns the mongo query equivalent to the filter adding the
+// prefix to the field names, as needed by the array filters
+func toPrefixedMongoQuery(f Filter, prefix string) (bson.M, error) {
+	field := prefix + f.Field
+
+	switch f.Op {
+	case "":
+		return bson.M{}, nil
+	case EqOp:
+		return bson.M{field: f.Value}, nil
+	case ContainsOp:
+		text, _ := f.Value.(string)
+		return bson.M{field: bson.RegEx{Pattern: regexp.QuoteMeta(text), Options: "i"}}, nil
+	case ElemMatchOp:
+		q, err := toMongoQuery(f.Filters[0])
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{field: bson.M{"$elemMatch": q}}, nil
+	case AndOp, OrOp:
+		queries := []bson.M{}
+		for _, sf := range f.Filters {
+			q, err := toPrefixedMongoQuery(sf, prefix)
+			if err != nil {
+				return nil, err
+			}
+			queries = append(queries, q)
+		}
+		return bson.M{"$" + string(f.Op): queries}, nil
+	}
+
+	op, ok := mongoOperators[f.Op]
+	if !ok {
+		return nil, fmt.Errorf("unsupported filter operation %q", f.Op)
+	}
+
+	return bson.M{field: bson.M{op: f.Value}}, nil
+}
+
+// toMongoSelector returns the mongo selector equivalent to the projection
+func toMongoSelector(p Projection) bson.M {
+	if p == nil {
+		return nil
+	}
+
+	s := bson.M{IDField: 1}
+	for _, f := range p {
+		s[f] = 1
+	}
+
+	return s
+}
+
+// toMongoSort returns the fields used to sort a mongo query
+func toMongoSort(sort []Sort) []string {
+	if len(sort) == 0 {
+		return nil
+	}
+
+	fields := []string{}
+	for _, s := range sort {
+		if s.Descending {
+			fields = append(fields, "-"+s.Field)
+		} else {
+			fields = append(fields, s.Field)
+		}
+	}
+
+	return fields
+}
+
+// toMongoUpdate returns the mongo update equivalent to the modifications and the
+// array filters used by it
+func toMongoUpdate(modifications []Modification) (bson.M, []interface{}, error) {
+	u := bson.M{}
+	arrayFilters := []interface{}{}
+
+	for _, m := range modifications {
+		switch m.Op {
+		case SetOp:
+			mongoUpdateFields(u, "$set")[m.Field] = m.Value
+		case IncOp:
+			mongoUpdateFields(u, "$inc")[m.Field] = m.Value
+		case PushOp:
+			mongoUpdateFields(u, "$push")[m.Field] = m.Value
+		case PullOp:
+			q, err := toMongoQuery(m.Match)
+			if err != nil {
+				return nil, nil, err
+			}
+			mongoUpdateFields(u, "$pull")[m.Field] = q
+		case SetElementOp:
+			identifier := fmt.Sprintf("e%v", len(arrayFilters))
+			q, err := toPrefixedMongoQuery(m.Match, identifier+".")
+			if err != nil {
+				return nil, nil, err
+			}
+			arrayFilters = append(arrayFilters, q)
+
+			values, _ := m.Value.(map[string]interface{})
+			for k, v := range values {
+				mongoUpdateFields(u, "$set")[fmt.Sprintf("%v.$[%v].%v", m.Field, identifier, k)] = v
+			}
+		default:
+			return nil, nil, fmt.Errorf("unsupported modification %q", m.Op)
+		}
+	}
+
+	return u, arrayFilters, nil
+}
+
+// mongoUpdateFields returns the fields of the update operator, adding it if needed
+func mongoUpdateFields(u bson.M, op string) bson.M {
+	fields, ok := u[op].(bson.M)
+	if !ok {
+		fields = bson.M{}
+		u[op] = fields
+	}
+
+	return fields
+}
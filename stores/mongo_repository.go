@@ -1,6 +1,7 @@
 package stores
 
 import (
+	"errors"
 	"reflect"
 
 	appErrors "github.com/AngelVlc/lists-backend/errors"
@@ -13,37 +14,56 @@ type MongoRepository struct {
 }
 
 // Get returns several items from a collection
-func (s *MongoRepository) Get(doc interface{}, query interface{}, selector interface{}) error {
-	if err := s.mongoCollection.Find(doc, query, selector); err != nil {
-		return &appErrors.UnexpectedError{
-			Msg:           "Error retrieving from the database",
-			InternalError: err,
-		}
+func (s *MongoRepository) Get(doc interface{}, query Query) error {
+	q, err := toMongoQuery(query.Filter)
+	if err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
+	}
+
+	if err := s.mongoCollection.Find(doc, q, toMongoSelector(query.Projection), toMongoSort(query.Sort), query.Limit); err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
 	}
 
 	return nil
 }
 
 // GetOne returns a single item
-func (s *MongoRepository) GetOne(doc interface{}, query interface{}, selector interface{}) error {
-	if err := s.mongoCollection.FindOne(doc, query, selector); err != nil {
+func (s *MongoRepository) GetOne(doc interface{}, query Query) error {
+	q, err := toMongoQuery(query.Filter)
+	if err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
+	}
+
+	if err := s.mongoCollection.FindOne(doc, q, toMongoSelector(query.Projection), toMongoSort(query.Sort)); err != nil {
 		if err.Error() == "not found" {
 			return &appErrors.NotFoundError{
 				Model: s.mongoCollection.Name(),
 			}
 		}
-		return &appErrors.UnexpectedError{
-			Msg:           "Error retrieving from the database",
-			InternalError: err,
-		}
+		return s.unexpectedError("Error retrieving from the database", err)
 	}
 
 	return nil
 }
 
+// Count returns the number of documents which match the filter
+func (s *MongoRepository) Count(filter Filter) (int, error) {
+	q, err := toMongoQuery(filter)
+	if err != nil {
+		return 0, s.unexpectedError("Error counting in the database", err)
+	}
+
+	n, err := s.mongoCollection.Count(q)
+	if err != nil {
+		return 0, s.unexpectedError("Error counting in the database", err)
+	}
+
+	return n, nil
+}
+
 // Add adds a new document to the collection
 func (s *MongoRepository) Add(doc interface{}) (string, error) {
-	id := bson.NewObjectId().Hex()
+	id := NewID()
 	reflect.ValueOf(doc).Elem().FieldByName("ID").SetString(id)
 
 	if err := s.mongoCollection.Insert(doc); err != nil {
@@ -56,41 +76,111 @@ func (s *MongoRepository) Add(doc interface{}) (string, error) {
 	return id, nil
 }
 
-// Update updates a document
-func (s *MongoRepository) Update(query interface{}, doc interface{}) error {
-	if err := s.mongoCollection.Update(query, doc); err != nil {
+// Update replaces a document
+func (s *MongoRepository) Update(filter Filter, doc interface{}) error {
+	return s.update(filter, doc, nil)
+}
+
+// Modify applies the modifications to a document
+func (s *MongoRepository) Modify(filter Filter, modifications ...Modification) error {
+	u, arrayFilters, err := toMongoUpdate(modifications)
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	return s.update(filter, u, arrayFilters)
+}
+
+// Upsert applies the modifications to the first document which matches the filter, or adds
+// the document with them when there isn't any, and reads the result into the document.
+// There should be a unique index on the filter fields, so the concurrent calls can't add
+// two documents.
+func (s *MongoRepository) Upsert(filter Filter, doc interface{}, modifications ...Modification) error {
+	q, err := toMongoQuery(filter)
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	u, arrayFilters, err := toMongoUpdate(modifications)
+	if err == nil && len(arrayFilters) > 0 {
+		err = errors.New("the array elements can't be changed in an upsert")
+	}
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	reflect.ValueOf(doc).Elem().FieldByName("ID").SetString(NewID())
+	d, err := toDocument(doc)
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	// the fields of the new document can't be changed by the modifications too
+	for _, m := range modifications {
+		delete(d, m.Field)
+	}
+	if len(d) > 0 {
+		u["$setOnInsert"] = d
+	}
+
+	if err := s.mongoCollection.Upsert(q, u, doc); err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	return nil
+}
+
+// Remove removes a document from the collection
+func (s *MongoRepository) Remove(filter Filter) error {
+	q, err := toMongoQuery(filter)
+	if err != nil {
+		return s.unexpectedError("Error removing from the database", err)
+	}
+
+	if err := s.mongoCollection.Remove(q); err != nil {
 		if err.Error() == "not found" {
 			return &appErrors.NotFoundError{
 				Model: s.mongoCollection.Name(),
 			}
 		}
-		return &appErrors.UnexpectedError{
-			Msg:           "Error updating the database",
-			InternalError: err,
-		}
+		return s.unexpectedError("Error removing from the database", err)
 	}
 
 	return nil
 }
 
-// Remove removes a document from the collection
-func (s *MongoRepository) Remove(query interface{}) error {
-	if err := s.mongoCollection.Remove(query); err != nil {
+// IsValidID returns true if the id is valid
+func (s *MongoRepository) IsValidID(id string) bool {
+	return bson.IsObjectIdHex(id)
+}
+
+func (s *MongoRepository) update(filter Filter, doc interface{}, arrayFilters []interface{}) error {
+	q, err := toMongoQuery(filter)
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	if len(arrayFilters) > 0 {
+		err = s.mongoCollection.UpdateWithArrayFilters(q, doc, arrayFilters)
+	} else {
+		err = s.mongoCollection.Update(q, doc)
+	}
+
+	if err != nil {
 		if err.Error() == "not found" {
 			return &appErrors.NotFoundError{
 				Model: s.mongoCollection.Name(),
 			}
 		}
-		return &appErrors.UnexpectedError{
-			Msg:           "Error removing from the database",
-			InternalError: err,
-		}
+		return s.unexpectedError("Error updating the database", err)
 	}
 
 	return nil
 }
 
-// IsValidID returns true if the id is valid
-func (s *MongoRepository) IsValidID(id string) bool {
-	return bson.IsObjectIdHex(id)
+func (s *MongoRepository) unexpectedError(msg string, err error) error {
+	return &appErrors.UnexpectedError{
+		Msg:           msg,
+		InternalError: err,
+	}
 }
@@ -15,7 +15,7 @@ func TestMongoStore(t *testing.T) {
 	repository := session.GetRepository("lists")
 
 	gotLists := []models.GetListsResultDto{}
-	err := repository.Get(&gotLists, nil, bson.M{"name": 1})
+	err := repository.Get(&gotLists, Query{Projection: Fields("name")})
 	assert.Equal(t, 0, len(gotLists), "new collection should have zero lists")
 	assert.Nil(t, err)
 
@@ -25,38 +25,55 @@ func TestMongoStore(t *testing.T) {
 	assert.NotEmpty(t, id)
 
 	foundList := models.List{}
-	err = repository.GetOne(&foundList, bson.D{{"_id", id}}, nil)
+	err = repository.GetOne(&foundList, Query{Filter: Eq(IDField, id)})
 	assert.Nil(t, err)
 	assert.Equal(t, data.Name, foundList.Name)
 
 	gotLists = []models.GetListsResultDto{}
-	err = repository.Get(&gotLists, nil, bson.M{"name": 1})
+	err = repository.Get(&gotLists, Query{Projection: Fields("name")})
 	assert.Equal(t, 1, len(gotLists), "after adding a list the new collection should have one list")
 	assert.Nil(t, err)
 
 	foundList = models.List{}
-	err = repository.GetOne(&foundList, bson.D{{"_id", gotLists[0].ID}}, nil)
+	err = repository.GetOne(&foundList, Query{Filter: Eq(IDField, gotLists[0].ID)})
 	assert.Nil(t, err)
 	assert.Equal(t, data.Name, foundList.Name)
 
 	dataToReplace := models.SampleList()
 	dataToReplace.Name = "REPLACED"
 	dataToReplace.ID = foundList.ID
-	err = repository.Update(bson.D{{"_id", foundList.ID}}, &dataToReplace)
+	err = repository.Update(Eq(IDField, foundList.ID), &dataToReplace)
 	assert.Nil(t, err)
 
 	foundList = models.List{}
-	err = repository.GetOne(&foundList, bson.D{{"_id", gotLists[0].ID}}, nil)
+	err = repository.GetOne(&foundList, Query{Filter: Eq(IDField, gotLists[0].ID)})
 	assert.Nil(t, err)
 	assert.Equal(t, dataToReplace.Name, foundList.Name)
 
-	err = repository.Remove(bson.D{{"_id", data.ID}})
+	err = repository.Remove(Eq(IDField, data.ID))
 	assert.Nil(t, err)
 
 	foundList = models.List{}
-	err = repository.GetOne(&foundList, bson.D{{"_id", gotLists[0].ID}}, nil)
+	err = repository.GetOne(&foundList, Query{Filter: Eq(IDField, gotLists[0].ID)})
 	assert.NotNil(t, err)
 
 	err = session.session.DB(session.databaseName).C("lists").DropCollection()
 	assert.Nil(t, err)
+
+	// the items saved before the items had an id get one when migrating
+	lists := session.session.DB(session.databaseName).C("lists")
+	listID := NewID()
+	err = lists.Insert(bson.M{"_id": listID, "name": "list", "items": []bson.M{{"title": "old"}}})
+	assert.Nil(t, err)
+
+	assert.Nil(t, migrate(session.session.DB(session.databaseName)))
+
+	foundList = models.List{}
+	err = repository.GetOne(&foundList, Query{Filter: Eq(IDField, listID)})
+	assert.Nil(t, err)
+	assert.Equal(t, "old", foundList.Items[0].Title)
+	assert.True(t, repository.IsValidID(foundList.Items[0].ID))
+
+	err = lists.DropCollection()
+	assert.Nil(t, err)
 }
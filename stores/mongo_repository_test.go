@@ -15,19 +15,19 @@ type MockedMongoCollection struct {
 	mock.Mock
 }
 
-func (m *MockedMongoCollection) Find(doc interface{}, query interface{}, selector interface{}) error {
-	args := m.Called(doc, query, selector)
+func (m *MockedMongoCollection) Find(doc interface{}, query interface{}, selector interface{}, sort []string, limit int) error {
+	args := m.Called(doc, query, selector, sort, limit)
 	return args.Error(0)
 }
 
-func (m *MockedMongoCollection) GetOne(doc interface{}, query interface{}, selector interface{}) error {
-	args := m.Called(doc, query, selector)
+func (m *MockedMongoCollection) FindOne(doc interface{}, query interface{}, selector interface{}, sort []string) error {
+	args := m.Called(doc, query, selector, sort)
 	return args.Error(0)
 }
 
-func (m *MockedMongoCollection) FindOne(doc interface{}, query interface{}, selector interface{}) error {
-	args := m.Called(doc, query, selector)
-	return args.Error(0)
+func (m *MockedMongoCollection) Count(query interface{}) (int, error) {
+	args := m.Called(query)
+	return args.Int(0), args.Error(1)
 }
 
 func (m *MockedMongoCollection) Insert(doc interface{}) error {
@@ -45,11 +45,52 @@ func (m *MockedMongoCollection) Update(query interface{}, doc interface{}) error
 	return args.Error(0)
 }
 
+func (m *MockedMongoCollection) UpdateWithArrayFilters(query interface{}, doc interface{}, arrayFilters []interface{}) error {
+	args := m.Called(query, doc, arrayFilters)
+	return args.Error(0)
+}
+
+func (m *MockedMongoCollection) Upsert(query interface{}, update interface{}, doc interface{}) error {
+	args := m.Called(query, update, doc)
+	return args.Error(0)
+}
+
 func (m *MockedMongoCollection) Name() string {
 	args := m.Called()
 	return args.String(0)
 }
 
+func TestUpsert(t *testing.T) {
+	testMongoCollection := new(MockedMongoCollection)
+
+	repository := MongoRepository{testMongoCollection}
+
+	t.Run("Upsert() sets the fields which aren't modified only when the document is added", func(t *testing.T) {
+		a := models.LoginAttempt{Key: "wadus"}
+		update := mock.MatchedBy(func(u bson.M) bool {
+			onInsert, _ := u["$setOnInsert"].(bson.M)
+			_, hasFailures := onInsert["failures"]
+			return onInsert["key"] == "wadus" && onInsert["_id"] == a.ID && !hasFailures &&
+				assert.ObjectsAreEqual(bson.M{"failures": 1}, u["$inc"])
+		})
+		testMongoCollection.On("Upsert", bson.M{"key": "wadus"}, update, &a).Return(nil).Once()
+
+		err := repository.Upsert(Eq("key", "wadus"), &a, Inc("failures", 1))
+
+		assert.Nil(t, err)
+		assert.True(t, repository.IsValidID(a.ID))
+		testMongoCollection.AssertExpectations(t)
+	})
+
+	t.Run("Upsert() returns an unexpected error when the upsert fails", func(t *testing.T) {
+		testMongoCollection.On("Upsert", bson.M{"key": "wadus"}, mock.Anything, mock.Anything).Return(errors.New("wadus")).Once()
+
+		err := repository.Upsert(Eq("key", "wadus"), &models.LoginAttempt{}, Inc("failures", 1))
+
+		assertFailedOperation(t, testMongoCollection, err, "Error updating the database")
+	})
+}
+
 func TestUpdate(t *testing.T) {
 	testMongoCollection := new(MockedMongoCollection)
 
@@ -59,9 +100,9 @@ func TestUpdate(t *testing.T) {
 		id := bson.NewObjectId().Hex()
 		l := models.SampleList()
 
-		testMongoCollection.On("Update", bson.D{{"_id", id}}, &l).Return(errors.New("wadus")).Once()
+		testMongoCollection.On("Update", bson.M{"_id": id}, &l).Return(errors.New("wadus")).Once()
 
-		err := repository.Update(bson.D{{"_id", id}}, &l)
+		err := repository.Update(Eq(IDField, id), &l)
 
 		assert.IsType(t, &appErrors.UnexpectedError{}, err)
 
@@ -71,10 +112,10 @@ func TestUpdate(t *testing.T) {
 	t.Run("Update() returns a not found error when document does not exits", func(t *testing.T) {
 		id := bson.NewObjectId().Hex()
 		l := models.SampleList()
-		testMongoCollection.On("Update", bson.D{{"_id", id}}, &l).Return(errors.New("not found")).Once()
+		testMongoCollection.On("Update", bson.M{"_id": id}, &l).Return(errors.New("not found")).Once()
 		testMongoCollection.On("Name").Return("document").Once()
 
-		err := repository.Update(bson.D{{"_id", id}}, &l)
+		err := repository.Update(Eq(IDField, id), &l)
 
 		assert.IsType(t, &appErrors.NotFoundError{}, err)
 
@@ -85,9 +126,9 @@ func TestUpdate(t *testing.T) {
 		id := bson.NewObjectId().Hex()
 		l := models.SampleList()
 
-		testMongoCollection.On("Update", bson.D{{"_id", id}}, &l).Return(nil).Once()
+		testMongoCollection.On("Update", bson.M{"_id": id}, &l).Return(nil).Once()
 
-		err := repository.Update(bson.D{{"_id", id}}, &l)
+		err := repository.Update(Eq(IDField, id), &l)
 
 		assertSuccededOperation(t, testMongoCollection, err)
 	})
@@ -101,9 +142,9 @@ func TestRemove(t *testing.T) {
 	t.Run("Remove() returns an unexpected error when the remove fails", func(t *testing.T) {
 		id := bson.NewObjectId().Hex()
 
-		testMongoCollection.On("Remove", bson.D{{"_id", id}}).Return(errors.New("wadus")).Once()
+		testMongoCollection.On("Remove", bson.M{"_id": id}).Return(errors.New("wadus")).Once()
 
-		err := repository.Remove(bson.D{{"_id", id}})
+		err := repository.Remove(Eq(IDField, id))
 
 		assert.IsType(t, &appErrors.UnexpectedError{}, err)
 
@@ -112,10 +153,10 @@ func TestRemove(t *testing.T) {
 
 	t.Run("Remove() returns a not found error when document does not exits", func(t *testing.T) {
 		id := bson.NewObjectId().Hex()
-		testMongoCollection.On("Remove", bson.D{{"_id", id}}).Return(errors.New("not found")).Once()
+		testMongoCollection.On("Remove", bson.M{"_id": id}).Return(errors.New("not found")).Once()
 		testMongoCollection.On("Name").Return("document").Once()
 
-		err := repository.Remove(bson.D{{"_id", id}})
+		err := repository.Remove(Eq(IDField, id))
 
 		assert.IsType(t, &appErrors.NotFoundError{}, err)
 
@@ -125,9 +166,9 @@ func TestRemove(t *testing.T) {
 	t.Run("Remove() removes a list", func(t *testing.T) {
 		id := bson.NewObjectId().Hex()
 
-		testMongoCollection.On("Remove", bson.D{{"_id", id}}).Return(nil).Once()
+		testMongoCollection.On("Remove", bson.M{"_id": id}).Return(nil).Once()
 
-		err := repository.Remove(bson.D{{"_id", id}})
+		err := repository.Remove(Eq(IDField, id))
 
 		assertSuccededOperation(t, testMongoCollection, err)
 	})
@@ -169,14 +210,14 @@ func TestGet(t *testing.T) {
 
 	t.Run("Get() returns the collection items", func(t *testing.T) {
 		data := models.SampleGetListsResultDto()
-		testMongoCollection.On("Find", &[]models.GetListsResultDto{}, nil, bson.M{"name": 1}).Return(nil).Once().Run(func(args mock.Arguments) {
+		testMongoCollection.On("Find", &[]models.GetListsResultDto{}, bson.M{}, bson.M{"_id": 1, "name": 1}, []string(nil), 0).Return(nil).Once().Run(func(args mock.Arguments) {
 			arg := args.Get(0).(*[]models.GetListsResultDto)
 			*arg = data
 		})
 
 		want := data
 		got := []models.GetListsResultDto{}
-		err := repository.Get(&got, nil, bson.M{"name": 1})
+		err := repository.Get(&got, Query{Projection: Fields("name")})
 
 		assert.Equal(t, want, got, "they should be equal")
 
@@ -184,15 +225,112 @@ func TestGet(t *testing.T) {
 	})
 
 	t.Run("Get() returns an error when the query fails", func(t *testing.T) {
-		testMongoCollection.On("Find", &[]models.GetListsResultDto{}, nil, bson.M{"name": 1}).Return(errors.New("wadus")).Once()
+		testMongoCollection.On("Find", &[]models.GetListsResultDto{}, bson.M{}, bson.M{"_id": 1, "name": 1}, []string(nil), 0).Return(errors.New("wadus")).Once()
 
 		r := []models.GetListsResultDto{}
-		err := repository.Get(&r, nil, bson.M{"name": 1})
+		err := repository.Get(&r, Query{Projection: Fields("name")})
+
+		assertFailedOperation(t, testMongoCollection, err, "Error retrieving from the database")
+	})
+
+	t.Run("Get() sends the translated query", func(t *testing.T) {
+		query := bson.M{"$and": []bson.M{{"userId": "user1"}, {"name": bson.M{"$in": []interface{}{"a", "b"}}}}}
+		testMongoCollection.On("Find", &[]models.List{}, query, bson.M(nil), []string{"-name"}, 5).Return(nil).Once()
+
+		r := []models.List{}
+		err := repository.Get(&r, Query{
+			Filter: And(Eq("userId", "user1"), In("name", "a", "b")),
+			Sort:   []Sort{Desc("name")},
+			Limit:  5,
+		})
+
+		assertSuccededOperation(t, testMongoCollection, err)
+	})
+
+	t.Run("Get() returns an error when the filter is not supported", func(t *testing.T) {
+		r := []models.List{}
+		err := repository.Get(&r, Query{Filter: Filter{Op: "wadus"}})
+
+		assert.IsType(t, &appErrors.UnexpectedError{}, err)
 
 		assertFailedOperation(t, testMongoCollection, err, "Error retrieving from the database")
 	})
 }
 
+func TestCount(t *testing.T) {
+	testMongoCollection := new(MockedMongoCollection)
+
+	repository := MongoRepository{testMongoCollection}
+
+	t.Run("Count() returns the number of documents", func(t *testing.T) {
+		query := bson.M{"$and": []bson.M{{"userId": "user1"}, {"name": bson.RegEx{Pattern: `a\.b`, Options: "i"}}}}
+		testMongoCollection.On("Count", query).Return(2, nil).Once()
+
+		n, err := repository.Count(And(Eq("userId", "user1"), Contains("name", "a.b")))
+
+		assert.Equal(t, 2, n)
+
+		assertSuccededOperation(t, testMongoCollection, err)
+	})
+
+	t.Run("Count() returns an error when the count fails", func(t *testing.T) {
+		testMongoCollection.On("Count", bson.M{}).Return(0, errors.New("wadus")).Once()
+
+		_, err := repository.Count(All())
+
+		assert.IsType(t, &appErrors.UnexpectedError{}, err)
+
+		assertFailedOperation(t, testMongoCollection, err, "Error counting in the database")
+	})
+}
+
+func TestModify(t *testing.T) {
+	testMongoCollection := new(MockedMongoCollection)
+
+	repository := MongoRepository{testMongoCollection}
+
+	t.Run("Modify() sends the translated update", func(t *testing.T) {
+		update := bson.M{"$inc": bson.M{"value": 1}, "$set": bson.M{"name": "wadus"}}
+		testMongoCollection.On("Update", bson.M{"name": "requests"}, update).Return(nil).Once()
+
+		err := repository.Modify(Eq("name", "requests"), Inc("value", 1), Set("name", "wadus"))
+
+		assertSuccededOperation(t, testMongoCollection, err)
+	})
+
+	t.Run("Modify() translates the array modifications", func(t *testing.T) {
+		query := bson.M{"$and": []bson.M{{"_id": "id"}, {"items": bson.M{"$elemMatch": bson.M{"id": "i1"}}}}}
+		update := bson.M{
+			"$set":  bson.M{"items.$[e0].title": "new", "items.$[e1].done": false},
+			"$push": bson.M{"items": models.Item{ID: "i2"}},
+			"$pull": bson.M{"items": bson.M{"id": "i3"}},
+		}
+		arrayFilters := []interface{}{bson.M{"e0.id": "i1"}, bson.M{"$and": []bson.M{{"e1.done": true}, {"e1.archived": bson.M{"$ne": true}}}}}
+		testMongoCollection.On("UpdateWithArrayFilters", query, update, arrayFilters).Return(nil).Once()
+
+		err := repository.Modify(
+			And(Eq(IDField, "id"), ElemMatch("items", Eq("id", "i1"))),
+			SetElement("items", Eq("id", "i1"), map[string]interface{}{"title": "new"}),
+			SetElement("items", And(Eq("done", true), Ne("archived", true)), map[string]interface{}{"done": false}),
+			Push("items", models.Item{ID: "i2"}),
+			Pull("items", Eq("id", "i3")),
+		)
+
+		assertSuccededOperation(t, testMongoCollection, err)
+	})
+
+	t.Run("Modify() returns a not found error when document does not exits", func(t *testing.T) {
+		testMongoCollection.On("Update", bson.M{"name": "requests"}, bson.M{"$inc": bson.M{"value": 1}}).Return(errors.New("not found")).Once()
+		testMongoCollection.On("Name").Return("document").Once()
+
+		err := repository.Modify(Eq("name", "requests"), Inc("value", 1))
+
+		assert.IsType(t, &appErrors.NotFoundError{}, err)
+
+		assertFailedOperation(t, testMongoCollection, err, "document not found")
+	})
+}
+
 func TestGetOne(t *testing.T) {
 	testMongoCollection := new(MockedMongoCollection)
 
@@ -203,14 +341,14 @@ func TestGetOne(t *testing.T) {
 			ID:   "id",
 			Name: "list1",
 		}
-		testMongoCollection.On("FindOne", &models.List{}, nil, bson.M{"name": "list1"}).Return(nil).Once().Run(func(args mock.Arguments) {
+		testMongoCollection.On("FindOne", &models.List{}, bson.M{"name": "list1"}, bson.M(nil), []string(nil)).Return(nil).Once().Run(func(args mock.Arguments) {
 			arg := args.Get(0).(*models.List)
 			*arg = data
 		})
 
 		want := data
 		got := models.List{}
-		err := repository.GetOne(&got, nil, bson.M{"name": "list1"})
+		err := repository.GetOne(&got, Query{Filter: Eq("name", "list1")})
 
 		assert.Equal(t, want, got, "they should be equal")
 
@@ -218,10 +356,10 @@ func TestGetOne(t *testing.T) {
 	})
 
 	t.Run("GetOne() returns an error when the query fails", func(t *testing.T) {
-		testMongoCollection.On("FindOne", &models.List{}, nil, bson.M{"name": "1"}).Return(errors.New("wadus")).Once()
+		testMongoCollection.On("FindOne", &models.List{}, bson.M{"name": "1"}, bson.M(nil), []string(nil)).Return(errors.New("wadus")).Once()
 
 		r := models.List{}
-		err := repository.GetOne(&r, nil, bson.M{"name": "1"})
+		err := repository.GetOne(&r, Query{Filter: Eq("name", "1")})
 
 		assert.IsType(t, &appErrors.UnexpectedError{}, err)
 
@@ -229,11 +367,11 @@ func TestGetOne(t *testing.T) {
 	})
 
 	t.Run("GetOne() returns a not found error when document does not exits", func(t *testing.T) {
-		testMongoCollection.On("FindOne", &models.List{}, nil, bson.M{"name": "1"}).Return(errors.New("not found")).Once()
+		testMongoCollection.On("FindOne", &models.List{}, bson.M{"name": "1"}, bson.M(nil), []string(nil)).Return(errors.New("not found")).Once()
 		testMongoCollection.On("Name").Return("document").Once()
 
 		r := models.List{}
-		err := repository.GetOne(&r, nil, bson.M{"name": "1"})
+		err := repository.GetOne(&r, Query{Filter: Eq("name", "1")})
 
 		assert.IsType(t, &appErrors.NotFoundError{}, err)
 
@@ -283,3 +421,22 @@ func sampleList() models.List {
 	l.ID = bson.NewObjectId().Hex()
 	return l
 }
+
+func TestWithItemIDs(t *testing.T) {
+	items := []bson.D{
+		{{Name: "title", Value: "without id"}},
+		{{Name: "id", Value: ""}, {Name: "title", Value: "empty id"}},
+		{{Name: "id", Value: "5e8f8f8f8f8f8f8f8f8f8f8f"}, {Name: "title", Value: "with id"}},
+	}
+
+	got := withItemIDs(items)
+
+	assert.Equal(t, "id", got[0][0].Name)
+	assert.True(t, bson.IsObjectIdHex(got[0][0].Value.(string)))
+	assert.Equal(t, bson.DocElem{Name: "title", Value: "without id"}, got[0][1])
+
+	assert.True(t, bson.IsObjectIdHex(got[1][0].Value.(string)))
+	assert.Equal(t, "", items[1][0].Value, "the items shouldn't be changed")
+
+	assert.Equal(t, items[2], got[2])
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // MongoSession is the interface used to retrieve the mongo collection
@@ -34,6 +35,10 @@ func NewMyMongoSession(mongoUri string) *MyMongoSession {
 
 	log.Println("Connected with lists mongo database.")
 
+	if err = migrate(s.DB(databaseName)); err != nil {
+		panic(err)
+	}
+
 	return &MyMongoSession{
 		session:      s,
 		databaseName: databaseName,
@@ -47,3 +52,79 @@ func (s *MyMongoSession) GetRepository(collectionName string) Repository {
 	mc := NewMyMongoCollection(c)
 	return &MongoRepository{mc}
 }
+
+// migrate updates the documents saved before adding new fields with a default value and
+// adds the indexes
+func migrate(db *mgo.Database) error {
+	users := db.C("users")
+
+	if _, err := users.UpdateAll(bson.M{"isActive": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"isActive": true}}); err != nil {
+		return err
+	}
+
+	// the isAdmin field has been replaced by the role
+	if _, err := users.UpdateAll(bson.M{"role": bson.M{"$exists": false}, "isAdmin": true}, bson.M{"$set": bson.M{"role": "admin"}}); err != nil {
+		return err
+	}
+
+	if _, err := users.UpdateAll(bson.M{"role": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"role": "user"}}); err != nil {
+		return err
+	}
+
+	if _, err := users.UpdateAll(bson.M{"isAdmin": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"isAdmin": ""}}); err != nil {
+		return err
+	}
+
+	if err := addMissingItemIDs(db.C("lists")); err != nil {
+		return err
+	}
+
+	// the failures of each key are upserted, which needs the key to be unique
+	return db.C("loginAttempts").EnsureIndex(mgo.Index{Key: []string{"key"}, Unique: true})
+}
+
+// addMissingItemIDs gives an id to the list items saved before the items had one. A list
+// changed while doing it is skipped and its items get their ids on the next start.
+func addMissingItemIDs(lists *mgo.Collection) error {
+	withoutID := bson.M{"$or": []bson.M{{"id": bson.M{"$exists": false}}, {"id": ""}}}
+	iter := lists.Find(bson.M{"items": bson.M{"$elemMatch": withoutID}}).Select(bson.M{"items": 1}).Iter()
+
+	// the items are read as ordered documents, so they can be compared when updating them
+	l := struct {
+		ID    interface{} `bson:"_id"`
+		Items []bson.D    `bson:"items"`
+	}{}
+	for iter.Next(&l) {
+		err := lists.Update(bson.M{"_id": l.ID, "items": l.Items}, bson.M{"$set": bson.M{"items": withItemIDs(l.Items)}})
+		if err != nil && err != mgo.ErrNotFound {
+			iter.Close()
+			return err
+		}
+	}
+
+	return iter.Close()
+}
+
+// withItemIDs returns a copy of the items where the ones without an id have a new one
+func withItemIDs(items []bson.D) []bson.D {
+	r := make([]bson.D, len(items))
+	for i, item := range items {
+		r[i] = item
+
+		idIndex := -1
+		for j, e := range item {
+			if e.Name == "id" {
+				idIndex = j
+			}
+		}
+
+		if idIndex == -1 {
+			r[i] = append(bson.D{{Name: "id", Value: NewID()}}, item...)
+		} else if id, _ := item[idIndex].Value.(string); id == "" {
+			r[i] = append(bson.D{}, item...)
+			r[i][idIndex].Value = NewID()
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,184 @@
+package stores
+
+// IDField is the name of the field which contains the document id
+const IDField = "_id"
+
+// FilterOp is the operation a Filter applies
+type FilterOp string
+
+// The filter operations. The comparisons compare the value of the field with
+// the Filter value, Contains looks for the value in a string field ignoring the
+// case, ElemMatch matches an array field with any element matching the first of
+// the Filter filters and And and Or combine the Filter filters.
+const (
+	EqOp        FilterOp = "eq"
+	NeOp        FilterOp = "ne"
+	InOp        FilterOp = "in"
+	GtOp        FilterOp = "gt"
+	GteOp       FilterOp = "gte"
+	LtOp        FilterOp = "lt"
+	LteOp       FilterOp = "lte"
+	ContainsOp  FilterOp = "contains"
+	ElemMatchOp FilterOp = "elemMatch"
+	AndOp       FilterOp = "and"
+	OrOp        FilterOp = "or"
+)
+
+// Filter is a storage neutral condition over the fields of a document.
+// The zero value matches every document.
+type Filter struct {
+	Op      FilterOp
+	Field   string
+	Value   interface{}
+	Filters []Filter
+}
+
+// All returns a filter which matches every document
+func All() Filter {
+	return Filter{}
+}
+
+// Eq returns a filter which matches the documents whose field is equal to the value
+func Eq(field string, value interface{}) Filter {
+	return Filter{Op: EqOp, Field: field, Value: value}
+}
+
+// Ne returns a filter which matches the documents whose field is not equal to the value
+func Ne(field string, value interface{}) Filter {
+	return Filter{Op: NeOp, Field: field, Value: value}
+}
+
+// In returns a filter which matches the documents whose field is equal to any of the values
+func In(field string, values ...interface{}) Filter {
+	return Filter{Op: InOp, Field: field, Value: values}
+}
+
+// Gt returns a filter which matches the documents whose field is greater than the value
+func Gt(field string, value interface{}) Filter {
+	return Filter{Op: GtOp, Field: field, Value: value}
+}
+
+// Gte returns a filter which matches the documents whose field is greater than or equal to the value
+func Gte(field string, value interface{}) Filter {
+	return Filter{Op: GteOp, Field: field, Value: value}
+}
+
+// Lt returns a filter which matches the documents whose field is less than the value
+func Lt(field string, value interface{}) Filter {
+	return Filter{Op: LtOp, Field: field, Value: value}
+}
+
+// Lte returns a filter which matches the documents whose field is less than or equal to the value
+func Lte(field string, value interface{}) Filter {
+	return Filter{Op: LteOp, Field: field, Value: value}
+}
+
+// Contains returns a filter which matches the documents whose field contains the
+// text ignoring the case
+func Contains(field string, text string) Filter {
+	return Filter{Op: ContainsOp, Field: field, Value: text}
+}
+
+// ElemMatch returns a filter which matches the documents whose array field contains
+// an element matching the filter
+func ElemMatch(field string, filter Filter) Filter {
+	return Filter{Op: ElemMatchOp, Field: field, Filters: []Filter{filter}}
+}
+
+// And returns a filter which matches the documents that match all the filters
+func And(filters ...Filter) Filter {
+	return Filter{Op: AndOp, Filters: filters}
+}
+
+// Or returns a filter which matches the documents that match any of the filters
+func Or(filters ...Filter) Filter {
+	return Filter{Op: OrOp, Filters: filters}
+}
+
+// IsAll returns true if the filter matches every document
+func (f Filter) IsAll() bool {
+	return f.Op == ""
+}
+
+// Projection contains the fields to retrieve. The id is always retrieved and a
+// nil projection retrieves all the fields.
+type Projection []string
+
+// Fields returns a projection with the given fields
+func Fields(fields ...string) Projection {
+	return Projection(fields)
+}
+
+// Sort is the order applied to a field when retrieving documents
+type Sort struct {
+	Field      string
+	Descending bool
+}
+
+// Asc returns an ascending sort by the field
+func Asc(field string) Sort {
+	return Sort{Field: field}
+}
+
+// Desc returns a descending sort by the field
+func Desc(field string) Sort {
+	return Sort{Field: field, Descending: true}
+}
+
+// Query describes the documents to retrieve. A zero Limit retrieves all the
+// matching documents.
+type Query struct {
+	Filter     Filter
+	Projection Projection
+	Sort       []Sort
+	Limit      int
+}
+
+// ModificationOp is the operation a Modification applies
+type ModificationOp string
+
+// The modification operations. Push, Pull and SetElement change the elements of
+// an array field.
+const (
+	SetOp        ModificationOp = "set"
+	IncOp        ModificationOp = "inc"
+	PushOp       ModificationOp = "push"
+	PullOp       ModificationOp = "pull"
+	SetElementOp ModificationOp = "setElement"
+)
+
+// Modification is a change applied to a field of a document. Match selects the
+// elements of the array field changed by Pull and SetElement.
+type Modification struct {
+	Op    ModificationOp
+	Field string
+	Value interface{}
+	Match Filter
+}
+
+// Set returns a modification which sets the field to the value
+func Set(field string, value interface{}) Modification {
+	return Modification{Op: SetOp, Field: field, Value: value}
+}
+
+// Inc returns a modification which increments the field by the value
+func Inc(field string, value int) Modification {
+	return Modification{Op: IncOp, Field: field, Value: value}
+}
+
+// Push returns a modification which appends the value to the array field
+func Push(field string, value interface{}) Modification {
+	return Modification{Op: PushOp, Field: field, Value: value}
+}
+
+// Pull returns a modification which removes the elements of the array field that
+// match the filter
+func Pull(field string, match Filter) Modification {
+	return Modification{Op: PullOp, Field: field, Match: match}
+}
+
+// SetElement returns a modification which sets the given fields of the elements of
+// the array field that match the filter
+func SetElement(field string, match Filter, values map[string]interface{}) Modification {
+	return Modification{Op: SetElementOp, Field: field, Value: values, Match: match}
+}
@@ -0,0 +1,219 @@
+package stores
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// zeroTimeMillis is the value stored for the zero time
+const zeroTimeMillis = -62135596800000
+
+var relationalOperators = map[FilterOp]string{
+	EqOp:  "=",
+	NeOp:  "<>",
+	GtOp:  ">",
+	GteOp: ">=",
+	LtOp:  "<",
+	LteOp: "<=",
+}
+
+// translateFilter returns the sql condition equivalent to the filter and its arguments
+func translateFilter(t relationalTable, f Filter) (string, []interface{}, error) {
+	switch f.Op {
+	case "":
+		return "1 = 1", nil, nil
+	case AndOp, OrOp:
+		return translateLogical(t, f)
+	case ElemMatchOp:
+		return translateElemMatch(t, f)
+	}
+
+	c, ok := t.column(f.Field)
+	if !ok {
+		return "", nil, fmt.Errorf("the field %q can't be queried", f.Field)
+	}
+
+	if op, ok := relationalOperators[f.Op]; ok {
+		return fmt.Sprintf("%v %v ?", c.column, op), []interface{}{toFilterValue(c, f.Value)}, nil
+	}
+
+	switch f.Op {
+	case InOp:
+		values, _ := f.Value.([]interface{})
+		if len(values) == 0 {
+			return "1 = 0", nil, nil
+		}
+		args := []interface{}{}
+		for _, v := range values {
+			args = append(args, toFilterValue(c, v))
+		}
+		return fmt.Sprintf("%v IN (%v)", c.column, placeholders(len(values))), args, nil
+	case ContainsOp:
+		text, _ := f.Value.(string)
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(text)) + "%"
+		return fmt.Sprintf(`LOWER(%v) LIKE ? ESCAPE '\'`, c.column), []interface{}{pattern}, nil
+	}
+
+	return "", nil, fmt.Errorf("unsupported filter operation %q", f.Op)
+}
+
+// translateElemMatch returns the condition which looks for the matching element in the child table
+func translateElemMatch(t relationalTable, f Filter) (string, []interface{}, error) {
+	child, ok := t.child(f.Field)
+	if !ok {
+		return "", nil, fmt.Errorf("the field %q is not an array", f.Field)
+	}
+
+	where, args, err := translateFilter(child.table(), f.Filters[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("id IN (SELECT %v FROM %v WHERE %v)", child.parentColumn, child.name, where), args, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func translateLogical(t relationalTable, f Filter) (string, []interface{}, error) {
+	if len(f.Filters) == 0 {
+		if f.Op == OrOp {
+			return "1 = 0", nil, nil
+		}
+		return "1 = 1", nil, nil
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+
+	for _, sf := range f.Filters {
+		c, a, err := translateFilter(t, sf)
+		if err != nil {
+			return "", nil, err
+		}
+
+		conditions = append(conditions, "("+c+")")
+		args = append(args, a...)
+	}
+
+	return strings.Join(conditions, " "+strings.ToUpper(string(f.Op))+" "), args, nil
+}
+
+// translateSort returns the sql order by clause for the sort
+func translateSort(t relationalTable, sort []Sort) (string, error) {
+	fields := []string{}
+	for _, s := range sort {
+		c, ok := t.column(s.Field)
+		if !ok {
+			return "", fmt.Errorf("the field %q can't be sorted", s.Field)
+		}
+
+		if s.Descending {
+			fields = append(fields, c.column+" DESC")
+		} else {
+			fields = append(fields, c.column)
+		}
+	}
+
+	return strings.Join(append(fields, "id"), ", "), nil
+}
+
+// translateModifications returns the sql assignments for the modifications
+func translateModifications(t relationalTable, modifications []Modification) (string, []interface{}, error) {
+	assignments := []string{}
+	args := []interface{}{}
+
+	for _, m := range modifications {
+		c, ok := t.column(m.Field)
+		if !ok || m.Field == IDField {
+			return "", nil, fmt.Errorf("the field %q can't be modified", m.Field)
+		}
+
+		switch m.Op {
+		case SetOp:
+			assignments = append(assignments, c.column+" = ?")
+			args = append(args, toColumnValue(c, m.Value))
+		case IncOp:
+			assignments = append(assignments, fmt.Sprintf("%v = %v + ?", c.column, c.column))
+			args = append(args, m.Value)
+		default:
+			return "", nil, fmt.Errorf("unsupported modification %q", m.Op)
+		}
+	}
+
+	return strings.Join(assignments, ", "), args, nil
+}
+
+// translateElementValues returns the sql assignments for the values set by a SetElement modification
+func translateElementValues(child relationalChildTable, values map[string]interface{}) (string, []interface{}, error) {
+	fields := []string{}
+	for f := range values {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	assignments := []string{}
+	args := []interface{}{}
+	for _, f := range fields {
+		c, ok := child.table().column(f)
+		if !ok {
+			return "", nil, fmt.Errorf("the field %q can't be modified", f)
+		}
+
+		assignments = append(assignments, c.column+" = ?")
+		args = append(args, toColumnValue(c, values[f]))
+	}
+
+	return strings.Join(assignments, ", "), args, nil
+}
+
+// toColumnValue returns the value to store in the column for the document value
+func toColumnValue(c relationalColumn, v interface{}) interface{} {
+	switch c.kind {
+	case boolColumn:
+		b, _ := v.(bool)
+		return b
+	case timeColumn:
+		t, _ := v.(time.Time)
+		if t.IsZero() {
+			return int64(zeroTimeMillis)
+		}
+		return t.Unix()*1000 + int64(t.Nanosecond()/int(time.Millisecond))
+	case intColumn:
+		switch n := v.(type) {
+		case int:
+			return int64(n)
+		case int64:
+			return n
+		case float64:
+			return int64(n)
+		}
+		return int64(0)
+	default:
+		s, _ := v.(string)
+		return s
+	}
+}
+
+// toFilterValue returns the value to compare with the column in a filter
+func toFilterValue(c relationalColumn, v interface{}) interface{} {
+	if c.kind == timeColumn {
+		return toColumnValue(c, v)
+	}
+
+	return v
+}
+
+// fromTimeColumn returns the time stored in a time column
+func fromTimeColumn(ms int64) time.Time {
+	if ms == zeroTimeMillis {
+		return time.Time{}
+	}
+
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,597 @@
+package stores
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// RelationalRepository is the store which uses a sql database
+type RelationalRepository struct {
+	session        *MyRelationalSession
+	collectionName string
+	table          relationalTable
+}
+
+// maxLockAttempts is how many times a document is looked for again when it changes before
+// it is locked
+const maxLockAttempts = 5
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Get returns several items from a collection
+func (s *RelationalRepository) Get(doc interface{}, query Query) error {
+	docs, err := s.find(s.session.db, query)
+	if err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
+	}
+
+	if err := decodeDocuments(docs, doc); err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
+	}
+
+	return nil
+}
+
+// GetOne returns a single item
+func (s *RelationalRepository) GetOne(doc interface{}, query Query) error {
+	query.Limit = 1
+	docs, err := s.find(s.session.db, query)
+	if err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
+	}
+
+	if len(docs) == 0 {
+		return &appErrors.NotFoundError{Model: s.collectionName}
+	}
+
+	if err := decodeDocument(docs[0], doc); err != nil {
+		return s.unexpectedError("Error retrieving from the database", err)
+	}
+
+	return nil
+}
+
+// Count returns the number of documents which match the filter
+func (s *RelationalRepository) Count(filter Filter) (int, error) {
+	where, args, err := translateFilter(s.table, filter)
+	if err != nil {
+		return 0, s.unexpectedError("Error counting in the database", err)
+	}
+
+	var n int
+	stmt := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE %v", s.table.name, where)
+	if err := s.session.db.QueryRow(s.session.rebind(stmt), args...).Scan(&n); err != nil {
+		return 0, s.unexpectedError("Error counting in the database", err)
+	}
+
+	return n, nil
+}
+
+// Add adds a new document to the collection
+func (s *RelationalRepository) Add(doc interface{}) (string, error) {
+	id := NewID()
+	reflect.ValueOf(doc).Elem().FieldByName("ID").SetString(id)
+
+	d, err := toDocument(doc)
+	if err != nil {
+		return "", s.unexpectedError("Error inserting in the database", err)
+	}
+
+	err = s.inTransaction(func(tx *sql.Tx) error {
+		return s.insert(tx, d)
+	})
+	if err != nil {
+		return "", s.unexpectedError("Error inserting in the database", err)
+	}
+
+	return id, nil
+}
+
+// Update replaces a document
+func (s *RelationalRepository) Update(filter Filter, doc interface{}) error {
+	d, err := toDocument(doc)
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	return s.update(filter, func(tx *sql.Tx, id string) error {
+		d[IDField] = id
+		if err := s.delete(tx, id); err != nil {
+			return err
+		}
+		return s.insert(tx, d)
+	})
+}
+
+// Modify applies the modifications to a document
+func (s *RelationalRepository) Modify(filter Filter, modifications ...Modification) error {
+	return s.update(filter, func(tx *sql.Tx, id string) error {
+		return s.modify(tx, id, modifications)
+	})
+}
+
+// Upsert applies the modifications to the first document which matches the filter, or adds
+// the document with them when there isn't any, and reads the result into the document.
+// The filter should be on unique columns, so the concurrent calls can't add two documents.
+func (s *RelationalRepository) Upsert(filter Filter, doc interface{}, modifications ...Modification) error {
+	reflect.ValueOf(doc).Elem().FieldByName("ID").SetString(NewID())
+	d, err := toDocument(doc)
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	var result bson.M
+	err = s.inTransaction(func(tx *sql.Tx) error {
+		id, err := s.lockID(tx, filter)
+		if err != nil {
+			return err
+		}
+
+		if id == "" {
+			if err := s.insertIgnoringConflicts(tx, d); err != nil {
+				return err
+			}
+
+			// the document added by another transaction meanwhile is used instead
+			if id, err = s.lockID(tx, filter); err != nil {
+				return err
+			}
+			if id == "" {
+				return errors.New("the new document doesn't match the filter")
+			}
+		}
+
+		if err := s.modify(tx, id, modifications); err != nil {
+			return err
+		}
+
+		docs, err := s.find(tx, Query{Filter: Eq(IDField, id)})
+		if err != nil {
+			return err
+		}
+		result = docs[0]
+
+		return nil
+	})
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	if err := decodeDocument(result, doc); err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	return nil
+}
+
+// Remove removes a document from the collection
+func (s *RelationalRepository) Remove(filter Filter) error {
+	found := true
+	err := s.inTransaction(func(tx *sql.Tx) error {
+		id, err := s.lockID(tx, filter)
+		if err != nil || id == "" {
+			found = false
+			return err
+		}
+
+		return s.delete(tx, id)
+	})
+
+	if err != nil {
+		return s.unexpectedError("Error removing from the database", err)
+	}
+
+	if !found {
+		return &appErrors.NotFoundError{Model: s.collectionName}
+	}
+
+	return nil
+}
+
+// IsValidID returns true if the id is valid
+func (s *RelationalRepository) IsValidID(id string) bool {
+	return bson.IsObjectIdHex(id)
+}
+
+// update runs the change in a transaction for the first document which matches the filter
+func (s *RelationalRepository) update(filter Filter, change func(tx *sql.Tx, id string) error) error {
+	found := true
+	err := s.inTransaction(func(tx *sql.Tx) error {
+		id, err := s.lockID(tx, filter)
+		if err != nil || id == "" {
+			found = false
+			return err
+		}
+
+		return change(tx, id)
+	})
+
+	if err != nil {
+		return s.unexpectedError("Error updating the database", err)
+	}
+
+	if !found {
+		return &appErrors.NotFoundError{Model: s.collectionName}
+	}
+
+	return nil
+}
+
+// find returns the documents which match the query, including their array fields
+func (s *RelationalRepository) find(q queryer, query Query) ([]bson.M, error) {
+	where, args, err := translateFilter(s.table, query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy, err := translateSort(s.table, query.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := []string{}
+	for _, c := range s.table.columns {
+		columns = append(columns, c.column)
+	}
+
+	stmt := fmt.Sprintf("SELECT %v FROM %v WHERE %v ORDER BY %v", strings.Join(columns, ", "), s.table.name, where, orderBy)
+	if query.Limit > 0 {
+		stmt += fmt.Sprintf(" LIMIT %v", query.Limit)
+	}
+
+	rows, err := q.Query(s.session.rebind(stmt), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := scanDocuments(rows, s.table.columns, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(docs) == 0 {
+		return docs, nil
+	}
+
+	ids := []interface{}{}
+	byID := map[interface{}]bson.M{}
+	for _, d := range docs {
+		ids = append(ids, d[IDField])
+		byID[d[IDField]] = d
+	}
+
+	for _, child := range s.table.children {
+		for _, d := range docs {
+			d[child.field] = []interface{}{}
+		}
+
+		columns := []string{child.parentColumn}
+		for _, c := range child.columns {
+			columns = append(columns, c.column)
+		}
+
+		stmt := fmt.Sprintf("SELECT %v FROM %v WHERE %v IN (%v) ORDER BY %v, %v",
+			strings.Join(columns, ", "), child.name, child.parentColumn, placeholders(len(ids)), child.parentColumn, positionColumn)
+
+		rows, err := q.Query(s.session.rebind(stmt), ids...)
+		if err != nil {
+			return nil, err
+		}
+
+		elements, err := scanDocuments(rows, child.columns, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range elements {
+			parent := byID[e["_parent"]]
+			delete(e, "_parent")
+			parent[child.field] = append(parent[child.field].([]interface{}), e)
+		}
+	}
+
+	for i, d := range docs {
+		docs[i] = projectDocument(d, query.Projection)
+	}
+
+	return docs, nil
+}
+
+// findID returns the id of the first document which matches the filter or an empty string
+func (s *RelationalRepository) findID(tx *sql.Tx, filter Filter) (string, error) {
+	where, args, err := translateFilter(s.table, filter)
+	if err != nil {
+		return "", err
+	}
+
+	stmt := fmt.Sprintf("SELECT id FROM %v WHERE %v ORDER BY id LIMIT 1", s.table.name, where)
+
+	var id string
+	err = tx.QueryRow(s.session.rebind(stmt), args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return id, err
+}
+
+// lockID returns the id of the first document which matches the filter and locks it until
+// the transaction ends, so another transaction can't change it before it is updated or
+// removed. The sqlite transactions don't need it because they use a single connection.
+func (s *RelationalRepository) lockID(tx *sql.Tx, filter Filter) (string, error) {
+	for i := 0; i < maxLockAttempts; i++ {
+		id, err := s.findID(tx, filter)
+		if err != nil || id == "" || !s.session.postgres {
+			return id, err
+		}
+
+		stmt := fmt.Sprintf("SELECT id FROM %v WHERE id = ? FOR UPDATE", s.table.name)
+		if _, err := tx.Exec(s.session.rebind(stmt), id); err != nil {
+			return "", err
+		}
+
+		// the document could have been changed before it was locked, so the filter is
+		// checked again with a new statement, which sees the committed changes
+		matches, err := s.matchesID(tx, id, filter)
+		if err != nil || matches {
+			return id, err
+		}
+	}
+
+	return "", errors.New("the document has been changed by other transactions while it was locked")
+}
+
+// matchesID returns true when the document with the id matches the filter
+func (s *RelationalRepository) matchesID(tx *sql.Tx, id string, filter Filter) (bool, error) {
+	where, args, err := translateFilter(s.table, filter)
+	if err != nil {
+		return false, err
+	}
+
+	var n int
+	stmt := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE id = ? AND (%v)", s.table.name, where)
+	err = tx.QueryRow(s.session.rebind(stmt), append([]interface{}{id}, args...)...).Scan(&n)
+
+	return n > 0, err
+}
+
+// modify applies the modifications to the document with the id
+func (s *RelationalRepository) modify(tx *sql.Tx, id string, modifications []Modification) error {
+	columnModifications := []Modification{}
+	childModifications := []Modification{}
+	for _, m := range modifications {
+		if _, isChild := s.table.child(m.Field); isChild {
+			childModifications = append(childModifications, m)
+		} else {
+			columnModifications = append(columnModifications, m)
+		}
+	}
+
+	assignments, args, err := translateModifications(s.table, columnModifications)
+	if err != nil {
+		return err
+	}
+
+	if len(assignments) > 0 {
+		stmt := fmt.Sprintf("UPDATE %v SET %v WHERE id = ?", s.table.name, assignments)
+		if _, err := tx.Exec(s.session.rebind(stmt), append(args, id)...); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range childModifications {
+		if err := s.modifyChild(tx, id, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertIgnoringConflicts adds the document unless another transaction has added one with
+// the same unique columns. The documents with array fields are always added.
+func (s *RelationalRepository) insertIgnoringConflicts(tx *sql.Tx, d bson.M) error {
+	if len(s.table.children) > 0 {
+		return s.insert(tx, d)
+	}
+
+	names, args := rowValues(s.table.columns, d, nil)
+	stmt := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v) ON CONFLICT DO NOTHING", s.table.name, strings.Join(names, ", "), placeholders(len(names)))
+	_, err := tx.Exec(s.session.rebind(stmt), args...)
+
+	return err
+}
+
+func (s *RelationalRepository) insert(tx *sql.Tx, d bson.M) error {
+	if err := s.insertRow(tx, s.table.name, s.table.columns, d, nil); err != nil {
+		return err
+	}
+
+	for _, child := range s.table.children {
+		elements, _ := d[child.field].([]interface{})
+		for i, e := range elements {
+			ed, isDoc := e.(bson.M)
+			if !isDoc {
+				return fmt.Errorf("the elements of %q must be documents", child.field)
+			}
+
+			extra := map[string]interface{}{
+				child.parentColumn: d[IDField],
+				positionColumn:     i,
+			}
+			if err := s.insertRow(tx, child.name, child.columns, ed, extra); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *RelationalRepository) insertRow(tx *sql.Tx, table string, columns []relationalColumn, d bson.M, extra map[string]interface{}) error {
+	names, args := rowValues(columns, d, extra)
+	stmt := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", table, strings.Join(names, ", "), placeholders(len(names)))
+	_, err := tx.Exec(s.session.rebind(stmt), args...)
+
+	return err
+}
+
+// rowValues returns the columns and the values of the row of a document
+func rowValues(columns []relationalColumn, d bson.M, extra map[string]interface{}) ([]string, []interface{}) {
+	names := []string{}
+	args := []interface{}{}
+
+	for name, v := range extra {
+		names = append(names, name)
+		args = append(args, v)
+	}
+
+	for _, c := range columns {
+		names = append(names, c.column)
+		args = append(args, toColumnValue(c, d[c.field]))
+	}
+
+	return names, args
+}
+
+// modifyChild applies a modification of an array field to the rows of its child table
+func (s *RelationalRepository) modifyChild(tx *sql.Tx, id string, m Modification) error {
+	child, _ := s.table.child(m.Field)
+
+	switch m.Op {
+	case PushOp:
+		d, err := toDocument(m.Value)
+		if err != nil {
+			return err
+		}
+
+		var position int
+		stmt := fmt.Sprintf("SELECT COALESCE(MAX(%v), -1) + 1 FROM %v WHERE %v = ?", positionColumn, child.name, child.parentColumn)
+		if err := tx.QueryRow(s.session.rebind(stmt), id).Scan(&position); err != nil {
+			return err
+		}
+
+		extra := map[string]interface{}{
+			child.parentColumn: id,
+			positionColumn:     position,
+		}
+		return s.insertRow(tx, child.name, child.columns, d, extra)
+	case PullOp, SetElementOp:
+		where, whereArgs, err := translateFilter(child.table(), m.Match)
+		if err != nil {
+			return err
+		}
+
+		stmt := fmt.Sprintf("DELETE FROM %v WHERE %v = ? AND (%v)", child.name, child.parentColumn, where)
+		args := append([]interface{}{id}, whereArgs...)
+
+		if m.Op == SetElementOp {
+			values, _ := m.Value.(map[string]interface{})
+			assignments, valueArgs, err := translateElementValues(child, values)
+			if err != nil || assignments == "" {
+				return err
+			}
+
+			stmt = fmt.Sprintf("UPDATE %v SET %v WHERE %v = ? AND (%v)", child.name, assignments, child.parentColumn, where)
+			args = append(valueArgs, args...)
+		}
+
+		_, err = tx.Exec(s.session.rebind(stmt), args...)
+		return err
+	}
+
+	return fmt.Errorf("unsupported modification %q", m.Op)
+}
+
+func (s *RelationalRepository) delete(tx *sql.Tx, id string) error {
+	for _, child := range s.table.children {
+		stmt := fmt.Sprintf("DELETE FROM %v WHERE %v = ?", child.name, child.parentColumn)
+		if _, err := tx.Exec(s.session.rebind(stmt), id); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(s.session.rebind(fmt.Sprintf("DELETE FROM %v WHERE id = ?", s.table.name)), id)
+
+	return err
+}
+
+func (s *RelationalRepository) inTransaction(f func(tx *sql.Tx) error) error {
+	tx, err := s.session.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *RelationalRepository) unexpectedError(msg string, err error) error {
+	return &appErrors.UnexpectedError{
+		Msg:           msg,
+		InternalError: err,
+	}
+}
+
+// scanDocuments reads the rows as documents. The first skip columns are stored in the
+// document with the _parent key.
+func scanDocuments(rows *sql.Rows, columns []relationalColumn, skip int) ([]bson.M, error) {
+	defer rows.Close()
+
+	docs := []bson.M{}
+	for rows.Next() {
+		values := make([]interface{}, skip+len(columns))
+		for i := 0; i < skip; i++ {
+			values[i] = new(string)
+		}
+		for i, c := range columns {
+			switch c.kind {
+			case boolColumn:
+				values[skip+i] = new(sql.NullBool)
+			case intColumn, timeColumn:
+				values[skip+i] = new(sql.NullInt64)
+			default:
+				values[skip+i] = new(sql.NullString)
+			}
+		}
+
+		if err := rows.Scan(values...); err != nil {
+			return nil, err
+		}
+
+		d := bson.M{}
+		if skip > 0 {
+			d["_parent"] = *values[0].(*string)
+		}
+		for i, c := range columns {
+			switch v := values[skip+i].(type) {
+			case *sql.NullBool:
+				d[c.field] = v.Bool
+			case *sql.NullInt64:
+				if c.kind == timeColumn {
+					d[c.field] = fromTimeColumn(v.Int64)
+				} else {
+					d[c.field] = v.Int64
+				}
+			case *sql.NullString:
+				d[c.field] = v.String
+			}
+		}
+
+		docs = append(docs, d)
+	}
+
+	return docs, rows.Err()
+}
@@ -0,0 +1,154 @@
+package stores
+
+import (
+	"testing"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRelationalSession() *MyRelationalSession {
+	return NewMyRelationalSession("sqlite3", ":memory:")
+}
+
+func TestRelationalStore(t *testing.T) {
+	t.Run("CRUD", func(t *testing.T) {
+		testRepositoryCRUD(t, newTestRelationalSession())
+	})
+
+	t.Run("queries", func(t *testing.T) {
+		testRepositoryQueries(t, newTestRelationalSession())
+	})
+
+	t.Run("times", func(t *testing.T) {
+		testRepositoryTimes(t, newTestRelationalSession())
+	})
+
+	t.Run("arrays", func(t *testing.T) {
+		testRepositoryArrays(t, newTestRelationalSession())
+	})
+
+	t.Run("counters", func(t *testing.T) {
+		testRepositoryCounters(t, newTestRelationalSession())
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		testRepositoryUpsert(t, newTestRelationalSession())
+	})
+
+	t.Run("stores the list items in order", func(t *testing.T) {
+		repository := newTestRelationalSession().GetRepository("lists")
+
+		lists := models.SampleListSlice()
+		for i := range lists {
+			_, err := repository.Add(&lists[i])
+			assert.Nil(t, err)
+		}
+
+		got := []models.List{}
+		err := repository.Get(&got, Query{})
+
+		assert.Nil(t, err)
+		assert.Equal(t, lists, got)
+
+		lists[0].Items = lists[0].Items[1:]
+		err = repository.Update(Eq(IDField, lists[0].ID), &lists[0])
+		assert.Nil(t, err)
+
+		l := models.List{}
+		err = repository.GetOne(&l, Query{Filter: Eq(IDField, lists[0].ID)})
+
+		assert.Nil(t, err)
+		assert.Equal(t, lists[0], l)
+	})
+
+	t.Run("returns an unexpected error when querying a field without column", func(t *testing.T) {
+		repository := newTestRelationalSession().GetRepository("lists")
+
+		err := repository.Get(&[]models.List{}, Query{Filter: Eq("items", models.Item{Title: "item21"})})
+
+		assert.IsType(t, &appErrors.UnexpectedError{}, err)
+		assert.Equal(t, "Error retrieving from the database", err.Error())
+	})
+
+	t.Run("checks the filter again with the id of the found document", func(t *testing.T) {
+		repository := newTestRelationalSession().GetRepository("lists").(*RelationalRepository)
+
+		l := models.SampleListSlice()[0]
+		_, err := repository.Add(&l)
+		assert.Nil(t, err)
+
+		tx, err := repository.session.db.Begin()
+		assert.Nil(t, err)
+		defer tx.Rollback()
+
+		matches, err := repository.matchesID(tx, l.ID, Eq("name", l.Name))
+		assert.Nil(t, err)
+		assert.True(t, matches)
+
+		matches, err = repository.matchesID(tx, l.ID, Eq("name", "wadus"))
+		assert.Nil(t, err)
+		assert.False(t, matches)
+	})
+
+	t.Run("gives an id to the items saved before the items had one", func(t *testing.T) {
+		session := newTestRelationalSession()
+
+		_, err := session.db.Exec("INSERT INTO lists (id, name, user_id) VALUES ('5e8f8f8f8f8f8f8f8f8f8f8f', 'list', 'user')")
+		assert.Nil(t, err)
+		_, err = session.db.Exec("INSERT INTO list_items (list_id, position, title, description) VALUES ('5e8f8f8f8f8f8f8f8f8f8f8f', 0, 'old', ''), ('5e8f8f8f8f8f8f8f8f8f8f8f', 1, 'older', '')")
+		assert.Nil(t, err)
+
+		assert.Nil(t, session.migrate())
+
+		repository := session.GetRepository("lists")
+		l := models.List{}
+		assert.Nil(t, repository.GetOne(&l, Query{Filter: Eq(IDField, "5e8f8f8f8f8f8f8f8f8f8f8f")}))
+
+		assert.Equal(t, 2, len(l.Items))
+		assert.Equal(t, "old", l.Items[0].Title)
+		assert.True(t, repository.IsValidID(l.Items[0].ID))
+		assert.True(t, repository.IsValidID(l.Items[1].ID))
+		assert.NotEqual(t, l.Items[0].ID, l.Items[1].ID)
+	})
+
+	t.Run("applies the migrations only once", func(t *testing.T) {
+		session := newTestRelationalSession()
+
+		assert.Nil(t, session.migrate())
+
+		var count int
+		err := session.db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&count)
+
+		assert.Nil(t, err)
+		assert.Equal(t, len(relationalMigrations), count)
+	})
+}
+
+func TestRelationalSessionRebind(t *testing.T) {
+	query := "SELECT id FROM lists WHERE id = ? AND user_id IN (?, ?)"
+
+	sqlite := MyRelationalSession{}
+	assert.Equal(t, query, sqlite.rebind(query))
+
+	postgres := MyRelationalSession{postgres: true}
+	assert.Equal(t, "SELECT id FROM lists WHERE id = $1 AND user_id IN ($2, $3)", postgres.rebind(query))
+}
+
+func TestTranslateFilter(t *testing.T) {
+	table := relationalSchema["lists"]
+
+	where, args, err := translateFilter(table, And(
+		Eq("userId", "user1"),
+		Or(Eq("name", "a"), In("name", "b", "c")),
+	))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "(user_id = ?) AND ((name = ?) OR (name IN (?, ?)))", where)
+	assert.Equal(t, []interface{}{"user1", "a", "b", "c"}, args)
+
+	_, _, err = translateFilter(table, Eq("wadus", 1))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,283 @@
+package stores
+
+type columnKind int
+
+const (
+	stringColumn columnKind = iota
+	boolColumn
+	intColumn
+	// timeColumn stores the time as the milliseconds since the unix epoch, the same
+	// precision used by mongo
+	timeColumn
+)
+
+// relationalColumn maps a document field to a table column
+type relationalColumn struct {
+	field  string
+	column string
+	kind   columnKind
+}
+
+// relationalTable describes how the documents of a collection are stored.
+// The first column is always the document id. The array fields of the
+// documents are stored in child tables, one row per element.
+type relationalTable struct {
+	name     string
+	columns  []relationalColumn
+	children []relationalChildTable
+}
+
+// relationalChildTable describes the table used to store an array field
+type relationalChildTable struct {
+	field        string
+	name         string
+	parentColumn string
+	columns      []relationalColumn
+}
+
+const positionColumn = "position"
+
+var relationalSchema = map[string]relationalTable{
+	"users": {
+		name: "users",
+		columns: []relationalColumn{
+			{"_id", "id", stringColumn},
+			{"userName", "user_name", stringColumn},
+			{"passwordHash", "password_hash", stringColumn},
+			{"role", "role", stringColumn},
+			{"isActive", "is_active", boolColumn},
+			{"email", "email", stringColumn},
+			{"emailVerified", "email_verified", boolColumn},
+			{"totpSecret", "totp_secret", stringColumn},
+			{"totpEnabled", "totp_enabled", boolColumn},
+			{"totpLastStep", "totp_last_step", intColumn},
+			{"recoveryCodes", "recovery_codes", stringColumn},
+			{"mustChangePassword", "must_change_password", boolColumn},
+		},
+	},
+	"lists": {
+		name: "lists",
+		columns: []relationalColumn{
+			{"_id", "id", stringColumn},
+			{"name", "name", stringColumn},
+			{"userId", "user_id", stringColumn},
+			{"createdAt", "created_at", timeColumn},
+			{"updatedAt", "updated_at", timeColumn},
+			{"version", "version", intColumn},
+		},
+		children: []relationalChildTable{
+			{
+				field:        "items",
+				name:         "list_items",
+				parentColumn: "list_id",
+				columns: []relationalColumn{
+					{"id", "id", stringColumn},
+					{"title", "title", stringColumn},
+					{"description", "description", stringColumn},
+					{"done", "done", boolColumn},
+					{"doneAt", "done_at", timeColumn},
+					{"archived", "archived", boolColumn},
+					{"createdAt", "created_at", timeColumn},
+					{"updatedAt", "updated_at", timeColumn},
+				},
+			},
+			{
+				field:        "collaborators",
+				name:         "list_collaborators",
+				parentColumn: "list_id",
+				columns: []relationalColumn{
+					{"userId", "user_id", stringColumn},
+					{"userName", "user_name", stringColumn},
+					{"role", "role", stringColumn},
+				},
+			},
+			{
+				field:        "shareLinks",
+				name:         "list_share_links",
+				parentColumn: "list_id",
+				columns: []relationalColumn{
+					{"token", "token", stringColumn},
+					{"createdAt", "created_at", timeColumn},
+					{"expiresAt", "expires_at", timeColumn},
+				},
+			},
+		},
+	},
+	"refreshTokens": {
+		name: "refresh_tokens",
+		columns: []relationalColumn{
+			{"_id", "id", stringColumn},
+			{"userId", "user_id", stringColumn},
+			{"tokenId", "token_id", stringColumn},
+			{"createdAt", "created_at", timeColumn},
+			{"expiresAt", "expires_at", timeColumn},
+		},
+	},
+	"apiKeys": {
+		name: "api_keys",
+		columns: []relationalColumn{
+			{"_id", "id", stringColumn},
+			{"userId", "user_id", stringColumn},
+			{"name", "name", stringColumn},
+			{"prefix", "prefix", stringColumn},
+			{"keyHash", "key_hash", stringColumn},
+			{"scope", "scope", stringColumn},
+			{"createdAt", "created_at", timeColumn},
+			{"expiresAt", "expires_at", timeColumn},
+		},
+	},
+	"loginAttempts": {
+		name: "login_attempts",
+		columns: []relationalColumn{
+			{"_id", "id", stringColumn},
+			{"key", "attempt_key", stringColumn},
+			{"failures", "failures", intColumn},
+			{"lastFailureAt", "last_failure_at", timeColumn},
+			{"lockedUntil", "locked_until", timeColumn},
+		},
+	},
+	"userTokens": {
+		name: "user_tokens",
+		columns: []relationalColumn{
+			{"_id", "id", stringColumn},
+			{"userId", "user_id", stringColumn},
+			{"kind", "kind", stringColumn},
+			{"tokenHash", "token_hash", stringColumn},
+			{"expiresAt", "expires_at", timeColumn},
+		},
+	},
+	"counters": {
+		name: "counters",
+		columns: []relationalColumn{
+			{"_id", "id", stringColumn},
+			{"name", "name", stringColumn},
+			{"value", "value", intColumn},
+		},
+	},
+}
+
+// relationalMigrations contains the statements which create the schema. They are
+// applied in order and only once, so new changes must be appended at the end.
+// The statements must be valid both for sqlite and postgres.
+var relationalMigrations = []string{
+	`CREATE TABLE users (
+		id TEXT PRIMARY KEY,
+		user_name TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		is_admin BOOLEAN NOT NULL
+	)`,
+	`CREATE TABLE lists (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		user_id TEXT NOT NULL
+	)`,
+	`CREATE INDEX lists_user_id ON lists (user_id)`,
+	`CREATE TABLE list_items (
+		list_id TEXT NOT NULL REFERENCES lists (id),
+		position INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL,
+		PRIMARY KEY (list_id, position)
+	)`,
+	`CREATE TABLE counters (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		value BIGINT NOT NULL
+	)`,
+	`ALTER TABLE lists ADD COLUMN created_at BIGINT NOT NULL DEFAULT -62135596800000`,
+	`ALTER TABLE lists ADD COLUMN updated_at BIGINT NOT NULL DEFAULT -62135596800000`,
+	`ALTER TABLE list_items ADD COLUMN id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE list_items ADD COLUMN done BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE list_items ADD COLUMN done_at BIGINT NOT NULL DEFAULT -62135596800000`,
+	`ALTER TABLE list_items ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE list_items ADD COLUMN created_at BIGINT NOT NULL DEFAULT -62135596800000`,
+	`ALTER TABLE list_items ADD COLUMN updated_at BIGINT NOT NULL DEFAULT -62135596800000`,
+	`ALTER TABLE lists ADD COLUMN version BIGINT NOT NULL DEFAULT 0`,
+	`CREATE TABLE list_collaborators (
+		list_id TEXT NOT NULL REFERENCES lists (id),
+		position INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		user_name TEXT NOT NULL,
+		role TEXT NOT NULL,
+		PRIMARY KEY (list_id, position)
+	)`,
+	`CREATE INDEX list_collaborators_user_id ON list_collaborators (user_id)`,
+	`CREATE TABLE list_share_links (
+		list_id TEXT NOT NULL REFERENCES lists (id),
+		position INTEGER NOT NULL,
+		token TEXT NOT NULL UNIQUE,
+		created_at BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL,
+		PRIMARY KEY (list_id, position)
+	)`,
+	`CREATE TABLE refresh_tokens (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		token_id TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX refresh_tokens_user_id ON refresh_tokens (user_id)`,
+	`ALTER TABLE users ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT TRUE`,
+	`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'`,
+	`UPDATE users SET role = 'admin' WHERE is_admin`,
+	`ALTER TABLE users DROP COLUMN is_admin`,
+	`CREATE TABLE api_keys (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		scope TEXT NOT NULL,
+		created_at BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE INDEX api_keys_user_id ON api_keys (user_id)`,
+	`ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE users ADD COLUMN recovery_codes TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE login_attempts (
+		id TEXT PRIMARY KEY,
+		attempt_key TEXT NOT NULL UNIQUE,
+		failures BIGINT NOT NULL,
+		last_failure_at BIGINT NOT NULL,
+		locked_until BIGINT NOT NULL
+	)`,
+	`ALTER TABLE users ADD COLUMN must_change_password BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE`,
+	`CREATE INDEX users_email ON users (email)`,
+	`CREATE TABLE user_tokens (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		expires_at BIGINT NOT NULL
+	)`,
+}
+
+func (t relationalTable) column(field string) (relationalColumn, bool) {
+	for _, c := range t.columns {
+		if c.field == field {
+			return c, true
+		}
+	}
+
+	return relationalColumn{}, false
+}
+
+func (t relationalTable) child(field string) (relationalChildTable, bool) {
+	for _, c := range t.children {
+		if c.field == field {
+			return c, true
+		}
+	}
+
+	return relationalChildTable{}, false
+}
+
+// table returns the child table as a table, so its columns can be queried
+func (c relationalChildTable) table() relationalTable {
+	return relationalTable{name: c.name, columns: c.columns}
+}
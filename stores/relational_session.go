@@ -0,0 +1,153 @@
+package stores
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// MyRelationalSession is a MongoSession which stores the collections in a sql database.
+// It supports sqlite and postgres. The database driver must be registered by the caller.
+type MyRelationalSession struct {
+	db       *sql.DB
+	postgres bool
+}
+
+// NewMyRelationalSession returns a new MyRelationalSession. It creates the database
+// schema if needed.
+func NewMyRelationalSession(driverName string, dataSourceName string) *MyRelationalSession {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		panic(err)
+	}
+
+	s := &MyRelationalSession{
+		db:       db,
+		postgres: driverName == "postgres",
+	}
+
+	if !s.postgres {
+		// sqlite doesn't allow concurrent writes and each connection to an in memory
+		// database has its own data, so a single connection is used
+		db.SetMaxOpenConns(1)
+	}
+
+	if err = db.Ping(); err != nil {
+		panic(err)
+	}
+
+	if err = s.migrate(); err != nil {
+		panic(err)
+	}
+
+	log.Printf("Connected with lists %v database.", driverName)
+
+	return s
+}
+
+// GetRepository returns a relational repository for the given collection
+func (s *MyRelationalSession) GetRepository(collectionName string) Repository {
+	t, ok := relationalSchema[collectionName]
+	if !ok {
+		panic(fmt.Sprintf("there isn't any table for the collection %q", collectionName))
+	}
+
+	return &RelationalRepository{
+		session:        s,
+		collectionName: collectionName,
+		table:          t,
+	}
+}
+
+func (s *MyRelationalSession) migrate() error {
+	if _, err := s.db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)"); err != nil {
+		return err
+	}
+
+	var version int
+	if err := s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(relationalMigrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec(relationalMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %v failed: %v", i+1, err)
+		}
+
+		if _, err = tx.Exec(s.rebind("INSERT INTO schema_migrations (version) VALUES (?)"), i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return s.addMissingItemIDs()
+}
+
+// addMissingItemIDs gives an id to the list items saved before the items had one. The ids
+// are generated by the app, so it can't be done by a migration statement.
+func (s *MyRelationalSession) addMissingItemIDs() error {
+	rows, err := s.db.Query("SELECT list_id, " + positionColumn + " FROM list_items WHERE id = ''")
+	if err != nil {
+		return err
+	}
+
+	type itemKey struct {
+		listID   string
+		position int
+	}
+
+	keys := []itemKey{}
+	for rows.Next() {
+		k := itemKey{}
+		if err := rows.Scan(&k.listID, &k.position); err != nil {
+			rows.Close()
+			return err
+		}
+		keys = append(keys, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// sqlite uses a single connection, so the rows are read before updating them
+	for _, k := range keys {
+		_, err := s.db.Exec(s.rebind("UPDATE list_items SET id = ? WHERE list_id = ? AND "+positionColumn+" = ? AND id = ''"), NewID(), k.listID, k.position)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebind replaces the ? placeholders with the ones used by the database
+func (s *MyRelationalSession) rebind(query string) string {
+	if !s.postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%v", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
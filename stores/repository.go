@@ -1,11 +1,21 @@
 package stores
 
+import "gopkg.in/mgo.v2/bson"
+
 // Repository is the interface which a store must implement
 type Repository interface {
-	Get(item interface{}, query interface{}, selector interface{}) error
-	GetOne(item interface{}, query interface{}, selector interface{}) error
+	Get(items interface{}, query Query) error
+	GetOne(item interface{}, query Query) error
+	Count(filter Filter) (int, error)
 	Add(item interface{}) (string, error)
-	Remove(query interface{}) error
-	Update(query interface{}, item interface{}) error
+	Remove(filter Filter) error
+	Update(filter Filter, item interface{}) error
+	Modify(filter Filter, modifications ...Modification) error
+	Upsert(filter Filter, item interface{}, modifications ...Modification) error
 	IsValidID(id string) bool
 }
+
+// NewID returns a new unique id. All the stores use the same format.
+func NewID() string {
+	return bson.NewObjectId().Hex()
+}
@@ -0,0 +1,358 @@
+package stores
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	appErrors "github.com/AngelVlc/lists-backend/errors"
+	"github.com/AngelVlc/lists-backend/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRepositoryCRUD(t *testing.T, session MongoSession) {
+	repository := session.GetRepository("lists")
+
+	gotLists := []models.GetListsResultDto{}
+	err := repository.Get(&gotLists, Query{Projection: Fields("name")})
+	assert.Equal(t, 0, len(gotLists), "new collection should have zero lists")
+	assert.Nil(t, err)
+
+	data := models.SampleList()
+	id, err := repository.Add(&data)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, id)
+	assert.True(t, repository.IsValidID(id))
+
+	foundList := models.List{}
+	err = repository.GetOne(&foundList, Query{Filter: Eq(IDField, id)})
+	assert.Nil(t, err)
+	assert.Equal(t, data, foundList)
+
+	gotLists = []models.GetListsResultDto{}
+	err = repository.Get(&gotLists, Query{Projection: Fields("name")})
+	assert.Equal(t, []models.GetListsResultDto{{ID: id, Name: data.Name}}, gotLists)
+	assert.Nil(t, err)
+
+	dataToReplace := models.SampleList()
+	dataToReplace.Name = "REPLACED"
+	err = repository.Update(Eq(IDField, id), &dataToReplace)
+	assert.Nil(t, err)
+
+	foundList = models.List{}
+	err = repository.GetOne(&foundList, Query{Filter: Eq(IDField, id)})
+	assert.Nil(t, err)
+	assert.Equal(t, id, foundList.ID, "the replacement should keep the id")
+	assert.Equal(t, dataToReplace.Name, foundList.Name)
+
+	err = repository.Remove(Eq(IDField, id))
+	assert.Nil(t, err)
+
+	err = repository.GetOne(&models.List{}, Query{Filter: Eq(IDField, id)})
+	assert.IsType(t, &appErrors.NotFoundError{}, err)
+	assert.Equal(t, "lists not found", err.Error())
+
+	err = repository.Update(Eq(IDField, id), &dataToReplace)
+	assert.IsType(t, &appErrors.NotFoundError{}, err)
+
+	err = repository.Remove(Eq(IDField, id))
+	assert.IsType(t, &appErrors.NotFoundError{}, err)
+}
+
+func testRepositoryQueries(t *testing.T, session MongoSession) {
+	repository := session.GetRepository("lists")
+
+	for _, l := range models.SampleListSlice() {
+		l.UserID = "user" + l.ID
+		_, err := repository.Add(&l)
+		assert.Nil(t, err)
+	}
+
+	t.Run("filters by several fields", func(t *testing.T) {
+		got := []models.List{}
+		err := repository.Get(&got, Query{Filter: And(Eq("userId", "user1"), Eq("name", "list1"))})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(got))
+		assert.Equal(t, "list1", got[0].Name)
+
+		got = []models.List{}
+		err = repository.Get(&got, Query{Filter: And(Eq("userId", "user1"), Eq("name", "list2"))})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(got))
+	})
+
+	t.Run("supports the filter operations", func(t *testing.T) {
+		got := []models.List{}
+		err := repository.Get(&got, Query{Filter: In("name", "list2", "wadus")})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(got))
+		assert.Equal(t, "list2", got[0].Name)
+
+		got = []models.List{}
+		err = repository.Get(&got, Query{Filter: Or(Eq("name", "list1"), Eq("userId", "user2"))})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(got))
+
+		got = []models.List{}
+		err = repository.Get(&got, Query{Filter: Gt("name", "list1")})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(got))
+		assert.Equal(t, "list2", got[0].Name)
+
+		got = []models.List{}
+		err = repository.Get(&got, Query{Filter: And(Gte("name", "list1"), Lte("name", "list2"), Ne("userId", "user2"))})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(got))
+		assert.Equal(t, "list1", got[0].Name)
+
+		got = []models.List{}
+		err = repository.Get(&got, Query{Filter: In("name")})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(got))
+	})
+
+	t.Run("filters by text ignoring the case", func(t *testing.T) {
+		got := []models.List{}
+		err := repository.Get(&got, Query{Filter: Contains("name", "ST2")})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(got))
+		assert.Equal(t, "list2", got[0].Name)
+
+		got = []models.List{}
+		err = repository.Get(&got, Query{Filter: Contains("name", "%")})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(got))
+	})
+
+	t.Run("filters by the elements of an array", func(t *testing.T) {
+		got := []models.List{}
+		err := repository.Get(&got, Query{Filter: Or(Eq("userId", "user2"), ElemMatch("collaborators", Eq("userId", "2")))})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(got))
+
+		got = []models.List{}
+		err = repository.Get(&got, Query{Filter: ElemMatch("collaborators", And(Eq("userId", "1"), Eq("role", models.RoleEditor)))})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(got))
+	})
+
+	t.Run("counts the documents", func(t *testing.T) {
+		n, err := repository.Count(All())
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n)
+
+		n, err = repository.Count(Contains("name", "list1"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("sorts and limits the results", func(t *testing.T) {
+		got := []models.List{}
+		err := repository.Get(&got, Query{Sort: []Sort{Desc("name")}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(got))
+		assert.Equal(t, "list2", got[0].Name)
+		assert.Equal(t, "list1", got[1].Name)
+
+		got = []models.List{}
+		err = repository.Get(&got, Query{Sort: []Sort{Asc("name")}, Limit: 1})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(got))
+		assert.Equal(t, "list1", got[0].Name)
+
+		l := models.List{}
+		err = repository.GetOne(&l, Query{Sort: []Sort{Desc("name")}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "list2", l.Name)
+	})
+
+	t.Run("returns an unexpected error when the filter is not supported", func(t *testing.T) {
+		got := []models.List{}
+		err := repository.Get(&got, Query{Filter: Filter{Op: "regex", Field: "name", Value: "list"}})
+
+		assert.IsType(t, &appErrors.UnexpectedError{}, err)
+		assert.Equal(t, "Error retrieving from the database", err.Error())
+	})
+
+	t.Run("applies the projection", func(t *testing.T) {
+		got := models.List{}
+		err := repository.GetOne(&got, Query{Filter: Eq("name", "list1"), Projection: Fields("name")})
+
+		assert.Nil(t, err)
+		assert.NotEmpty(t, got.ID)
+		assert.Equal(t, "list1", got.Name)
+		assert.Empty(t, got.UserID)
+		assert.Nil(t, got.Items)
+	})
+}
+
+func testRepositoryTimes(t *testing.T, session MongoSession) {
+	repository := session.GetRepository("lists")
+
+	created := time.Date(2020, 5, 1, 10, 0, 0, int(123*time.Millisecond), time.UTC)
+	for i, name := range []string{"b", "a", "c"} {
+		l := models.List{Name: name, CreatedAt: created.Add(time.Duration(i) * time.Hour)}
+		_, err := repository.Add(&l)
+		assert.Nil(t, err)
+	}
+
+	got := []models.List{}
+	err := repository.Get(&got, Query{Filter: Gt("createdAt", created), Sort: []Sort{Desc("createdAt")}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, "c", got[0].Name)
+	assert.Equal(t, "a", got[1].Name)
+	assert.True(t, created.Add(time.Hour).Equal(got[1].CreatedAt))
+	assert.True(t, got[1].UpdatedAt.IsZero())
+}
+
+func testRepositoryArrays(t *testing.T, session MongoSession) {
+	repository := session.GetRepository("lists")
+
+	l := models.List{
+		Name: "list",
+		Items: []models.Item{
+			{ID: "i1", Title: "item1"},
+			{ID: "i2", Title: "item2"},
+		},
+	}
+	id, err := repository.Add(&l)
+	assert.Nil(t, err)
+
+	_, err = repository.Add(&models.List{Name: "other", Items: []models.Item{{ID: "i3"}}})
+	assert.Nil(t, err)
+
+	got := []models.List{}
+	err = repository.Get(&got, Query{Filter: ElemMatch("items", Eq("id", "i2"))})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, id, got[0].ID)
+
+	err = repository.Modify(Eq(IDField, id), Push("items", models.Item{ID: "i4", Title: "item4"}), Set("name", "changed"))
+	assert.Nil(t, err)
+
+	itemFilter := And(Eq(IDField, id), ElemMatch("items", Eq("id", "i1")))
+	err = repository.Modify(itemFilter, SetElement("items", Eq("id", "i1"), map[string]interface{}{"title": "new", "description": "desc"}))
+	assert.Nil(t, err)
+
+	err = repository.Modify(And(Eq(IDField, id), ElemMatch("items", Eq("id", "i2"))), Pull("items", Eq("id", "i2")))
+	assert.Nil(t, err)
+
+	err = repository.Modify(And(Eq(IDField, id), ElemMatch("items", Eq("id", "i2"))), Pull("items", Eq("id", "i2")))
+	assert.IsType(t, &appErrors.NotFoundError{}, err)
+
+	found := models.List{}
+	err = repository.GetOne(&found, Query{Filter: Eq(IDField, id)})
+	assert.Nil(t, err)
+	assert.Equal(t, "changed", found.Name)
+	assert.Equal(t, []models.Item{
+		{ID: "i1", Title: "new", Description: "desc"},
+		{ID: "i4", Title: "item4"},
+	}, found.Items)
+
+	other := models.List{}
+	err = repository.GetOne(&other, Query{Filter: Eq("name", "other")})
+	assert.Nil(t, err)
+	assert.Equal(t, []models.Item{{ID: "i3"}}, other.Items)
+
+	err = repository.Modify(Eq(IDField, id), Push("items", models.Item{ID: "i5", Done: true}))
+	assert.Nil(t, err)
+
+	err = repository.Modify(Eq(IDField, id), SetElement("items", Ne("done", true), map[string]interface{}{"done": true}))
+	assert.Nil(t, err)
+
+	err = repository.Modify(Eq(IDField, id), SetElement("items", Eq("done", true), map[string]interface{}{"archived": true}))
+	assert.Nil(t, err)
+
+	found = models.List{}
+	err = repository.GetOne(&found, Query{Filter: Eq(IDField, id)})
+	assert.Nil(t, err)
+	for _, i := range found.Items {
+		assert.True(t, i.Done && i.Archived, "all the items should be done and archived")
+	}
+
+	err = repository.Modify(Eq(IDField, id), Pull("items", Eq("archived", true)))
+	assert.Nil(t, err)
+
+	found = models.List{}
+	err = repository.GetOne(&found, Query{Filter: Eq(IDField, id)})
+	assert.Nil(t, err)
+	assert.Empty(t, found.Items)
+}
+
+func testRepositoryCounters(t *testing.T, session MongoSession) {
+	repository := session.GetRepository("counters")
+
+	c := models.Counter{Name: "requests", Value: 1}
+	_, err := repository.Add(&c)
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		err = repository.Modify(Eq("name", "requests"), Inc("value", 1))
+		assert.Nil(t, err)
+	}
+
+	got := models.Counter{}
+	err = repository.GetOne(&got, Query{Filter: Eq("name", "requests")})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, got.Value)
+
+	err = repository.Modify(Eq("name", "requests"), Set("value", 10))
+	assert.Nil(t, err)
+
+	err = repository.GetOne(&got, Query{Filter: Eq("name", "requests")})
+	assert.Nil(t, err)
+	assert.Equal(t, 10, got.Value)
+
+	err = repository.Modify(Eq("name", "wadus"), Inc("value", 1))
+	assert.IsType(t, &appErrors.NotFoundError{}, err)
+}
+
+func testRepositoryUpsert(t *testing.T, session MongoSession) {
+	repository := session.GetRepository("loginAttempts")
+
+	for i := 0; i < 2; i++ {
+		a := models.LoginAttempt{Key: "wadus"}
+		err := repository.Upsert(Eq("key", "wadus"), &a, Inc("failures", 1))
+		assert.Nil(t, err)
+		assert.Equal(t, "wadus", a.Key)
+		assert.Equal(t, i+1, a.Failures)
+	}
+
+	count, err := repository.Count(Eq("key", "wadus"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, repository.Upsert(Eq("key", "other"), &models.LoginAttempt{Key: "other"}, Inc("failures", 1)))
+		}()
+	}
+	wg.Wait()
+
+	got := []models.LoginAttempt{}
+	err = repository.Get(&got, Query{Filter: Eq("key", "other")})
+	assert.Nil(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, 10, got[0].Failures)
+}